@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	assertMinWorking int
+	assertMaxLatency time.Duration
+	// assertTimeout is the --timeout of assert, whose default differs from the root command's
+	assertTimeout time.Duration
+)
+
+// assertEndpoint is the health of an endpoint, healthy when it passed testing within the
+// latency bound
+type assertEndpoint struct {
+	URL       string `json:"url"`
+	Healthy   bool   `json:"healthy"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	// Problem is why the endpoint is not healthy, Cause classifies failures of testing
+	Problem string `json:"problem,omitempty"`
+	Cause   string `json:"cause,omitempty"`
+}
+
+// assertReport is the outcome of assert, passed when enough endpoints are healthy
+type assertReport struct {
+	Chain        string           `json:"chain"`
+	ChainID      uint64           `json:"chainId"`
+	Pass         bool             `json:"pass"`
+	Healthy      int              `json:"healthy"`
+	MinWorking   int              `json:"minWorking"`
+	MaxLatencyMs int64            `json:"maxLatencyMs,omitempty"`
+	Endpoints    []assertEndpoint `json:"endpoints"`
+}
+
+var assertCmd = &cobra.Command{
+	Use:   "assert <chainId|chainName> --min-working 3 --max-latency 500ms",
+	Short: "Fail unless a chain has enough healthy endpoints",
+	Long:  "Tests the endpoints of the chain and checks that at least --min-working of them pass, each answering within --max-latency if set. Prints a report of every endpoint, as JSON with --json, and exits non-zero unless the assertion holds, to gate deploys on RPC availability in CI",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if assertMinWorking < 1 {
+			return NewParameterErrorWithCmd("min-working must be at least 1", cmd)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
+		if len(rpcUrls) == 0 {
+			return noRPCsError(chainData.RPCs)
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		tester.Timeout = assertTimeout
+		results := tester.CheckRPCs(rpcUrls, chainData.ChainID)
+		reportResults(results...)
+
+		report := assertHealth(results)
+		report.Chain = chainData.Name
+		report.ChainID = chainData.ChainID
+		if asJSON {
+			if err := printJSON(report); err != nil {
+				return err
+			}
+		} else if err := printAssertReport(report); err != nil {
+			return err
+		}
+
+		if !report.Pass {
+			return withExitCode(EXIT_NO_ENDPOINTS, fmt.Errorf("%d of %d endpoints of %s are healthy, %d required", report.Healthy, len(report.Endpoints), chainData.Name, assertMinWorking))
+		}
+		return nil
+	},
+}
+
+// assertHealth tells which endpoints are healthy and whether there are enough of them
+func assertHealth(results []rpc.RPCResult) assertReport {
+	report := assertReport{MinWorking: assertMinWorking, MaxLatencyMs: assertMaxLatency.Milliseconds()}
+	for _, result := range results {
+		endpoint := assertEndpoint{URL: redactURL(result.URL), Problem: result.Err, Cause: result.Cause}
+		if result.Err == "" {
+			endpoint.LatencyMs = result.Latency.Milliseconds()
+			if assertMaxLatency > 0 && result.Latency > assertMaxLatency {
+				endpoint.Problem = fmt.Sprintf("latency %s above %s", formatLatency(result.Latency), formatLatency(assertMaxLatency))
+			} else {
+				endpoint.Healthy = true
+				report.Healthy++
+			}
+		}
+		report.Endpoints = append(report.Endpoints, endpoint)
+	}
+	report.Pass = report.Healthy >= assertMinWorking
+	return report
+}
+
+func printAssertReport(report assertReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tLATENCY\tSTATUS")
+	for _, endpoint := range report.Endpoints {
+		latency, status := "-", "healthy"
+		if endpoint.LatencyMs > 0 || endpoint.Problem == "" {
+			latency = formatLatency(msDuration(endpoint.LatencyMs))
+		}
+		if !endpoint.Healthy {
+			status = "unhealthy: " + endpoint.Problem
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", endpoint.URL, latency, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	verdict := "PASS"
+	if !report.Pass {
+		verdict = "FAIL"
+	}
+	fmt.Printf("%s: %d of %d endpoints of %s are healthy, %d required\n", verdict, report.Healthy, len(report.Endpoints), report.Chain, report.MinWorking)
+	return nil
+}
+
+func init() {
+	assertCmd.Flags().IntVar(&assertMinWorking, "min-working", 1, "fewest healthy endpoints the chain must have")
+	durationVar(assertCmd.Flags(), &assertMaxLatency, "max-latency", 0, "highest latency of a healthy endpoint (0: no bound)")
+	assertCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	assertCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(assertCmd.Flags(), &assertTimeout, "timeout", "t", 2*time.Second, "timeout for testing every endpoint")
+	assertCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	assertCmd.Flags().BoolVar(&wsOnly, "wss", false, "only assert WebSocket endpoints")
+	assertCmd.Flags().BoolVar(&httpsOnly, "https", false, "only assert HTTPS endpoints")
+	assertCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	assertCmd.Flags().BoolVar(&excludeSyncing, "exclude-syncing", false, "count endpoints that report through eth_syncing that they are still syncing as unhealthy")
+	addOutputFlags(assertCmd)
+}
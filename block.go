@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var blockFull bool
+
+var blockCmd = &cobra.Command{
+	Use:   "block <chainId|chainName>",
+	Short: "Print the latest block of a chain",
+	Long:  "Finds a working endpoint of the chain and prints its latest block number, or the latest block header with --full",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		if !blockFull {
+			var blockNumber uint64
+			err := withWorkingEndpoint(cmd, args[0], func(ctx context.Context, rpcURL string) error {
+				var err error
+				blockNumber, err = rpc.BlockNumber(ctx, rpcURL)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				return printJSON(map[string]uint64{"number": blockNumber})
+			}
+			fmt.Println(blockNumber)
+			return nil
+		}
+
+		var block *rpc.Block
+		err = withWorkingEndpoint(cmd, args[0], func(ctx context.Context, rpcURL string) error {
+			var err error
+			block, err = rpc.LatestBlock(ctx, rpcURL)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(block)
+		}
+
+		fmt.Printf("Number:       %d\n", block.Number)
+		fmt.Printf("Hash:         %s\n", block.Hash)
+		fmt.Printf("Parent hash:  %s\n", block.ParentHash)
+		fmt.Printf("Timestamp:    %s (%s ago)\n", block.Timestamp.Format(time.RFC3339), formatDuration(time.Since(block.Timestamp)))
+		fmt.Printf("Miner:        %s\n", block.Miner)
+		if block.GasLimit > 0 {
+			fmt.Printf("Gas used:     %d of %d (%.1f%%)\n", block.GasUsed, block.GasLimit, 100*float64(block.GasUsed)/float64(block.GasLimit))
+		}
+		if block.BaseFeePerGas != nil {
+			fmt.Printf("Base fee:     %s\n", formatGwei(block.BaseFeePerGas))
+		}
+		fmt.Printf("Transactions: %d\n", block.Transactions)
+		return nil
+	},
+}
+
+// formatGwei prints a wei amount in gwei, with the digits that matter for fees from
+// thousands of gwei down to the fractions of a gwei of rollups
+func formatGwei(wei *big.Int) string {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e9)).Float64()
+	switch {
+	case gwei >= 100:
+		return fmt.Sprintf("%.0f gwei", gwei)
+	case gwei >= 1:
+		return fmt.Sprintf("%.2f gwei", gwei)
+	default:
+		return fmt.Sprintf("%.4g gwei", gwei)
+	}
+}
+
+func init() {
+	blockCmd.Flags().BoolVar(&blockFull, "full", false, "print the latest block header rather than its number")
+	addRequestFlags(blockCmd)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var endpointBudget rpc.Budget
+
+// addBudgetFlags adds the latency and error budget flags of endpoints to the command
+func addBudgetFlags(cmd *cobra.Command) {
+	durationVar(cmd.Flags(), &endpointBudget.P95, "budget-p95", 0, "demote endpoints whose 95th percentile latency over their last 20 outcomes exceeds this (0: no limit)")
+	cmd.Flags().Float64Var(&endpointBudget.ErrorRate, "budget-errors", 0, "demote endpoints failing more than this share, from 0 to 1, of their last 20 outcomes (0: no limit)")
+	durationVar(cmd.Flags(), &endpointBudget.Recovery, "budget-recovery", rpc.BUDGET_RECOVERY, "time a demoted endpoint must stay within budget to be promoted back")
+}
+
+// newBudgetTracker returns the tracker of the budget flags, nil without a budget
+func newBudgetTracker(cmd *cobra.Command) (*rpc.BudgetTracker, error) {
+	if endpointBudget.ErrorRate < 0 || endpointBudget.ErrorRate > 1 {
+		return nil, NewParameterErrorWithCmd("budget-errors must be between 0 and 1", cmd)
+	}
+	if endpointBudget.P95 == 0 && endpointBudget.ErrorRate == 0 {
+		return nil, nil
+	}
+	return rpc.NewBudgetTracker(endpointBudget), nil
+}
+
+// formatBudgetFigures describes the figures an endpoint was demoted or promoted on
+func formatBudgetFigures(change rpc.BudgetChange) string {
+	return fmt.Sprintf("p95 %s, %d%% errors", formatLatency(change.P95), int(math.Round(change.ErrorRate*100)))
+}
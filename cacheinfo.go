@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+// cacheInfo is the JSON representation of the cache info command output
+type cacheInfo struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+	Size   int64  `json:"size"`
+	// AgeSeconds is the time since the dataset was fetched
+	AgeSeconds int64 `json:"ageSeconds"`
+	// TTLSeconds is the time until the cache expires, negative once expired
+	TTLSeconds int64 `json:"ttlSeconds"`
+	ChainCount int   `json:"chainCount"`
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show cache location, size and age",
+	Long:  "Shows where the cache lives, its size, its age, the time until it expires and the number of cached chains",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		status, err := chain.GetCacheStatus()
+		if err != nil {
+			return err
+		}
+
+		info := cacheInfo{Path: status.Path, Exists: status.Exists, Size: status.Size}
+		if status.Exists {
+			info.AgeSeconds = int64(time.Since(status.Meta.FetchedAt).Seconds())
+			info.TTLSeconds = int64(time.Until(status.Meta.ExpiresAt).Seconds())
+			info.ChainCount = status.Meta.ChainCount
+		}
+
+		if asJSON {
+			return printJSON(info)
+		}
+
+		fmt.Printf("Path:          %s\n", info.Path)
+		if !info.Exists {
+			fmt.Println("Exists:        no")
+			return nil
+		}
+		fmt.Printf("Size:          %s\n", formatSize(info.Size))
+		fmt.Printf("Age:           %s\n", formatDuration(time.Since(status.Meta.FetchedAt)))
+		if ttl := time.Until(status.Meta.ExpiresAt); ttl > 0 {
+			fmt.Printf("TTL remaining: %s\n", formatDuration(ttl))
+		} else {
+			fmt.Printf("TTL remaining: expired %s ago\n", formatDuration(-ttl))
+		}
+		if info.ChainCount > 0 {
+			fmt.Printf("Chains:        %s\n", formatCount(info.ChainCount))
+		} else {
+			fmt.Println("Chains:        unknown (rebuild with `chain-rpc cache build`)")
+		}
+		return nil
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show what each source contributed to the cache",
+	Long:  "Breaks the cached dataset down by source: chains and endpoints each source provided, and how many of them no earlier source in the merge order had",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		status, err := chain.GetCacheStatus()
+		if err != nil {
+			return err
+		}
+
+		var stats []chain.SourceStats
+		if status.Exists {
+			stats = status.Meta.SourceStats
+		}
+
+		if asJSON {
+			return printJSON(struct {
+				Sources []chain.SourceStats `json:"sources"`
+			}{append([]chain.SourceStats{}, stats...)})
+		}
+
+		if !status.Exists {
+			return fmt.Errorf("cache does not exist, build it with `chain-rpc cache build`")
+		}
+		if len(stats) == 0 {
+			return fmt.Errorf("cache has no source statistics, rebuild it with `chain-rpc cache build`")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SOURCE\tCHAINS\tNEW CHAINS\tENDPOINTS\tNEW ENDPOINTS")
+		for _, stat := range stats {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", stat.Name, formatCount(stat.Chains), formatCount(stat.NewChains), formatCount(stat.Endpoints), formatCount(stat.NewEndpoints))
+		}
+		return w.Flush()
+	},
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%s %ciB", formatDecimal(float64(size)/float64(div), 1), "KMGTPE"[exp])
+}
+
+// formatDuration prints durations of days in days and hours rather than hundreds of hours
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 24*time.Hour {
+		return d.String()
+	}
+	d = d.Round(time.Hour)
+	days := d / (24 * time.Hour)
+	hours := (d - days*24*time.Hour) / time.Hour
+	return fmt.Sprintf("%dd%dh", days, hours)
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheInfoCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	addOutputFlags(cacheInfoCmd)
+	addOutputFlags(cacheStatsCmd)
+}
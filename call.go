@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	callAttempts int
+	callTimeout  time.Duration
+	callQuorum   int
+)
+
+var callCmd = &cobra.Command{
+	Use:   "call <chainId|chainName> <method> [params...]",
+	Short: "Send a JSON-RPC request to a working endpoint",
+	Long:  "Finds a working endpoint of the chain, sends the JSON-RPC request and prints its result. Params that are valid JSON (numbers, true, objects, arrays, quoted strings) are sent as such, others as strings, e.g. 0x1b4 or latest. When an endpoint fails, the request is sent again to another working endpoint, up to --attempts times in all. With --quorum, the request is sent to several endpoints at once and the result is only printed when a majority of them agree, best with pinned block numbers rather than latest.",
+	Args:  minimumArgsWithParameterError(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		method := args[1]
+		params := parseCallParams(args[2:])
+
+		if callQuorum < 0 {
+			return NewParameterErrorWithCmd("quorum must not be negative", cmd)
+		}
+		if callQuorum > 1 {
+			result, err := quorumCall(cmd, args[0], method, params)
+			if err != nil {
+				return err
+			}
+			return printCallResult(result, asJSON)
+		}
+
+		var result json.RawMessage
+		err = withWorkingEndpoint(cmd, args[0], func(ctx context.Context, rpcURL string) error {
+			var err error
+			result, err = rpc.Call(ctx, rpcURL, method, params...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return printCallResult(result, asJSON)
+	},
+}
+
+// withWorkingEndpoint finds working endpoints of the chain like the root command does
+// and runs request on one of them, bounded by --call-timeout. When the request fails,
+// it runs again on another working endpoint, up to --attempts times in all.
+func withWorkingEndpoint(cmd *cobra.Command, identifier string, request func(ctx context.Context, rpcURL string) error) error {
+	if callAttempts < 1 {
+		return NewParameterErrorWithCmd("attempts must be at least 1", cmd)
+	}
+
+	results, strategy, err := workingEndpoints(cmd, identifier)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		picked := strategy.Pick(results)
+		results = removeResult(results, picked.URL)
+		warnInsecure(picked.URL)
+
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		err := request(ctx, picked.URL)
+		cancel()
+		if err == nil {
+			logger.Info("requested endpoint", "url", picked.URL)
+			return nil
+		}
+
+		if isCallerError(err) || attempt == callAttempts || len(results) == 0 {
+			return err
+		}
+		warnf("%v on %s, retrying with another endpoint", err, templateURL(picked.URL))
+	}
+}
+
+// workingEndpoints tests the endpoints of the chain like the root command does and
+// returns the working ones, with the strategy to pick among them
+func workingEndpoints(cmd *cobra.Command, identifier string) ([]rpc.RPCResult, rpc.Strategy, error) {
+	chainData, err := getChainData(identifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
+	if len(rpcUrls) == 0 {
+		return nil, nil, noRPCsError(chainData.RPCs)
+	}
+
+	strategy, err := selectionStrategy(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tester, err := newTester(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := rpc.UnwrapStrategy(strategy).(rpc.FastestStrategy); ok {
+		tester.WarmUp = true
+	}
+	if rpc.UsesScores(strategy) {
+		enableScoring(tester, chainData)
+	}
+
+	results := tester.TestRPCs(rpcUrls, chainData.ChainID)
+	reportResults(results...)
+	working := make([]string, 0, len(results))
+	for _, result := range results {
+		working = append(working, result.URL)
+	}
+	saveWorkingRPCs(chainData.ChainID, rpcUrls, working)
+	if len(results) == 0 {
+		return nil, nil, rpc.ErrNoRPCsFound
+	}
+	return results, strategy, nil
+}
+
+// quorumAnswer is the result an endpoint answered the request with
+type quorumAnswer struct {
+	url    string
+	result json.RawMessage
+	err    error
+}
+
+// quorumCall sends the request to --quorum working endpoints at once, and to others in
+// place of the ones failing, and returns the result a majority of them agree on. The
+// endpoints answering otherwise are reported, as they may be broken or malicious.
+func quorumCall(cmd *cobra.Command, identifier string, method string, params []any) (json.RawMessage, error) {
+	results, strategy, err := workingEndpoints(cmd, identifier)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) < callQuorum {
+		return nil, fmt.Errorf("only %d working endpoints, fewer than the quorum of %d", len(results), callQuorum)
+	}
+
+	var answers []quorumAnswer
+	for len(answers) < callQuorum && len(results) > 0 {
+		// Endpoints are asked at once, so that they answer about the same chain state
+		urls := make([]string, 0, callQuorum-len(answers))
+		for len(urls) < callQuorum-len(answers) && len(results) > 0 {
+			picked := strategy.Pick(results)
+			results = removeResult(results, picked.URL)
+			warnInsecure(picked.URL)
+			urls = append(urls, picked.URL)
+		}
+
+		round := make([]quorumAnswer, len(urls))
+		var wg sync.WaitGroup
+		for i, rpcURL := range urls {
+			wg.Add(1)
+			go func(i int, rpcURL string) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+				defer cancel()
+				result, err := rpc.Call(ctx, rpcURL, method, params...)
+				round[i] = quorumAnswer{url: rpcURL, result: result, err: err}
+			}(i, rpcURL)
+		}
+		wg.Wait()
+
+		for _, answer := range round {
+			if answer.err == nil {
+				answers = append(answers, answer)
+				continue
+			}
+			if isCallerError(answer.err) {
+				return nil, answer.err
+			}
+			warnf("%v on %s, asking another endpoint", answer.err, templateURL(answer.url))
+		}
+	}
+	if len(answers) < callQuorum {
+		return nil, fmt.Errorf("only %d of %d endpoints answered, short of the quorum", len(answers), callQuorum)
+	}
+
+	// Results are compared once normalized, as endpoints may order and space them differently
+	counts := make(map[string]int)
+	normalized := make([]string, len(answers))
+	for i, answer := range answers {
+		normalized[i] = normalizeJSON(answer.result)
+		counts[normalized[i]]++
+	}
+	majority := ""
+	for result, count := range counts {
+		if count > callQuorum/2 {
+			majority = result
+		}
+	}
+
+	for i, answer := range answers {
+		switch {
+		case majority == "":
+			warnf("%s answered %s", templateURL(answer.url), truncateResult(answer.result))
+		case normalized[i] != majority:
+			warnf("%s disagrees with the majority, answered %s", templateURL(answer.url), truncateResult(answer.result))
+		default:
+			logger.Info("requested endpoint", "url", answer.url)
+		}
+	}
+	if majority == "" {
+		return nil, fmt.Errorf("no majority among %d endpoints: %d different results", callQuorum, len(counts))
+	}
+	return json.RawMessage(majority), nil
+}
+
+// normalizeJSON returns the compact JSON of the value, with sorted object keys
+func normalizeJSON(data json.RawMessage) string {
+	// Numbers are kept as written, float64 would round large ones
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return string(data)
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return string(data)
+	}
+	return string(normalized)
+}
+
+// truncateResult shortens results for warnings
+func truncateResult(result json.RawMessage) string {
+	const limit = 80
+	if len(result) <= limit {
+		return string(result)
+	}
+	return string(result[:limit]) + "…"
+}
+
+// parseCallParams sends params that are valid JSON as such and the others as strings,
+// so that neither hex quantities nor block tags need quoting
+func parseCallParams(args []string) []any {
+	params := make([]any, 0, len(args))
+	for _, arg := range args {
+		if json.Valid([]byte(arg)) {
+			params = append(params, json.RawMessage(arg))
+		} else {
+			params = append(params, arg)
+		}
+	}
+	return params
+}
+
+// isCallerError reports whether the request itself is at fault, so that another
+// endpoint would reject it as well
+func isCallerError(err error) bool {
+	var rpcErr *rpc.RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	// Parse error, invalid request and invalid params
+	return rpcErr.Code == -32700 || rpcErr.Code == -32600 || rpcErr.Code == -32602
+}
+
+// printCallResult prints strings unquoted and other results indented, or the result
+// as the endpoint returned it with --json
+func printCallResult(result json.RawMessage, asJSON bool) error {
+	if asJSON {
+		fmt.Println(string(result))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(result, &s); err == nil {
+		fmt.Println(s)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, result, "", "  "); err != nil {
+		return fmt.Errorf("invalid result: %v", err)
+	}
+	fmt.Println(buf.String())
+	return nil
+}
+
+// addRequestFlags registers the flags of commands sending requests through withWorkingEndpoint
+func addRequestFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&callAttempts, "attempts", 3, "send the request again to another working endpoint while it fails, up to this many times in all")
+	durationVar(cmd.Flags(), &callTimeout, "call-timeout", 10*time.Second, "timeout for the request, once an endpoint is found")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(cmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	cmd.Flags().BoolVar(&wsOnly, "wss", false, "use only WebSocket RPC URLs")
+	cmd.Flags().BoolVar(&httpsOnly, "https", false, "use only HTTPS RPC URLs")
+	cmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	cmd.Flags().StringVar(&strategyName, "strategy", "random", fmt.Sprintf("how to pick among working endpoints: %s", strings.Join(rpc.StrategyNames(), ", ")))
+	cmd.Flags().BoolVar(&fastest, "fastest", false, "shorthand for --strategy fastest")
+	cmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	addOutputFlags(cmd)
+}
+
+func init() {
+	addRequestFlags(callCmd)
+	callCmd.Flags().IntVar(&callQuorum, "quorum", 0, "send the request to this many working endpoints at once and only print the result a majority of them agree on (0: one endpoint)")
+}
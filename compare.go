@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+const DEFAULT_ROUND_INTERVAL = time.Second
+
+var (
+	referenceURL  string
+	compareRounds int
+	roundInterval time.Duration
+	// compareTimeout is the --timeout of compare, whose default differs from the root command's
+	compareTimeout time.Duration
+)
+
+// comparison is an endpoint measured against the reference
+type comparison struct {
+	URL       string `json:"url"`
+	Reference bool   `json:"reference,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+	// LatencyDeltaMs and LatencyRatio compare the latency to the one of the reference
+	LatencyDeltaMs int64   `json:"latencyDeltaMs"`
+	LatencyRatio   float64 `json:"latencyRatio"`
+	BlockNumber    uint64  `json:"blockNumber"`
+	// BlockLag is how many blocks the endpoint is behind the reference, negative when ahead
+	BlockLag int64 `json:"blockLag"`
+	// Passed is in how many of the rounds the endpoint worked, with --rounds
+	Passed int `json:"passed,omitempty"`
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <chainId|chainName> --reference <url>",
+	Short: "Compare public endpoints to a reference endpoint",
+	Long:  "Tests a reference endpoint, such as a paid provider, together with the public endpoints of the chain and reports the latency and block lag of each public endpoint relative to the reference, to tell whether the free ones are good enough. With --rounds, all endpoints are probed at once every --round-interval, on the interval boundaries of the clock, and their median latency and block lag are reported",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if referenceURL == "" {
+			return NewParameterErrorWithCmd("requires --reference (or CHAIN_RPC_REFERENCE)", cmd)
+		}
+		if compareRounds < 1 {
+			return NewParameterErrorWithCmd("rounds must be at least 1", cmd)
+		}
+		if roundInterval <= 0 {
+			return NewParameterErrorWithCmd("round-interval must be positive", cmd)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		rpcUrls := make([]string, 0, len(chainData.RPCs))
+		for _, url := range extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly) {
+			if url != referenceURL {
+				rpcUrls = append(rpcUrls, url)
+			}
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		tester.Timeout = compareTimeout
+		// Latencies are only comparable once connections are set up
+		tester.WarmUp = true
+		tester.Checks = append(tester.Checks, rpc.BlockCheck{})
+
+		// The reference is tested with the others, so that block numbers are taken at the same time
+		rounds := make([][]rpc.RPCResult, 0, compareRounds)
+		if compareRounds == 1 {
+			rounds = append(rounds, tester.TestRPCs(append([]string{referenceURL}, rpcUrls...), chainData.ChainID))
+		} else {
+			for round := 0; round < compareRounds; round++ {
+				waitRoundStart(roundInterval)
+				results := tester.TestRPCs(append([]string{referenceURL}, rpcUrls...), chainData.ChainID)
+				logger.Info("round finished", "round", round+1, "rounds", compareRounds, "working", len(results))
+				rounds = append(rounds, results)
+			}
+		}
+		for _, results := range rounds {
+			reportResults(results...)
+		}
+
+		comparisons := compareResults(referenceURL, rounds)
+		if comparisons == nil {
+			return fmt.Errorf("reference endpoint %s does not work for %s, try a longer --timeout", redactURL(referenceURL), chainData.Name)
+		}
+		if asJSON {
+			return printJSON(comparisons)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		passed := ""
+		if compareRounds > 1 {
+			fmt.Fprintln(w, "URL\tMEDIAN LATENCY\tVS REFERENCE\tBLOCK\tMEDIAN LAG\tPASSED")
+		} else {
+			fmt.Fprintln(w, "URL\tLATENCY\tVS REFERENCE\tBLOCK\tBLOCK LAG")
+		}
+		for _, c := range comparisons {
+			if compareRounds > 1 {
+				passed = fmt.Sprintf("\t%d/%d", c.Passed, compareRounds)
+			}
+			if c.Reference {
+				fmt.Fprintf(w, "%s\t%s\treference\t%s\t-%s\n", c.URL, formatLatency(msDuration(c.LatencyMs)), formatCount(c.BlockNumber), passed)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s (%sx)\t%s\t%s%s\n", c.URL, formatLatency(msDuration(c.LatencyMs)), formatLatencyDelta(msDuration(c.LatencyDeltaMs)),
+				formatDecimal(c.LatencyRatio, 1), formatCount(c.BlockNumber), formatCount(c.BlockLag), passed)
+		}
+		return w.Flush()
+	},
+}
+
+// waitRoundStart sleeps until the next interval boundary of the clock, so that every
+// endpoint of a round is probed in the same interval, and the rounds of runs on other
+// machines line up too
+func waitRoundStart(interval time.Duration) {
+	now := time.Now()
+	time.Sleep(now.Truncate(interval).Add(interval).Sub(now))
+}
+
+// compareResults measures the results of the rounds against the reference, the
+// reference first and the others from the fastest. Latencies and block lags are the
+// medians of the rounds the endpoints worked in, block lags only of the rounds the
+// reference worked in too. It returns nil if the reference never worked.
+func compareResults(referenceURL string, rounds [][]rpc.RPCResult) []comparison {
+	type samples struct {
+		latencies []time.Duration
+		lags      []int64
+		block     uint64
+	}
+	var urls []string
+	byURL := make(map[string]*samples)
+	for _, results := range rounds {
+		var reference *rpc.RPCResult
+		for i := range results {
+			if results[i].URL == referenceURL {
+				reference = &results[i]
+			}
+		}
+		for _, result := range results {
+			s, exists := byURL[result.URL]
+			if !exists {
+				s = &samples{}
+				byURL[result.URL] = s
+				urls = append(urls, result.URL)
+			}
+			s.latencies = append(s.latencies, result.Latency)
+			s.block = max(s.block, result.BlockNumber)
+			if reference != nil {
+				s.lags = append(s.lags, int64(reference.BlockNumber)-int64(result.BlockNumber))
+			}
+		}
+	}
+	reference, exists := byURL[referenceURL]
+	if !exists {
+		return nil
+	}
+	referenceLatency := time.Duration(median(reference.latencies))
+
+	others := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if url != referenceURL {
+			others = append(others, url)
+		}
+	}
+	latency := func(url string) time.Duration { return time.Duration(median(byURL[url].latencies)) }
+	sort.SliceStable(others, func(i, j int) bool { return latency(others[i]) < latency(others[j]) })
+
+	comparisons := []comparison{{
+		URL:          redactURL(referenceURL),
+		Reference:    true,
+		LatencyMs:    referenceLatency.Milliseconds(),
+		LatencyRatio: 1,
+		BlockNumber:  reference.block,
+		Passed:       passedRounds(reference.latencies, rounds),
+	}}
+	for _, url := range others {
+		s := byURL[url]
+		c := comparison{
+			URL:            url,
+			LatencyMs:      latency(url).Milliseconds(),
+			LatencyDeltaMs: (latency(url) - referenceLatency).Milliseconds(),
+			BlockNumber:    s.block,
+			BlockLag:       int64(math.Round(median(s.lags))),
+			Passed:         passedRounds(s.latencies, rounds),
+		}
+		if referenceLatency > 0 {
+			c.LatencyRatio = math.Round(float64(latency(url))/float64(referenceLatency)*100) / 100
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
+
+// passedRounds counts the rounds an endpoint worked in, left out of a single round
+func passedRounds(latencies []time.Duration, rounds [][]rpc.RPCResult) int {
+	if len(rounds) == 1 {
+		return 0
+	}
+	return len(latencies)
+}
+
+// redactURL keeps the scheme and host of the endpoint, as paid endpoints carry
+// their API key in the path, query or user info
+func redactURL(rpcURL string) string {
+	u, err := url.Parse(rpcURL)
+	if err != nil || u.Host == "" {
+		return "reference"
+	}
+	if u.Path == "" && u.RawQuery == "" && u.User == nil {
+		return rpcURL
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
+
+func init() {
+	compareCmd.Flags().StringVar(&referenceURL, "reference", os.Getenv("CHAIN_RPC_REFERENCE"), "endpoint to compare to, e.g. a paid provider (env CHAIN_RPC_REFERENCE, which keeps API keys out of the shell history)")
+	compareCmd.Flags().IntVar(&compareRounds, "rounds", 1, "probe all endpoints at once this many times and compare their medians")
+	durationVar(compareCmd.Flags(), &roundInterval, "round-interval", DEFAULT_ROUND_INTERVAL, "time between the starts of rounds, aligned on the clock")
+	compareCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	compareCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(compareCmd.Flags(), &compareTimeout, "timeout", "t", time.Second, "timeout for RPC testing")
+	compareCmd.Flags().BoolVar(&wsOnly, "wss", false, "compare only WebSocket RPC URLs")
+	compareCmd.Flags().BoolVar(&httpsOnly, "https", false, "compare only HTTPS RPC URLs")
+	compareCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	compareCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	addOutputFlags(compareCmd)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+var currencyCmd = &cobra.Command{
+	Use:   "currency <chainId|chainName>",
+	Short: "Print the native currency of a chain",
+	Long:  "Prints the name, symbol and decimals of the native currency of the chain, from the cached chain data",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(chainData.NativeCurrency)
+		}
+		fmt.Printf("Name:     %s\n", chainData.NativeCurrency.Name)
+		fmt.Printf("Symbol:   %s\n", chainData.NativeCurrency.Symbol)
+		fmt.Printf("Decimals: %d\n", chainData.NativeCurrency.Decimals)
+		return nil
+	},
+}
+
+func init() {
+	currencyCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	currencyCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(currencyCmd)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/discovery"
+
+	"github.com/spf13/cobra"
+)
+
+// DOCKER_DISCOVERY_TIMEOUT bounds listing the containers of the Docker daemon
+const DOCKER_DISCOVERY_TIMEOUT = 2 * time.Second
+
+var (
+	discoverDocker bool
+
+	dockerOnce sync.Once
+	dockerURLs []chain.RPC
+)
+
+// validateDocker checks --docker, whose candidates only count once their chain ID is verified
+func validateDocker(cmd *cobra.Command) error {
+	if discoverDocker && noTest {
+		return NewParameterErrorWithCmd("--docker cannot be combined with --no-test, discovered endpoints need testing", cmd)
+	}
+	return nil
+}
+
+// dockerRPCs returns the endpoints published by running Docker containers, listed once per run.
+// They are candidates of every chain, testing keeps the ones serving it.
+func dockerRPCs() []chain.RPC {
+	dockerOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DOCKER_DISCOVERY_TIMEOUT)
+		defer cancel()
+
+		containers, err := discovery.DockerContainers(ctx)
+		if err != nil {
+			warnf("Docker discovery failed: %v", err)
+			return
+		}
+		for _, container := range containers {
+			logger.Info("found container", "name", container.Name, "image", container.Image, "urls", container.URLs)
+			for _, url := range container.URLs {
+				dockerURLs = append(dockerURLs, chain.RPC{URL: url})
+			}
+		}
+	})
+	return dockerURLs
+}
+
+// withDockerRPCs puts the endpoints of Docker containers first, unless the chain lists them already
+func withDockerRPCs(rpcs []chain.RPC) []chain.RPC {
+	listed := make(map[string]bool, len(rpcs))
+	for _, rpc := range rpcs {
+		listed[rpc.URL] = true
+	}
+
+	candidates := make([]chain.RPC, 0, len(rpcs))
+	for _, rpc := range dockerRPCs() {
+		if !listed[rpc.URL] {
+			candidates = append(candidates, rpc)
+		}
+	}
+	return append(candidates, rpcs...)
+}
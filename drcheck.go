@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+const DEFAULT_DR_MAX_LAG = 5
+
+var (
+	drPrimary    string
+	drChain      string
+	drBackups    []string
+	drMaxLag     int64
+	drNamespaces []string
+	// drTimeout is the --timeout of dr-check, whose default differs from the root command's
+	drTimeout time.Duration
+)
+
+// namespaceMethods are the methods probed to tell whether an endpoint serves a JSON-RPC
+// namespace. Endpoints serving the eth namespace is what testing verifies already.
+var namespaceMethods = map[string]string{
+	"net":    "net_version",
+	"web3":   "web3_clientVersion",
+	"debug":  "debug_traceTransaction",
+	"trace":  "trace_block",
+	"txpool": "txpool_status",
+}
+
+// drEndpoint is the state of the primary or of a backup endpoint
+type drEndpoint struct {
+	URL         string `json:"url"`
+	Primary     bool   `json:"primary,omitempty"`
+	OK          bool   `json:"ok"`
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	// BlockLag is how many blocks the endpoint is behind the primary, unknown when the
+	// primary or the endpoint failed
+	BlockLag   *int64   `json:"blockLag,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Problem is why a backup cannot take over from the primary
+	Problem string `json:"problem,omitempty"`
+}
+
+// drReport is the outcome of dr-check, passed when every backup can take over
+type drReport struct {
+	Chain     string       `json:"chain"`
+	ChainID   uint64       `json:"chainId"`
+	Pass      bool         `json:"pass"`
+	Endpoints []drEndpoint `json:"endpoints"`
+}
+
+var drCheckCmd = &cobra.Command{
+	Use:   "dr-check --primary <url> --chain <chainId|chainName>",
+	Short: "Check that backup endpoints can take over from a primary endpoint",
+	Long:  "Tests a primary endpoint together with its backups, given with --backup or in the backups of the config file, and checks that every backup is healthy (serves the chain, is not syncing), at most --max-lag blocks behind the primary and serves the JSON-RPC namespaces the primary does. Prints a pass/fail report and exits non-zero unless every backup passes, for disaster recovery runbooks and scheduled jobs",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if drPrimary == "" {
+			return NewParameterErrorWithCmd("requires --primary", cmd)
+		}
+		if drChain == "" {
+			return NewParameterErrorWithCmd("requires --chain", cmd)
+		}
+		if drMaxLag < 0 {
+			return NewParameterErrorWithCmd("max-lag must not be negative", cmd)
+		}
+		if retries < 0 {
+			return NewParameterErrorWithCmd("retries must not be negative", cmd)
+		}
+		methods := make([]string, 0, len(drNamespaces))
+		for _, namespace := range drNamespaces {
+			method, known := namespaceMethods[namespace]
+			if !known {
+				return NewParameterErrorWithCmd(fmt.Sprintf("unknown namespace %q, expected some of: %s", namespace, strings.Join(knownNamespaces(), ", ")), cmd)
+			}
+			methods = append(methods, method)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(drChain)
+		if err != nil {
+			return err
+		}
+
+		backups := drBackups
+		if !cmd.Flags().Changed("backup") {
+			backups = splitList(os.Getenv("CHAIN_RPC_BACKUPS"))
+		}
+		if len(backups) == 0 {
+			backups = cfg.Backups[chainData.ChainID]
+		}
+		if len(backups) == 0 {
+			return NewParameterErrorWithCmd(fmt.Sprintf("no backup endpoints of %s, set --backup or the backups of the config file", chainData.Name), cmd)
+		}
+
+		tester := rpc.NewTester(drTimeout)
+		tester.Retries = retries
+		tester.Checks = append(tester.Checks, rpc.SyncingCheck{}, rpc.BlockCheck{}, rpc.MethodsCheck{Methods: methods, Optional: true})
+		// Backups are tested with the primary, so that block numbers are taken at the same time
+		results := tester.CheckRPCs(append([]string{drPrimary}, backups...), chainData.ChainID)
+		reportResults(results...)
+
+		report := checkBackups(results[0], results[1:])
+		report.Chain = chainData.Name
+		report.ChainID = chainData.ChainID
+		if asJSON {
+			if err := printJSON(report); err != nil {
+				return err
+			}
+		} else if err := printDRReport(report); err != nil {
+			return err
+		}
+
+		if results[0].Err != "" {
+			return fmt.Errorf("primary endpoint failed, backups could not be compared to it: %s", results[0].Err)
+		}
+		if !report.Pass {
+			failed := 0
+			for _, endpoint := range report.Endpoints {
+				if !endpoint.Primary && !endpoint.OK {
+					failed++
+				}
+			}
+			return fmt.Errorf("%d of %d backup endpoints cannot take over from the primary", failed, len(backups))
+		}
+		return nil
+	},
+}
+
+// checkBackups tells whether each backup can take over from the primary. Block lag
+// and namespaces are only compared when the primary works.
+func checkBackups(primary rpc.RPCResult, backups []rpc.RPCResult) drReport {
+	report := drReport{Pass: primary.Err == ""}
+
+	primaryEndpoint := drEndpoint{URL: redactURL(primary.URL), Primary: true, OK: primary.Err == "", Problem: primary.Err}
+	if primary.Err == "" {
+		primaryEndpoint.BlockNumber = primary.BlockNumber
+		primaryEndpoint.Namespaces = servedNamespaces(primary)
+	}
+	report.Endpoints = append(report.Endpoints, primaryEndpoint)
+
+	for _, backup := range backups {
+		endpoint := drEndpoint{URL: redactURL(backup.URL), OK: true}
+		if backup.Err != "" {
+			endpoint.OK = false
+			endpoint.Problem = backup.Err
+			report.Pass = false
+			report.Endpoints = append(report.Endpoints, endpoint)
+			continue
+		}
+		endpoint.BlockNumber = backup.BlockNumber
+		endpoint.Namespaces = servedNamespaces(backup)
+		if primary.Err == "" {
+			lag := int64(primary.BlockNumber) - int64(backup.BlockNumber)
+			endpoint.BlockLag = &lag
+
+			var missing []string
+			for _, namespace := range primaryEndpoint.Namespaces {
+				if !slices.Contains(endpoint.Namespaces, namespace) {
+					missing = append(missing, namespace)
+				}
+			}
+			switch {
+			case lag > drMaxLag:
+				endpoint.OK = false
+				endpoint.Problem = fmt.Sprintf("%s blocks behind > %s", formatCount(lag), formatCount(drMaxLag))
+			case len(missing) > 0:
+				endpoint.OK = false
+				endpoint.Problem = "missing namespaces: " + strings.Join(missing, ", ")
+			}
+		}
+		report.Pass = report.Pass && endpoint.OK
+		report.Endpoints = append(report.Endpoints, endpoint)
+	}
+	return report
+}
+
+// servedNamespaces lists the namespaces whose method the endpoint supports
+func servedNamespaces(result rpc.RPCResult) []string {
+	namespaces := []string{"eth"}
+	for _, namespace := range knownNamespaces() {
+		if result.Methods[namespaceMethods[namespace]] {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+func knownNamespaces() []string {
+	namespaces := make([]string, 0, len(namespaceMethods))
+	for namespace := range namespaceMethods {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+func printDRReport(report drReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tROLE\tSTATUS\tBLOCK\tLAG\tNAMESPACES")
+	for _, endpoint := range report.Endpoints {
+		role := "backup"
+		if endpoint.Primary {
+			role = "primary"
+		}
+		status := "pass"
+		switch {
+		case endpoint.Primary && !endpoint.OK:
+			status = "failed: " + endpoint.Problem
+		case endpoint.Primary:
+			status = "-"
+		case !endpoint.OK:
+			status = "FAIL: " + endpoint.Problem
+		}
+		block, lag := "-", "-"
+		if endpoint.BlockNumber > 0 {
+			block = formatCount(endpoint.BlockNumber)
+		}
+		if endpoint.BlockLag != nil {
+			lag = formatCount(*endpoint.BlockLag)
+		}
+		namespaces := strings.Join(endpoint.Namespaces, ",")
+		if namespaces == "" {
+			namespaces = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", endpoint.URL, role, status, block, lag, namespaces)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if report.Pass {
+		fmt.Printf("PASS: every backup of %s can take over from the primary\n", report.Chain)
+	} else {
+		fmt.Printf("FAIL: not every backup of %s can take over from the primary\n", report.Chain)
+	}
+	return nil
+}
+
+func init() {
+	drCheckCmd.Flags().StringVar(&drPrimary, "primary", "", "endpoint the backups stand in for")
+	drCheckCmd.Flags().StringVar(&drChain, "chain", "", "chain ID or name the endpoints serve")
+	drCheckCmd.Flags().StringArrayVar(&drBackups, "backup", nil, "backup endpoint (repeatable; env CHAIN_RPC_BACKUPS, comma-separated; default: the backups of the chain in the config file)")
+	drCheckCmd.Flags().Int64Var(&drMaxLag, "max-lag", DEFAULT_DR_MAX_LAG, "most blocks a backup may be behind the primary")
+	drCheckCmd.Flags().StringSliceVar(&drNamespaces, "namespaces", knownNamespaces(), "JSON-RPC namespaces backups must serve when the primary does, besides eth")
+	drCheckCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	drCheckCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(drCheckCmd.Flags(), &drTimeout, "timeout", "t", 5*time.Second, "timeout for testing every endpoint")
+	drCheckCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	addOutputFlags(drCheckCmd)
+}
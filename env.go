@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/dotenv"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	envOut    string
+	envPrefix string
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env <chainId|chainName>",
+	Short: "Write a working RPC endpoint to a dotenv file",
+	Long:  "Finds a working endpoint of the chain and sets RPC_URL and CHAIN_ID in a dotenv file, creating it if needed. Other keys, comments and ordering are left as they were.",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if envPrefix != "" && !shellNamePattern.MatchString(envPrefix) {
+			return NewParameterErrorWithCmd(fmt.Sprintf("invalid --prefix %q: letters, digits and underscores only, not starting with a digit", envPrefix), cmd)
+		}
+
+		// Fail on an unreadable file before testing endpoints
+		file, err := dotenv.Read(envOut)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
+		if len(rpcUrls) == 0 {
+			return noRPCsError(chainData.RPCs)
+		}
+
+		strategy, err := selectionStrategy(cmd)
+		if err != nil {
+			return err
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		if _, ok := rpc.UnwrapStrategy(strategy).(rpc.FastestStrategy); ok {
+			tester.WarmUp = true
+		}
+		if rpc.UsesScores(strategy) {
+			enableScoring(tester, chainData)
+		}
+
+		workingRPC, err := rpc.NewSelector(tester, strategy).SelectResult(rpcUrls, chainData.ChainID)
+		if err != nil {
+			return err
+		}
+		reportResults(workingRPC)
+		saveWorkingRPCs(chainData.ChainID, nil, []string{workingRPC.URL})
+		warnInsecure(workingRPC.URL)
+
+		keys := []string{envKey("RPC_URL"), envKey("CHAIN_ID")}
+		file.Set(keys[0], workingRPC.URL)
+		file.Set(keys[1], strconv.FormatUint(chainData.ChainID, 10))
+		if err := file.Write(envOut); err != nil {
+			return err
+		}
+
+		notef("Set %s in %s", strings.Join(keys, " and "), envOut)
+		return nil
+	},
+}
+
+func envKey(name string) string {
+	if envPrefix == "" {
+		return name
+	}
+	return envPrefix + "_" + name
+}
+
+func init() {
+	envCmd.Flags().StringVar(&envOut, "out", ".env", "dotenv file to write or update")
+	envCmd.Flags().StringVar(&envPrefix, "prefix", "", "prefix of the key names, PREFIX_RPC_URL and PREFIX_CHAIN_ID")
+	envCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	envCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(envCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	envCmd.Flags().BoolVar(&wsOnly, "wss", false, "use only WebSocket RPC URLs")
+	envCmd.Flags().BoolVar(&httpsOnly, "https", false, "use only HTTPS RPC URLs")
+	envCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	envCmd.Flags().StringVar(&strategyName, "strategy", "random", fmt.Sprintf("how to pick among working endpoints: %s", strings.Join(rpc.StrategyNames(), ", ")))
+	envCmd.Flags().BoolVar(&fastest, "fastest", false, "shorthand for --strategy fastest")
+	envCmd.Flags().BoolVar(&discoverDocker, "docker", false, "include running Docker containers publishing common RPC ports (8545, 8546, ...) as candidate endpoints")
+	envCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+}
@@ -1,11 +1,72 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"text/tabwriter"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
 
 	"github.com/spf13/cobra"
 )
 
+// Exit codes, for scripts to tell failures apart
+const (
+	EXIT_ERROR           = 1
+	EXIT_PARAMETER       = 2
+	EXIT_CHAIN_NOT_FOUND = 3
+	EXIT_NO_ENDPOINTS    = 4
+	EXIT_CACHE           = 5
+)
+
+// ExitCodeError is an error that ends the process with its exit code
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitCodeError{Code: code, Err: err}
+}
+
+// exitCode maps an error to the exit code of the process: parameter errors, unknown
+// chains, chains without working endpoints and failures to load the chain data or to
+// reach the network have their own
+func exitCode(err error) int {
+	var (
+		codeErr  *ExitCodeError
+		paramErr *ParameterError
+		netErr   net.Error
+	)
+	switch {
+	case errors.As(err, &codeErr):
+		return codeErr.Code
+	case errors.As(err, &paramErr), errors.Is(err, chain.ErrAmbiguousName):
+		return EXIT_PARAMETER
+	case errors.Is(err, chain.ErrChainNotFound):
+		return EXIT_CHAIN_NOT_FOUND
+	case errors.Is(err, rpc.ErrNoRPCsFound):
+		return EXIT_NO_ENDPOINTS
+	case errors.Is(err, chain.ErrCacheMiss), errors.Is(err, chain.ErrCacheStale), errors.As(err, &netErr):
+		return EXIT_CACHE
+	}
+	return EXIT_ERROR
+}
+
 // Custom error type for parameter errors
 type ParameterError struct {
 	message string
@@ -40,9 +101,34 @@ func exactArgsWithParameterError(n int) cobra.PositionalArgs {
 	}
 }
 
+// Custom argument validator allowing at most n args that returns ParameterError
+func maximumArgsWithParameterError(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) > n {
+			return NewParameterErrorWithCmd(fmt.Sprintf("accepts at most %d arg(s), received %d", n, len(args)), cmd)
+		}
+		return nil
+	}
+}
+
+// Custom argument validator requiring at least n args that returns ParameterError
+func minimumArgsWithParameterError(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) < n {
+			return NewParameterErrorWithCmd(fmt.Sprintf("requires at least %d arg(s), received %d", n, len(args)), cmd)
+		}
+		return nil
+	}
+}
+
 // Format error message with red "Error:" prefix
 func formatError(err error) string {
 	errMsg := err.Error()
+	// Why each endpoint failed is only worth the screen space when asked for
+	var failed *rpc.EndpointsFailedError
+	if errors.As(err, &failed) && (verbose || explainFailed) {
+		errMsg += "\n" + failureTable(failed.Failures)
+	}
 
 	if len(errMsg) >= 6 && errMsg[:6] == "Error:" {
 		return colorRed + "Error:" + colorReset + errMsg[6:]
@@ -50,3 +136,15 @@ func formatError(err error) string {
 
 	return colorRed + "Error:" + colorReset + " " + errMsg
 }
+
+// failureTable lists the cause of each endpoint failure, e.g. DNS, TLS or a wrong chain ID
+func failureTable(failures []rpc.RPCResult) string {
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tCAUSE\tERROR")
+	for _, failure := range failures {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", redactURL(failure.URL), failure.Cause, failure.Err)
+	}
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
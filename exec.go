@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var execAttempts int
+
+var execCmd = &cobra.Command{
+	Use:   "exec <chainId|chainName> -- <command> [args...]",
+	Short: "Run a command with a working RPC endpoint in its environment",
+	Long:  "Finds a working endpoint of the chain and runs the command with ETH_RPC_URL, CHAIN_ID and CHAIN_NAME set in its environment. With --attempts, a failing command is run again with another working endpoint.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+			return NewParameterErrorWithCmd("expects a chain, then -- and the command to run", cmd)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if execAttempts < 1 {
+			return NewParameterErrorWithCmd("attempts must be at least 1", cmd)
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		if preferLocal {
+			if url := localRPC(chainData.ChainID); url != "" {
+				return runWithEndpoint(args[1:], chainData, url)
+			}
+		}
+
+		rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
+		if len(rpcUrls) == 0 {
+			return noRPCsError(chainData.RPCs)
+		}
+
+		strategy, err := selectionStrategy(cmd)
+		if err != nil {
+			return err
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		if _, ok := rpc.UnwrapStrategy(strategy).(rpc.FastestStrategy); ok {
+			tester.WarmUp = true
+		}
+		if rpc.UsesScores(strategy) {
+			enableScoring(tester, chainData)
+		}
+
+		results := tester.TestRPCs(rpcUrls, chainData.ChainID)
+		reportResults(results...)
+		working := make([]string, 0, len(results))
+		for _, result := range results {
+			working = append(working, result.URL)
+		}
+		saveWorkingRPCs(chainData.ChainID, rpcUrls, working)
+		if len(results) == 0 {
+			return rpc.ErrNoRPCsFound
+		}
+
+		for attempt := 1; ; attempt++ {
+			picked := strategy.Pick(results)
+			results = removeResult(results, picked.URL)
+			warnInsecure(picked.URL)
+
+			err := runWithEndpoint(args[1:], chainData, picked.URL)
+			var exitErr *childExitError
+			if !errors.As(err, &exitErr) || attempt == execAttempts || len(results) == 0 {
+				return err
+			}
+			warnf("%s exited with code %d on %s, retrying with another endpoint", args[1], exitErr.code, templateURL(picked.URL))
+		}
+	},
+}
+
+// childExitError makes chain-rpc exit with the exit code of the command it ran
+type childExitError struct {
+	code int
+}
+
+func (e *childExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.code)
+}
+
+// runWithEndpoint runs the command with the endpoint of the chain exported in its environment.
+// Interrupts are left to the command, which shares the terminal.
+func runWithEndpoint(command []string, chainData *chain.ChainData, url string) error {
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.Env = append(os.Environ(),
+		"ETH_RPC_URL="+url,
+		"CHAIN_ID="+strconv.FormatUint(chainData.ChainID, 10),
+		"CHAIN_NAME="+chainData.Name,
+	)
+
+	signal.Ignore(os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+
+	err := child.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// A command killed by a signal has no exit code
+		return &childExitError{code: max(exitErr.ExitCode(), 1)}
+	}
+	return err
+}
+
+func removeResult(results []rpc.RPCResult, url string) []rpc.RPCResult {
+	remaining := make([]rpc.RPCResult, 0, len(results))
+	for _, result := range results {
+		if result.URL != url {
+			remaining = append(remaining, result)
+		}
+	}
+	return remaining
+}
+
+func init() {
+	execCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	execCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(execCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	execCmd.Flags().BoolVar(&wsOnly, "wss", false, "use only WebSocket RPC URLs")
+	execCmd.Flags().BoolVar(&httpsOnly, "https", false, "use only HTTPS RPC URLs")
+	execCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	execCmd.Flags().StringVar(&strategyName, "strategy", "random", fmt.Sprintf("how to pick among working endpoints: %s", strings.Join(rpc.StrategyNames(), ", ")))
+	execCmd.Flags().BoolVar(&fastest, "fastest", false, "shorthand for --strategy fastest")
+	execCmd.Flags().BoolVar(&discoverDocker, "docker", false, "include running Docker containers publishing common RPC ports (8545, 8546, ...) as candidate endpoints")
+	execCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	execCmd.Flags().BoolVar(&preferLocal, "prefer-local", false, "use a local development node on 127.0.0.1:8545 or :8546 serving the chain, if any, before trying public endpoints")
+	execCmd.Flags().IntVar(&execAttempts, "attempts", 1, "run the command again with another working endpoint while it fails, up to this many times in all")
+}
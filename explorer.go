@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+var explorerCmd = &cobra.Command{
+	Use:   "explorer <chainId|chainName> [txHash|address|block]",
+	Short: "Print the block explorer URL of a chain",
+	Long:  "Prints the URL of the primary block explorer of the chain, or the link to a transaction hash (0x and 64 hex digits), an address (0x and 40 hex digits) or a block number on it",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 || len(args) > 2 {
+			return NewParameterErrorWithCmd(fmt.Sprintf("accepts 1 or 2 arg(s), received %d", len(args)), cmd)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		explorer, exists := chainData.PrimaryExplorer()
+		if !exists {
+			return fmt.Errorf("no block explorer known for %s", chainData.Name)
+		}
+		if len(args) == 1 {
+			fmt.Println(explorer.URL)
+			return nil
+		}
+
+		link, err := chain.ExplorerLink(explorer, args[1])
+		if err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+		if !explorer.FollowsEIP3091() {
+			warnf("%s does not declare EIP-3091 links, the link may not work", explorer.Name)
+		}
+		fmt.Println(link)
+		return nil
+	},
+}
+
+func init() {
+	explorerCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	explorerCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportVars   bool
+	exportPrefix string
+)
+
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateExport checks --export and --prefix, which print the endpoints of a single chain as shell code
+func validateExport(cmd *cobra.Command, identifiers []string) error {
+	if !exportVars {
+		if cmd.Flags().Changed("prefix") {
+			return NewParameterErrorWithCmd("--prefix needs --export", cmd)
+		}
+		return nil
+	}
+	if len(identifiers) > 1 {
+		return NewParameterErrorWithCmd("--export takes a single chain", cmd)
+	}
+	if asJSON, err := isJSONOutput(cmd); err != nil || asJSON {
+		return NewParameterErrorWithCmd("--export cannot be combined with JSON output", cmd)
+	}
+	if exportPrefix != "" && !shellNamePattern.MatchString(exportPrefix) {
+		return NewParameterErrorWithCmd(fmt.Sprintf("invalid --prefix %q: letters, digits and underscores only, not starting with a digit", exportPrefix), cmd)
+	}
+	return nil
+}
+
+// printPicked prints the endpoint the strategy picks out of the results, or with --export
+// the HTTP and WebSocket endpoints it pairs
+func printPicked(strategy rpc.Strategy, results []rpc.RPCResult, asJSON bool) error {
+	if exportVars {
+		return printExport(rpc.PickPair(strategy, results))
+	}
+	return printPickedURL(strategy.Pick(results).URL, asJSON)
+}
+
+// printExport prints shell statements exporting the endpoints, for eval
+func printExport(httpResult, wsResult rpc.RPCResult) error {
+	if httpResult.URL == "" && wsResult.URL == "" {
+		return rpc.ErrNoRPCsFound
+	}
+	if httpResult.URL != "" {
+		warnInsecure(httpResult.URL)
+		fmt.Printf("export %s=%s\n", exportName("RPC_URL"), shellQuote(httpResult.URL))
+	}
+	if wsResult.URL != "" {
+		warnInsecure(wsResult.URL)
+		fmt.Printf("export %s=%s\n", exportName("WS_URL"), shellQuote(wsResult.URL))
+	}
+	return nil
+}
+
+func exportName(suffix string) string {
+	if exportPrefix == "" {
+		return suffix
+	}
+	return exportPrefix + "_" + suffix
+}
+
+// shellQuote quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// urlResults turns untested endpoints into results for strategies to pick from
+func urlResults(urls []string) []rpc.RPCResult {
+	results := make([]rpc.RPCResult, 0, len(urls))
+	for _, url := range urls {
+		results = append(results, rpc.RPCResult{URL: url})
+	}
+	return results
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// numberFormat holds the separators numbers are printed with in tables
+type numberFormat struct {
+	thousands string
+	decimal   string
+}
+
+// localeFormat is the number format of the locale of the environment
+var localeFormat = detectNumberFormat()
+
+// detectNumberFormat reads the locale like C programs do, from LC_ALL, LC_NUMERIC and
+// then LANG. Languages not known to group otherwise, and the C locale, print numbers
+// the English way.
+func detectNumberFormat() numberFormat {
+	locale := ""
+	for _, key := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if locale = os.Getenv(key); locale != "" {
+			break
+		}
+	}
+	language, _, _ := strings.Cut(strings.ToLower(locale), ".")
+	language, _, _ = strings.Cut(language, "@")
+	language, region, _ := strings.Cut(strings.ReplaceAll(language, "-", "_"), "_")
+
+	switch {
+	case region == "ch" && (language == "de" || language == "it" || language == "fr"):
+		return numberFormat{thousands: "'", decimal: "."}
+	case strings.Contains(" de es it nl pt id tr da el ro hr sl sr vi ", " "+language+" "):
+		return numberFormat{thousands: ".", decimal: ","}
+	case strings.Contains(" fr ru uk pl cs sk sv nb nn no fi hu bg et lv lt ", " "+language+" "):
+		// A no-break space, so that numbers are never split
+		return numberFormat{thousands: " ", decimal: ","}
+	default:
+		return numberFormat{thousands: ",", decimal: "."}
+	}
+}
+
+// formatCount prints a quantity with the thousands separators of the locale
+func formatCount[T int | int64 | uint64](n T) string {
+	if n < 0 {
+		return "-" + groupDigits(strconv.FormatUint(uint64(-n), 10))
+	}
+	return groupDigits(strconv.FormatUint(uint64(n), 10))
+}
+
+// formatDecimal prints a number with the given number of decimals and the separators
+// of the locale
+func formatDecimal(f float64, decimals int) string {
+	number := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	integer, fraction, _ := strings.Cut(number, ".")
+	sign := ""
+	if f < 0 && strings.Trim(number, "0.") != "" {
+		sign = "-"
+	}
+	if fraction == "" {
+		return sign + groupDigits(integer)
+	}
+	return sign + groupDigits(integer) + localeFormat.decimal + fraction
+}
+
+func groupDigits(digits string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(localeFormat.thousands)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// formatLatency prints latencies in milliseconds below a second, in seconds with a
+// decimal below a minute, and like formatDuration above
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "-" + formatLatency(-d)
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return formatDecimal(d.Seconds(), 1) + "s"
+	default:
+		return formatDuration(d)
+	}
+}
+
+// formatLatencyDelta prints the difference between two latencies with its sign
+func formatLatencyDelta(d time.Duration) string {
+	if d < 0 {
+		return formatLatency(d)
+	}
+	return "+" + formatLatency(d)
+}
+
+// msDuration converts the milliseconds of JSON outputs back to a duration
+func msDuration(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// durationValue is a duration flag. It takes Go durations like 1.5s, 750ms and 2m,
+// whole or fractional days like 7d, never negative ones, and prints days in days.
+type durationValue time.Duration
+
+func (d *durationValue) Set(value string) error {
+	parsed, err := parseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(parsed)
+	return nil
+}
+
+func (d *durationValue) String() string {
+	duration := time.Duration(*d)
+	const day = 24 * time.Hour
+	if duration == 0 {
+		// Like pflag, so that zero defaults are not printed in usages
+		return "0"
+	}
+	if duration < day {
+		return duration.String()
+	}
+	days := duration / day
+	if rest := duration - days*day; rest > 0 {
+		return fmt.Sprintf("%dd%s", days, rest)
+	}
+	return fmt.Sprintf("%dd", days)
+}
+
+func (d *durationValue) Type() string {
+	return "duration"
+}
+
+// parseDuration parses the durations of flags, see durationValue
+func parseDuration(value string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration %q, use e.g. 1.5s, 750ms, 2m or 7d", value)
+	rest := strings.TrimSpace(value)
+	if rest == "" {
+		return 0, invalid
+	}
+
+	var duration time.Duration
+	if days, after, found := strings.Cut(rest, "d"); found {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return 0, invalid
+		}
+		duration = time.Duration(n * float64(24*time.Hour))
+		rest = after
+	}
+	if rest != "" {
+		parsed, err := time.ParseDuration(rest)
+		if err != nil {
+			if _, err := strconv.ParseFloat(rest, 64); err == nil {
+				return 0, fmt.Errorf("invalid duration %q: missing unit, use e.g. %sms or %ss", value, rest, rest)
+			}
+			return 0, invalid
+		}
+		if duration != 0 && parsed < 0 {
+			return 0, invalid
+		}
+		duration += parsed
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("invalid duration %q: must not be negative", value)
+	}
+	return duration, nil
+}
+
+// durationVarP defines a duration flag, see durationValue
+func durationVarP(flags *pflag.FlagSet, p *time.Duration, name, shorthand string, value time.Duration, usage string) {
+	*p = value
+	flags.VarP((*durationValue)(p), name, shorthand, usage)
+}
+
+// durationVar defines a duration flag without shorthand, see durationValue
+func durationVar(flags *pflag.FlagSet, p *time.Duration, name string, value time.Duration, usage string) {
+	durationVarP(flags, p, name, "", value, usage)
+}
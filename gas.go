@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+// FEE_PERCENTILES are the priority fee percentiles gas summaries are made of
+var FEE_PERCENTILES = []float64{10, 50, 90}
+
+var gasBlocks int
+
+// gasSummary sums up the fees of a chain, amounts in wei
+type gasSummary struct {
+	GasPrice *big.Int `json:"gasPrice"`
+	// The fields below are left out on chains without EIP-1559
+	BaseFee *big.Int `json:"baseFee,omitempty"`
+	// PriorityFee maps the percentiles to the median of their priority fees over the blocks
+	PriorityFee map[string]*big.Int `json:"priorityFee,omitempty"`
+	// MaxFeePerGas is twice the base fee plus the median priority fee, enough for a few full blocks
+	MaxFeePerGas *big.Int `json:"maxFeePerGas,omitempty"`
+	GasUsedRatio float64  `json:"gasUsedRatio,omitempty"`
+	Blocks       int      `json:"blocks,omitempty"`
+}
+
+var gasCmd = &cobra.Command{
+	Use:   "gas <chainId|chainName>",
+	Short: "Print the gas price and fees of a chain",
+	Long:  "Finds a working endpoint of the chain and prints the gas price from eth_gasPrice and, on chains with EIP-1559, a summary of the fees of the latest blocks from eth_feeHistory: the base fee of the next block, priority fee percentiles and a suggested max fee",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if gasBlocks < 1 || gasBlocks > 1024 {
+			return NewParameterErrorWithCmd("--blocks must be between 1 and 1024", cmd)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		var gasPrice *big.Int
+		var feeHistory *rpc.FeeHistory
+		err = withWorkingEndpoint(cmd, args[0], func(ctx context.Context, rpcURL string) error {
+			var err error
+			if gasPrice, err = rpc.GasPrice(ctx, rpcURL); err != nil {
+				return err
+			}
+			// Chains without EIP-1559 do not know eth_feeHistory
+			if feeHistory, err = rpc.GetFeeHistory(ctx, rpcURL, gasBlocks, FEE_PERCENTILES); err != nil {
+				logger.Info("no fee history", "error", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		summary := summarizeGas(gasPrice, feeHistory)
+		if asJSON {
+			return printJSON(summary)
+		}
+
+		fmt.Printf("Gas price:     %s\n", formatGwei(gasPrice))
+		if summary.BaseFee == nil {
+			return nil
+		}
+		fmt.Printf("Base fee:      %s (next block)\n", formatGwei(summary.BaseFee))
+		for _, percentile := range FEE_PERCENTILES {
+			label := fmt.Sprintf("p%g", percentile)
+			fmt.Printf("Priority fee:  %s (%s)\n", formatGwei(summary.PriorityFee[label]), label)
+		}
+		fmt.Printf("Max fee:       %s (suggested)\n", formatGwei(summary.MaxFeePerGas))
+		fmt.Printf("Gas used:      %.1f%% of the limit over %d blocks\n", 100*summary.GasUsedRatio, summary.Blocks)
+		return nil
+	},
+}
+
+// summarizeGas sums up the gas price and the fee history, if any
+func summarizeGas(gasPrice *big.Int, feeHistory *rpc.FeeHistory) gasSummary {
+	summary := gasSummary{GasPrice: gasPrice}
+	if feeHistory == nil || len(feeHistory.BaseFeePerGas) == 0 {
+		return summary
+	}
+
+	baseFee := feeHistory.BaseFeePerGas[len(feeHistory.BaseFeePerGas)-1]
+	summary.BaseFee = baseFee
+	summary.Blocks = len(feeHistory.GasUsedRatio)
+	for _, ratio := range feeHistory.GasUsedRatio {
+		summary.GasUsedRatio += ratio / float64(summary.Blocks)
+	}
+
+	summary.PriorityFee = make(map[string]*big.Int, len(FEE_PERCENTILES))
+	medianFee := new(big.Int)
+	for i, percentile := range FEE_PERCENTILES {
+		fees := make([]*big.Int, 0, len(feeHistory.Reward))
+		for _, rewards := range feeHistory.Reward {
+			if i < len(rewards) {
+				fees = append(fees, rewards[i])
+			}
+		}
+		fee := medianWei(fees)
+		summary.PriorityFee[fmt.Sprintf("p%g", percentile)] = fee
+		if percentile == 50 {
+			medianFee = fee
+		}
+	}
+
+	maxFee := new(big.Int).Mul(baseFee, big.NewInt(2))
+	summary.MaxFeePerGas = maxFee.Add(maxFee, medianFee)
+	return summary
+}
+
+func medianWei(values []*big.Int) *big.Int {
+	if len(values) == 0 {
+		return new(big.Int)
+	}
+	sorted := append([]*big.Int(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	return sorted[len(sorted)/2]
+}
+
+func init() {
+	gasCmd.Flags().IntVar(&gasBlocks, "blocks", 20, "number of latest blocks the fees are summed up over")
+	addRequestFlags(gasCmd)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/snippet"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	goFileOut     string
+	goFilePackage string
+	goFileVar     string
+	goFileMax     int
+	// goFileTimeout is the --timeout of export go, whose default differs from the root command's
+	goFileTimeout time.Duration
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export verified endpoints as source code",
+	Long:  "Commands to generate source files with endpoints verified by chain-rpc, for projects to vendor as a fallback list",
+}
+
+var exportGoCmd = &cobra.Command{
+	Use:   "go <chainId|chainName>...",
+	Short: "Generate a Go file with verified endpoints of chains",
+	Long:  "Tests the endpoints of the chains and generates a Go source file with a map of chain IDs to the working endpoints, fastest first, and the time they were verified. No file is written when a chain has no working endpoint.",
+	Args:  minimumArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if goFileMax < 0 {
+			return NewParameterErrorWithCmd("max must not be negative", cmd)
+		}
+		// Check the names before testing, rather than after
+		if _, err := snippet.RenderGo(snippet.GoFile{Package: goFilePackage, Var: goFileVar}); err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+
+		chains, err := getChainsData(args)
+		if err != nil {
+			return err
+		}
+
+		toTest := make([]rpc.ChainURLs, 0, len(chains))
+		for _, chainData := range chains {
+			rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
+			if len(rpcUrls) == 0 {
+				return fmt.Errorf("%s: %w", chainData.Name, noRPCsError(chainData.RPCs))
+			}
+			toTest = append(toTest, rpc.ChainURLs{ChainID: chainData.ChainID, URLs: rpcUrls})
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		tester.Timeout = goFileTimeout
+		// Latencies order the endpoints, they are only comparable once connections are set up
+		tester.WarmUp = true
+		results := tester.TestChains(toTest)
+
+		file := snippet.GoFile{Package: goFilePackage, Var: goFileVar, GeneratedAt: time.Now()}
+		var failed []string
+		for _, tested := range toTest {
+			chainResults := results[tested.ChainID]
+			reportResults(chainResults...)
+			working := make([]string, 0, len(chainResults))
+			for _, result := range chainResults {
+				working = append(working, result.URL)
+			}
+			saveWorkingRPCs(tested.ChainID, tested.URLs, working)
+
+			chainData := chainByID(chains, tested.ChainID)
+			if len(chainResults) == 0 {
+				failed = append(failed, chainData.Name)
+				continue
+			}
+
+			sort.SliceStable(chainResults, func(i, j int) bool {
+				return chainResults[i].Latency < chainResults[j].Latency
+			})
+			if goFileMax > 0 && len(chainResults) > goFileMax {
+				chainResults = chainResults[:goFileMax]
+			}
+			urls := make([]string, 0, len(chainResults))
+			for _, result := range chainResults {
+				urls = append(urls, result.URL)
+			}
+			file.Chains = append(file.Chains, snippet.GoChain{ID: chainData.ChainID, Name: chainData.Name, URLs: urls})
+		}
+		if len(failed) > 0 {
+			return withExitCode(EXIT_NO_ENDPOINTS, fmt.Errorf("no working endpoints for %s, no file written", strings.Join(failed, ", ")))
+		}
+
+		source, err := snippet.RenderGo(file)
+		if err != nil {
+			return err
+		}
+		if goFileOut == "" {
+			_, err := os.Stdout.Write(source)
+			return err
+		}
+		if err := os.WriteFile(goFileOut, source, 0644); err != nil {
+			return fmt.Errorf("failed to write Go file: %v", err)
+		}
+		notef("Exported the endpoints of %d chains to %s", len(file.Chains), goFileOut)
+		return nil
+	},
+}
+
+func chainByID(chains []*chain.ChainData, chainID uint64) *chain.ChainData {
+	for _, chainData := range chains {
+		if chainData.ChainID == chainID {
+			return chainData
+		}
+	}
+	return nil
+}
+
+func init() {
+	exportGoCmd.Flags().StringVar(&goFileOut, "out", "", "Go file to write (default: stdout)")
+	exportGoCmd.Flags().StringVar(&goFilePackage, "package", "fallback", "package of the Go file")
+	exportGoCmd.Flags().StringVar(&goFileVar, "var", "RPCs", "name of the map of endpoints by chain ID")
+	exportGoCmd.Flags().IntVar(&goFileMax, "max", 0, "keep at most this many endpoints per chain, the fastest (0: all)")
+	exportGoCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	exportGoCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(exportGoCmd.Flags(), &goFileTimeout, "timeout", "t", time.Second, "timeout for RPC testing")
+	exportGoCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	exportGoCmd.Flags().BoolVar(&wsOnly, "wss", false, "export only WebSocket RPC URLs")
+	exportGoCmd.Flags().BoolVar(&httpsOnly, "https", false, "export only HTTPS RPC URLs")
+	exportGoCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	exportCmd.AddCommand(exportGoCmd)
+}
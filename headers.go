@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var requestHeaders []string
+
+// applyHeaders makes requests carry the headers of --header, to every endpoint, and
+// the headers of the config file rules, to the endpoints of their hosts
+func applyHeaders(cmd *cobra.Command) error {
+	var builders []rpc.RequestBuilder
+	if len(requestHeaders) > 0 {
+		header := http.Header{}
+		for _, h := range requestHeaders {
+			name, value, err := rpc.ParseHeader(h)
+			if err != nil {
+				return NewParameterErrorWithCmd(err.Error(), cmd)
+			}
+			header.Add(name, value)
+		}
+		builders = append(builders, rpc.StaticHeaders(header))
+	}
+
+	for _, rule := range cfg.Headers {
+		if strings.TrimSpace(rule.Host) == "" {
+			return fmt.Errorf("invalid config: header rule without host")
+		}
+		header := http.Header{}
+		for name, value := range rule.Headers {
+			value, complete := rpc.SubstitutePlaceholders(value, os.LookupEnv)
+			if !complete {
+				return fmt.Errorf("header %s for %s references an unset environment variable", name, rule.Host)
+			}
+			header.Set(name, value)
+		}
+		builders = append(builders, rpc.HostHeaders(rule.Host, header))
+	}
+
+	rpc.SetRequestBuilders(builders...)
+	return nil
+}
+
+// splitLines splits an environment variable holding one value per line
+func splitLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
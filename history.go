@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+// MIN_HISTORY_PROBES is the number of recorded probes an endpoint needs before
+// its reliability counts towards its score
+const MIN_HISTORY_PROBES = 3
+
+// SPARK_TICKS are the bars of sparklines, from the lowest value to the highest
+var SPARK_TICKS = []rune("▁▂▃▄▅▆▇█")
+
+var (
+	noHistory      bool
+	historySamples int
+
+	probeHistoryMux sync.Mutex
+	probeHistory    []chain.ProbeRecord
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <chainId|chainName>",
+	Short: "Show endpoint uptime from past runs",
+	Long:  "Shows the uptime of every endpoint of the chain over the last day and week, from the probes of past runs",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if historySamples < 0 {
+			return NewParameterErrorWithCmd("--samples must not be negative", cmd)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		records, err := chain.LoadProbeHistory(chainData.ChainID)
+		if err != nil {
+			return err
+		}
+		summary := chain.SummarizeHistory(records)
+
+		if asJSON {
+			return printJSON(summary)
+		}
+		if len(summary) == 0 {
+			return fmt.Errorf("no probes of %s recorded in the last week", chainData.Name)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if historySamples == 0 {
+			fmt.Fprintln(w, "URL\t24H UPTIME\t7D UPTIME\tPROBES\tAVG LATENCY\tLAST SEEN")
+		} else {
+			fmt.Fprintln(w, "URL\t24H UPTIME\t7D UPTIME\tPROBES\tAVG LATENCY\tLAST SEEN\tLATENCY TREND\tUP TREND")
+		}
+		recent := recentProbes(records, historySamples)
+		for _, endpoint := range summary {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s ago", endpoint.URL, formatUptime(endpoint.DayUptime), formatUptime(endpoint.WeekUptime),
+				formatCount(endpoint.Probes), formatLatency(msDuration(endpoint.AvgLatencyMs)), formatDuration(time.Since(endpoint.LastSeen)))
+			if historySamples > 0 {
+				latencyTrend, upTrend := sparklines(recent[endpoint.URL])
+				fmt.Fprintf(w, "\t%s\t%s", latencyTrend, upTrend)
+			}
+			fmt.Fprintln(w)
+		}
+		return w.Flush()
+	},
+}
+
+func formatUptime(uptime float64) string {
+	if uptime < 0 {
+		return "-"
+	}
+	return formatDecimal(100*uptime, 1) + "%"
+}
+
+// recentProbes returns the last n probes of every endpoint, oldest first
+func recentProbes(records []chain.ProbeRecord, n int) map[string][]chain.ProbeRecord {
+	recent := make(map[string][]chain.ProbeRecord)
+	if n <= 0 {
+		return recent
+	}
+	for _, record := range records {
+		probes := append(recent[record.URL], record)
+		if len(probes) > n {
+			probes = probes[1:]
+		}
+		recent[record.URL] = probes
+	}
+	return recent
+}
+
+// sparklines renders the latency of probes as bars scaled between the lowest and the
+// highest latency, failed probes as dots, and whether probes passed as full or low bars
+func sparklines(probes []chain.ProbeRecord) (latencyTrend, upTrend string) {
+	var lowest, highest int64 = -1, 0
+	for _, probe := range probes {
+		if probe.OK {
+			if lowest < 0 || probe.LatencyMs < lowest {
+				lowest = probe.LatencyMs
+			}
+			highest = max(highest, probe.LatencyMs)
+		}
+	}
+
+	var latency, up strings.Builder
+	for _, probe := range probes {
+		if !probe.OK {
+			latency.WriteRune('·')
+			up.WriteRune(SPARK_TICKS[0])
+			continue
+		}
+		tick := 0
+		if highest > lowest {
+			tick = int((probe.LatencyMs - lowest) * int64(len(SPARK_TICKS)-1) / (highest - lowest))
+		}
+		latency.WriteRune(SPARK_TICKS[tick])
+		up.WriteRune(SPARK_TICKS[len(SPARK_TICKS)-1])
+	}
+	return latency.String(), up.String()
+}
+
+// recordProbe keeps the outcome of a probe for saveProbeHistory
+func recordProbe(outcome rpc.ProbeOutcome) {
+	probeHistoryMux.Lock()
+	defer probeHistoryMux.Unlock()
+
+	probeHistory = append(probeHistory, chain.ProbeRecord{
+		ChainID:   outcome.ChainID,
+		URL:       templateURL(outcome.URL),
+		Time:      outcome.Time,
+		LatencyMs: outcome.Latency.Milliseconds(),
+		OK:        outcome.OK,
+	})
+}
+
+// saveProbeHistory appends the probes recorded so far to the history
+func saveProbeHistory() {
+	probeHistoryMux.Lock()
+	records := probeHistory
+	probeHistory = nil
+	probeHistoryMux.Unlock()
+
+	if err := chain.RecordProbes(records); err != nil {
+		warnf("%v", err)
+	}
+}
+
+// endpointReliability returns the weekly uptime of endpoints with enough recorded probes
+func endpointReliability(chains ...*chain.ChainData) map[string]float64 {
+	reliability := make(map[string]float64)
+	for _, chainData := range chains {
+		records, err := chain.LoadProbeHistory(chainData.ChainID)
+		if err != nil {
+			warnf("%v", err)
+			return reliability
+		}
+
+		for _, endpoint := range chain.SummarizeHistory(records) {
+			if endpoint.Probes >= MIN_HISTORY_PROBES {
+				reliability[endpoint.URL] = endpoint.WeekUptime
+			}
+		}
+	}
+	return reliability
+}
+
+func init() {
+	historyCmd.Flags().IntVar(&historySamples, "samples", 20, "number of recent probes drawn as latency and uptime sparklines (0: none)")
+	historyCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	historyCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(historyCmd)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+// chainInfo is the JSON representation of the info command output
+type chainInfo struct {
+	*chain.ChainData
+	RPCCount int                `json:"rpcCount"`
+	Cache    *chain.CacheStatus `json:"cache,omitempty"`
+}
+
+// MarshalJSON flattens the chain data, including fields without first-class
+// support, next to the info command additions
+func (i chainInfo) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(i.ChainData)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	if fields["rpcCount"], err = json.Marshal(i.RPCCount); err != nil {
+		return nil, err
+	}
+	if i.Cache != nil {
+		if fields["cache"], err = json.Marshal(i.Cache); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info <chainId|chainName>",
+	Short: "Show all known metadata of a chain",
+	Long:  "Prints the cached metadata of a chain: name, short name, slug, native currency, explorers, features, SLIP-44 coin type, ENS registry and RPC count. Accepts either chain ID (number) or chain name (string)",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(chainInfo{ChainData: chainData, RPCCount: len(chainData.RPCs), Cache: cacheStatusForOutput()})
+		}
+
+		printChainInfo(chainData)
+		return nil
+	},
+}
+
+func printChainInfo(chainData *chain.ChainData) {
+	fmt.Printf("Name:            %s\n", chainData.Name)
+	fmt.Printf("Chain ID:        %d\n", chainData.ChainID)
+	fmt.Printf("Short name:      %s\n", chainData.ShortName)
+	fmt.Printf("Slug:            %s\n", chainData.ChainSlug)
+	fmt.Printf("Chain:           %s\n", chainData.Chain)
+	fmt.Printf("Native currency: %s (%s, %d decimals)\n", chainData.NativeCurrency.Name, chainData.NativeCurrency.Symbol, chainData.NativeCurrency.Decimals)
+	fmt.Printf("RPC endpoints:   %d\n", len(chainData.RPCs))
+	if len(chainData.Features) > 0 {
+		names := make([]string, 0, len(chainData.Features))
+		for _, feature := range chainData.Features {
+			names = append(names, feature.Name)
+		}
+		fmt.Printf("Features:        %s\n", strings.Join(names, ", "))
+	}
+	if chainData.Slip44 != nil {
+		fmt.Printf("SLIP-44:         %d (HD path m/44'/%d'/...)\n", *chainData.Slip44, *chainData.Slip44)
+	}
+	if chainData.ENS != nil && chainData.ENS.Registry != "" {
+		fmt.Printf("ENS registry:    %s\n", chainData.ENS.Registry)
+	}
+	if len(chainData.Extra) > 0 {
+		names := make([]string, 0, len(chainData.Extra))
+		for name := range chainData.Extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("Other fields:    %s (see --output json)\n", strings.Join(names, ", "))
+	}
+
+	if len(chainData.Explorers) == 0 {
+		fmt.Println("Explorers:       none")
+		return
+	}
+	fmt.Println("Explorers:")
+	for _, explorer := range chainData.Explorers {
+		if explorer.Standard != "" && explorer.Standard != "none" {
+			fmt.Printf("  - %s: %s (%s)\n", explorer.Name, explorer.URL, explorer.Standard)
+		} else {
+			fmt.Printf("  - %s: %s\n", explorer.Name, explorer.URL)
+		}
+	}
+}
+
+func init() {
+	infoCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	infoCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(infoCmd)
+}
@@ -0,0 +1,88 @@
+// Command snapshotgen downloads the chains dataset and writes the gzipped
+// snapshot embedded into chain-rpc for offline use. Run it via go generate:
+//
+//	go generate ./pkg/chain
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// The generator does not import pkg/chain so that it works before a snapshot exists
+const (
+	CHAINS_DATA_URL = "https://chainlist.org/rpcs.json"
+	FETCH_TIMEOUT   = 30 * time.Second
+)
+
+func main() {
+	dataURL := flag.String("url", CHAINS_DATA_URL, "dataset to snapshot")
+	output := flag.String("o", "snapshot.json.gz", "output file")
+	flag.Parse()
+
+	if err := run(*dataURL, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "snapshotgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dataURL, output string) error {
+	client := &http.Client{Timeout: FETCH_TIMEOUT}
+	resp, err := client.Get(dataURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch chains data: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("failed to fetch chains data: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to fetch chains data: %v", err)
+	}
+
+	var chains []json.RawMessage
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return fmt.Errorf("failed to parse chains data: %v", err)
+	}
+	if len(chains) == 0 {
+		return fmt.Errorf("chains data is empty")
+	}
+
+	// The gzip header records when the dataset was produced
+	modTime := time.Now().UTC()
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		modTime = lastModified.UTC()
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	defer file.Close()
+
+	writer, err := gzip.NewWriterLevel(file, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	writer.Name = "rpcs.json"
+	writer.ModTime = modTime
+
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+
+	fmt.Printf("Wrote %d chains to %s\n", len(chains), output)
+	return nil
+}
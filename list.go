@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	testnetsOnly bool
+	mainnetsOnly bool
+	features     []string
+)
+
+// chainSummary is a single row of the list command output
+type chainSummary struct {
+	ChainID uint64 `json:"chainId"`
+	Name    string `json:"name"`
+	Symbol  string `json:"symbol"`
+	Testnet bool   `json:"testnet"`
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list [pattern]",
+	Short: "List known chains",
+	Long:  "Lists all cached chains with their ID, name and native currency symbol. The optional pattern filters by chain name, short name or slug: as a glob when it contains *, ? or [, as a substring otherwise",
+	Args:  maximumArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		keep, err := networkFilter(cmd)
+		if err != nil {
+			return err
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		pattern := ""
+		if len(args) == 1 {
+			pattern = strings.ToLower(args[0])
+			if _, err := path.Match(pattern, ""); err != nil {
+				return NewParameterErrorWithCmd(fmt.Sprintf("invalid pattern '%s'", args[0]), cmd)
+			}
+		}
+
+		chains := make([]chainSummary, 0)
+		err = chain.ForEachChain(func(chainData *chain.ChainData) error {
+			if !matchesChain(chainData, pattern) {
+				return nil
+			}
+
+			for _, feature := range features {
+				if !chainData.HasFeature(feature) {
+					return nil
+				}
+			}
+
+			if keep != nil && !keep(chainData) {
+				return nil
+			}
+
+			chains = append(chains, chainSummary{
+				ChainID: chainData.ChainID,
+				Name:    chainData.Name,
+				Symbol:  chainData.NativeCurrency.Symbol,
+				Testnet: chain.IsTestnet(chainData),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(chains, func(i, j int) bool {
+			return chains[i].ChainID < chains[j].ChainID
+		})
+
+		if asJSON {
+			return printJSON(struct {
+				Chains []chainSummary     `json:"chains"`
+				Cache  *chain.CacheStatus `json:"cache,omitempty"`
+			}{chains, cacheStatusForOutput()})
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSYMBOL")
+		for _, c := range chains {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", c.ChainID, c.Name, c.Symbol)
+		}
+		return w.Flush()
+	},
+}
+
+// matchesChain checks the pattern against the chain names and ID
+func matchesChain(chainData *chain.ChainData, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	isGlob := strings.ContainsAny(pattern, "*?[")
+	candidates := []string{chainData.Name, chainData.ShortName, chainData.ChainSlug, strconv.FormatUint(chainData.ChainID, 10)}
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(candidate)
+		if isGlob {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		} else if strings.Contains(candidate, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// addNetworkFlags registers --testnets and --mainnets, also spelled --testnet and --mainnet
+func addNetworkFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&testnetsOnly, "testnets", false, "only test networks")
+	cmd.Flags().BoolVar(&mainnetsOnly, "mainnets", false, "only main networks")
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		switch name {
+		case "testnet":
+			name = "testnets"
+		case "mainnet":
+			name = "mainnets"
+		}
+		return pflag.NormalizedName(name)
+	})
+}
+
+// networkFilter returns which chains --testnets or --mainnets keep, nil for all
+func networkFilter(cmd *cobra.Command) (func(*chain.ChainData) bool, error) {
+	switch {
+	case testnetsOnly && mainnetsOnly:
+		return nil, NewParameterErrorWithCmd("--testnets and --mainnets are mutually exclusive", cmd)
+	case testnetsOnly:
+		return chain.IsTestnet, nil
+	case mainnetsOnly:
+		return func(chainData *chain.ChainData) bool { return !chain.IsTestnet(chainData) }, nil
+	}
+	return nil, nil
+}
+
+func init() {
+	addNetworkFlags(listCmd)
+	listCmd.Flags().StringSliceVar(&features, "feature", nil, "list only chains declaring the feature, e.g. EIP1559 (repeatable)")
+	listCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	listCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(listCmd)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strconv"
+
+	"chain-rpc/pkg/rpc"
+)
+
+// LOCAL_RPC_URLS are the usual endpoints of local development nodes (anvil, hardhat, geth --dev)
+var LOCAL_RPC_URLS = []string{"http://127.0.0.1:8545", "http://127.0.0.1:8546"}
+
+var preferLocal bool
+
+// localRPC returns the first local development node serving the chain, or "" when there is none
+func localRPC(chainId uint64) string {
+	tester := rpc.NewTester(timeout)
+	tester.Target = 1
+	results := tester.TestRPCs(LOCAL_RPC_URLS, chainId)
+	if len(results) == 0 {
+		return ""
+	}
+	logger.Info("using local node", "url", results[0].URL)
+	return results[0].URL
+}
+
+// localRPCByID looks for a local node before any chain data is loaded, when the chain is
+// given by ID. It tells whether it looked.
+func localRPCByID(identifier string) (string, bool) {
+	chainId, err := strconv.ParseUint(identifier, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	return localRPC(chainId), true
+}
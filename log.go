@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var (
+	logLevel  string
+	logFormat string
+	quiet     bool
+
+	// logger receives diagnostics, on stderr so that they never mix with the output on stdout
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+)
+
+// setupLogger builds the logger of --log-level and --log-format and shares it with the
+// packages. Without --log-level, only errors are logged, informational messages with -v
+// and nothing with --quiet.
+func setupLogger(cmd *cobra.Command) error {
+	if quiet && verbose {
+		return NewParameterErrorWithCmd("--quiet cannot be combined with --verbose", cmd)
+	}
+	level := slog.LevelError
+	switch {
+	case verbose:
+		level = slog.LevelInfo
+	case quiet:
+		level = slog.LevelError + 1
+	}
+	if logLevel != "" {
+		if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+			return NewParameterErrorWithCmd(fmt.Sprintf("unknown log level '%s', expected debug, info, warn or error", logLevel), cmd)
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: templateURLAttr}
+	switch logFormat {
+	case logFormatText:
+		logger = slog.New(slog.NewTextHandler(os.Stderr, options))
+	case logFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, options))
+	default:
+		return NewParameterErrorWithCmd(fmt.Sprintf("unknown log format '%s', expected text or json", logFormat), cmd)
+	}
+	chain.SetLogger(logger)
+	rpc.SetLogger(logger)
+	return nil
+}
+
+// templateURLAttr logs endpoint URLs completed with API keys in their template form
+func templateURLAttr(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == "url" && attr.Value.Kind() == slog.KindString {
+		attr.Value = slog.StringValue(templateURL(attr.Value.String()))
+	}
+	return attr
+}
+
+// warnf writes a warning to stderr, unless --quiet
+func warnf(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+	}
+}
+
+// notef writes what a command did besides its output, e.g. the file it wrote, to
+// stderr, unless --quiet
+func notef(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
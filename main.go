@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/config"
 	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/urls"
 
 	"github.com/spf13/cobra"
 )
@@ -29,71 +37,347 @@ var (
 	timeout   time.Duration
 	wsOnly    bool
 	httpsOnly bool
+	torProxy  string
+	retries   int
+	useCached bool
+	noLint    bool
+	skipKeyed bool
+
+	traceProbes bool
+	budget      time.Duration
+	target      int
+	perHost     int
+
+	excludeSyncing bool
+	clients        []string
+	requireMethods []string
+	requireBatch   bool
+	requireSubs    bool
+	requireCORS    bool
+	excludeLimited bool
+
+	minScore   int
+	showScores bool
+
+	strategyName string
+	fastest      bool
+	stream       bool
+
+	allowInsecure bool
+	explainFailed bool
+
+	ipfsCID     string
+	ipfsGateway string
+
+	offline       bool
+	noWorkspace   bool
+	configPath    string
+	registryPath  string
+	cacheDir      string
+	cacheReadOnly bool
+	proxyAddr     string
+	tlsOptions    rpc.TLSOptions
+	cfg           *config.Config
+	sources       []string
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "chain-rpc <chainId|chainName>",
+	Use:   "chain-rpc <chainId|chainName>...",
 	Short: "Find first working RPC endpoint for a blockchain network",
-	Long:  "Fetches chain data from `chainlist.org` and tests RPC endpoints to find the first working one. Accepts either chain ID (number) or chain name (string)",
-	Args:  exactArgsWithParameterError(1),
+	Long:  "Fetches chain data from `chainlist.org` and tests RPC endpoints to find the first working one. Accepts either chain ID (number) or chain name (string), several chains are tested at once and printed grouped by chain",
+	Args:  cobra.ArbitraryArgs,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupLogger(cmd); err != nil {
+			return err
+		}
+		chain.SetReadOnly(cacheReadOnly)
+		if cacheDir != "" {
+			if err := chain.SetCacheDir(cacheDir); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if cfg, err = config.Load(configPath); err != nil {
+			return err
+		}
+		if !noWorkspace {
+			if err := applyWorkspaceConfig(); err != nil {
+				return err
+			}
+		}
+
+		if err := applySourceConfig(cfg.Source); err != nil {
+			return err
+		}
+		if err := chain.LoadLocalRegistry(localRegistryPath(cmd)); err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("source") || os.Getenv("CHAIN_RPC_SOURCE") != "" {
+			if err := chain.SetSources(sources); err != nil {
+				return NewParameterErrorWithCmd(err.Error(), cmd)
+			}
+		}
+		if err := chain.SetIPFSSource(ipfsCID, ipfsGateway); err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+		chain.SetOffline(offline)
+		if err := rpc.SetProxy(proxyAddr); err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+		if proxyAddr != "" {
+			chain.SetProxy(rpc.Proxy)
+		}
+		tlsConfig, err := tlsOptions.Config()
+		if err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+		rpc.SetTLSConfig(tlsConfig)
+		applyProviderConfig(cmd)
+		if err := applyHeaders(cmd); err != nil {
+			return err
+		}
+		return loadFilterTags()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chain.SetVerbose(verbose)
 		chain.SetForceRebuild(force)
+		if err := rpc.SetTorProxy(torProxy); err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+
+		identifiers, err := chainIdentifiers(cmd, args)
+		if err != nil {
+			return err
+		}
+		if err := validateScoring(cmd, identifiers); err != nil {
+			return err
+		}
+		if err := validateRecording(cmd, identifiers); err != nil {
+			return err
+		}
+		if err := validateExport(cmd, identifiers); err != nil {
+			return err
+		}
+		if err := validateDocker(cmd); err != nil {
+			return err
+		}
+		if len(identifiers) > 1 {
+			if preferLocal {
+				return NewParameterErrorWithCmd("--prefer-local takes a single chain", cmd)
+			}
+			return runMultiChain(cmd, identifiers, false)
+		}
 
-		chainData, err := getChainData(args[0])
+		asJSON, err := isJSONOutput(cmd)
 		if err != nil {
 			return err
 		}
 
+		// A chain given by ID needs no chain data to look for a local node
+		localChecked := false
+		if preferLocal {
+			var url string
+			if url, localChecked = localRPCByID(identifiers[0]); url != "" {
+				return printPicked(rpc.FirstStrategy{}, urlResults([]string{url}), asJSON)
+			}
+		}
+
+		chainData, err := getChainData(identifiers[0])
+		if err != nil {
+			return err
+		}
+
+		if preferLocal && !localChecked {
+			if url := localRPC(chainData.ChainID); url != "" {
+				return printPicked(rpc.FirstStrategy{}, urlResults([]string{url}), asJSON)
+			}
+		}
+
 		rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
 		if len(rpcUrls) == 0 {
-			return fmt.Errorf("no known rpc urls for this chain at `chainlist.org`")
+			return noRPCsError(chainData.RPCs)
 		}
 
 		if noTest {
-			fmt.Println(rpcUrls[0])
-			return nil
+			return printPicked(rpc.FirstStrategy{}, urlResults(rpcUrls), asJSON)
 		}
 
-		workingRPC, err := rpc.FindRandomWorkingRPC(rpcUrls, chainData.ChainID, timeout)
+		strategy, err := selectionStrategy(cmd)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println(workingRPC)
-		return nil
+		if useCached {
+			if cachedRPCs := cachedWorkingRPCs(chainData.ChainID, rpcUrls); len(cachedRPCs) > 0 {
+				// Latencies of earlier runs are not kept, cached endpoints count as equally fast
+				return printPicked(strategy, urlResults(cachedRPCs), asJSON)
+			}
+		}
+		if canAskDaemon(strategy) {
+			if verified := daemonRPCs(chainData.ChainID, rpcUrls); len(verified) > 0 {
+				return printPicked(strategy, verified, asJSON)
+			}
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Latency ranking is only fair once connections are set up
+		if _, ok := rpc.UnwrapStrategy(strategy).(rpc.FastestStrategy); ok {
+			tester.WarmUp = true
+		}
+		if minScore > 0 || rpc.UsesScores(strategy) {
+			enableScoring(tester, chainData)
+		}
+		startRecording(tester, chainData.ChainID)
+
+		if exportVars {
+			httpResult, wsResult, err := rpc.NewSelector(tester, strategy).SelectPair(rpcUrls, chainData.ChainID)
+			saveRecording(tester)
+			if err != nil {
+				return err
+			}
+			var picked []string
+			for _, result := range []rpc.RPCResult{httpResult, wsResult} {
+				if result.URL != "" {
+					reportResults(result)
+					picked = append(picked, result.URL)
+				}
+			}
+			saveWorkingRPCs(chainData.ChainID, nil, picked)
+			return printExport(httpResult, wsResult)
+		}
+
+		workingRPC, err := rpc.NewSelector(tester, strategy).SelectResult(rpcUrls, chainData.ChainID)
+		saveRecording(tester)
+		if err != nil {
+			return err
+		}
+		reportResults(workingRPC)
+		saveWorkingRPCs(chainData.ChainID, nil, []string{workingRPC.URL})
+
+		return printPickedURL(workingRPC.URL, asJSON)
 	},
 }
 
 var allCmd = &cobra.Command{
-	Use:   "all <chainId|chainName>",
+	Use:   "all <chainId|chainName>...",
 	Short: "Find all working RPC endpoints for a blockchain network",
-	Long:  "Fetches chain data from ethereum-lists/chains and tests all RPC endpoints to find working ones. Accepts either chain ID (number) or chain name (string)",
-	Args:  exactArgsWithParameterError(1),
+	Long:  "Fetches chain data from ethereum-lists/chains and tests all RPC endpoints to find working ones. Accepts either chain ID (number) or chain name (string), several chains are tested at once and printed grouped by chain",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chain.SetVerbose(verbose)
 		chain.SetForceRebuild(force)
+		if err := rpc.SetTorProxy(torProxy); err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+
+		identifiers, err := chainIdentifiers(cmd, args)
+		if err != nil {
+			return err
+		}
+		if err := validateScoring(cmd, identifiers); err != nil {
+			return err
+		}
+		if err := validateRecording(cmd, identifiers); err != nil {
+			return err
+		}
+		if err := validateDocker(cmd); err != nil {
+			return err
+		}
+		if len(identifiers) > 1 {
+			return runMultiChain(cmd, identifiers, true)
+		}
+
+		chainData, err := getChainData(identifiers[0])
+		if err != nil {
+			return err
+		}
 
-		chainData, err := getChainData(args[0])
+		asJSON, err := isJSONOutput(cmd)
 		if err != nil {
 			return err
 		}
 
 		rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
 		if len(rpcUrls) == 0 {
-			return fmt.Errorf("no known rpc urls for this chain at `chainlist.org`")
+			return noRPCsError(chainData.RPCs)
+		}
+
+		if watchInterval < 0 {
+			return NewParameterErrorWithCmd("--watch must not be negative", cmd)
+		}
+		budgets, err := newBudgetTracker(cmd)
+		if err != nil {
+			return err
+		}
+		if budgets != nil && watchInterval == 0 {
+			return NewParameterErrorWithCmd("--budget-p95 and --budget-errors need --watch", cmd)
+		}
+		if watchInterval > 0 {
+			if noTest || useCached || stream {
+				return NewParameterErrorWithCmd("--watch cannot be combined with --no-test, --cached or --stream", cmd)
+			}
+
+			tester, err := newTester(cmd)
+			if err != nil {
+				return err
+			}
+			return watchRPCs(tester, chainData.ChainID, rpcUrls, watchInterval, budgets, asJSON)
 		}
 
 		if noTest {
-			for _, rpcURL := range rpcUrls {
-				fmt.Println(rpcURL)
+			return printURLs(rpcUrls, asJSON)
+		}
+
+		var workingRPCs []string
+		if useCached {
+			workingRPCs = cachedWorkingRPCs(chainData.ChainID, rpcUrls)
+		}
+		if len(workingRPCs) == 0 && !stream && canAskDaemon(nil) {
+			for _, result := range daemonRPCs(chainData.ChainID, rpcUrls) {
+				workingRPCs = append(workingRPCs, result.URL)
 			}
-			return nil
 		}
 
-		workingRPCs, err := rpc.FindAllWorkingRPCs(rpcUrls, chainData.ChainID, timeout)
-		if err != nil {
-			return err
+		if len(workingRPCs) == 0 && stream {
+			tester, err := newTester(cmd)
+			if err != nil {
+				return err
+			}
+			startRecording(tester, chainData.ChainID)
+			defer saveRecording(tester)
+			return streamRPCs(tester, chainData.ChainID, rpcUrls, asJSON)
+		}
+
+		if len(workingRPCs) == 0 {
+			tester, err := newTester(cmd)
+			if err != nil {
+				return err
+			}
+			if minScore > 0 || showScores {
+				enableScoring(tester, chainData)
+			}
+			// Like JSON lines, the JSON scores carry the node implementation and batch support
+			if showScores && asJSON {
+				addCapabilityChecks(tester)
+			}
+			startRecording(tester, chainData.ChainID)
+
+			results, err := tester.FindAllWorkingResults(rpcUrls, chainData.ChainID)
+			saveRecording(tester)
+			reportResults(results...)
+			for _, result := range results {
+				workingRPCs = append(workingRPCs, result.URL)
+			}
+			saveWorkingRPCs(chainData.ChainID, rpcUrls, workingRPCs)
+			if err != nil {
+				return err
+			}
+
+			if showScores {
+				return printScores(results, asJSON)
+			}
 		}
 
 		// Shuffle the results for better load distribution
@@ -102,11 +386,380 @@ var allCmd = &cobra.Command{
 			workingRPCs[i], workingRPCs[j] = workingRPCs[j], workingRPCs[i]
 		})
 
-		for _, rpcURL := range workingRPCs {
-			fmt.Println(rpcURL)
+		return printURLs(preferredFirst(workingRPCs), asJSON)
+	},
+}
+
+// streamRPCs prints every endpoint as soon as it passes testing, as JSON lines with --json
+func streamRPCs(tester *rpc.Tester, chainId uint64, rpcUrls []string, asJSON bool) error {
+	// JSON lines carry the node implementation and batch support
+	if asJSON {
+		addCapabilityChecks(tester)
+	}
+
+	var printErr error
+	results := tester.StreamRPCs(rpcUrls, chainId, func(result rpc.RPCResult) bool {
+		warnInsecure(result.URL)
+		reportResults(result)
+		if asJSON {
+			data, err := json.Marshal(struct {
+				URL       string             `json:"url"`
+				LatencyMs int64              `json:"latencyMs"`
+				Client    *rpc.ClientVersion `json:"client,omitempty"`
+				Batch     *bool              `json:"batch,omitempty"`
+				RateLimit string             `json:"rateLimit"`
+			}{result.URL, result.Latency.Milliseconds(), result.Client, result.Batch, result.RateLimit})
+			if err != nil {
+				printErr = err
+				return false
+			}
+			_, printErr = fmt.Println(string(data))
+		} else {
+			_, printErr = fmt.Println(result.URL)
+		}
+		// Stop testing once the reader went away, e.g. `| head -1`
+		return printErr == nil
+	})
+	if printErr != nil {
+		return printErr
+	}
+
+	workingRPCs := make([]string, 0, len(results))
+	for _, result := range results {
+		workingRPCs = append(workingRPCs, result.URL)
+	}
+	saveWorkingRPCs(chainId, rpcUrls, workingRPCs)
+	if len(workingRPCs) == 0 {
+		return rpc.ErrNoRPCsFound
+	}
+	return nil
+}
+
+// reportResults logs verified endpoints with their node implementation
+func reportResults(results ...rpc.RPCResult) {
+	for _, result := range results {
+		if result.Err != "" {
+			continue
+		}
+		logger.Info("verified endpoint", "url", result.URL, "latency", result.Latency, "details", resultDetails(result))
+	}
+}
+
+// resultDetails describes a verified endpoint by its node implementation, rate limit and score
+func resultDetails(result rpc.RPCResult) string {
+	client := "unknown client"
+	if result.Client != nil {
+		client = result.Client.String()
+	}
+	details := fmt.Sprintf("%s, rate limit: %s", client, result.RateLimit)
+	if result.Score > 0 {
+		details += fmt.Sprintf(", score: %d", result.Score)
+	}
+	return details
+}
+
+// printURL prints an endpoint, labelling it on stderr when it is not encrypted
+func printURL(url string) {
+	warnInsecure(url)
+	fmt.Println(url)
+}
+
+// printPickedURL prints the endpoint picked for a chain, as a JSON string with --json
+func printPickedURL(url string, asJSON bool) error {
+	if asJSON {
+		warnInsecure(url)
+		return printJSON(url)
+	}
+	printURL(url)
+	return nil
+}
+
+func printURLs(urls []string, asJSON bool) error {
+	warnInsecure(urls...)
+	if asJSON {
+		return printJSON(urls)
+	}
+
+	for _, url := range urls {
+		fmt.Println(url)
+	}
+	return nil
+}
+
+// warnInsecure labels plaintext endpoints on stderr so that stdout stays usable in scripts
+func warnInsecure(urls ...string) {
+	for _, url := range urls {
+		if isInsecureURL(url) {
+			warnf("%s is insecure (plaintext, not encrypted)", templateURL(url))
+		}
+	}
+}
+
+// noRPCsError explains why no endpoint is left after filtering
+func noRPCsError(rpcs []chain.RPC) error {
+	if len(filterTags) > 0 {
+		return fmt.Errorf("no rpc urls of this chain are tagged %s, see chain-rpc tag list", strings.Join(filterTags, " and "))
+	}
+	if len(excludeProviders) > 0 {
+		for _, rpc := range rpcs {
+			if isExcludedProvider(rpc.URL) {
+				return fmt.Errorf("no rpc urls of this chain are left once excluding the providers %s", strings.Join(excludeProviders, ", "))
+			}
 		}
+	}
+	keyless := 0
+	for _, rpc := range rpcs {
+		if _, complete := substituteKeys(rpc.URL); !complete {
+			keyless++
+		}
+	}
+	if keyless > 0 && keyless == len(rpcs) {
+		return withExitCode(EXIT_NO_ENDPOINTS, fmt.Errorf("the rpc urls of this chain need API keys, set the environment variables of their ${...} templates or the provider keys in the config file"))
+	}
+	if !allowInsecure {
+		for _, rpc := range rpcs {
+			if isInsecureURL(rpc.URL) {
+				return withExitCode(EXIT_NO_ENDPOINTS, fmt.Errorf("no secure rpc urls known for this chain, pass --allow-insecure to use plaintext http:// endpoints"))
+			}
+		}
+	}
+	return withExitCode(EXIT_NO_ENDPOINTS, fmt.Errorf("no known rpc urls for this chain at `chainlist.org`"))
+}
+
+// localRegistryPath resolves the registry location: flag or env, then config, then default
+func localRegistryPath(cmd *cobra.Command) string {
+	if cmd.Flags().Changed("registry") || os.Getenv("CHAIN_RPC_REGISTRY") != "" || cfg.Registry == "" {
+		return registryPath
+	}
+	return cfg.Registry
+}
+
+// applyWorkspaceConfig merges the workspace config file of the current directory tree,
+// if any, over the user config
+func applyWorkspaceConfig() error {
+	dir, err := os.Getwd()
+	if err != nil {
 		return nil
-	},
+	}
+	path, exists := config.FindWorkspace(dir)
+	if !exists {
+		return nil
+	}
+
+	workspace, err := config.LoadWorkspace(path)
+	if err != nil {
+		return err
+	}
+	logger.Info("using workspace config", "path", path)
+	cfg = cfg.Merge(workspace)
+	return nil
+}
+
+func applySourceConfig(source config.SourceConfig) error {
+	chain.SetDataURL(source.URL)
+	chain.SetMirrors(source.Mirrors)
+	if err := chain.SetSources(source.Sources); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	if err := chain.SetChecksum(source.SHA256); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	if err := chain.SetMinisignKey(source.MinisignKey, source.SignatureURL); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	return nil
+}
+
+func newTester(cmd *cobra.Command) (*rpc.Tester, error) {
+	if retries < 0 {
+		return nil, NewParameterErrorWithCmd("retries must not be negative", cmd)
+	}
+	if budget < 0 {
+		return nil, NewParameterErrorWithCmd("budget must not be negative", cmd)
+	}
+	if target < 0 {
+		return nil, NewParameterErrorWithCmd("target must not be negative", cmd)
+	}
+	if perHost < 0 {
+		return nil, NewParameterErrorWithCmd("per-host must not be negative", cmd)
+	}
+
+	tester := rpc.NewTester(timeout)
+	tester.Retries = retries
+	tester.Budget = budget
+	tester.Target = target
+	tester.PerHost = perHost
+	tester.ExcludeRateLimited = excludeLimited
+	if excludeSyncing {
+		tester.Checks = append(tester.Checks, rpc.SyncingCheck{})
+	}
+	if len(requireMethods) > 0 {
+		tester.Checks = append(tester.Checks, rpc.MethodsCheck{Methods: requireMethods})
+	}
+	if requireBatch {
+		tester.Checks = append(tester.Checks, rpc.BatchCheck{Require: true})
+	}
+	if requireSubs {
+		tester.Checks = append(tester.Checks, rpc.SubscriptionCheck{})
+	}
+	if requireCORS {
+		tester.Checks = append(tester.Checks, rpc.CORSCheck{})
+	}
+	// Logs show the node implementation of verified endpoints
+	if len(clients) > 0 || logger.Enabled(context.Background(), slog.LevelInfo) {
+		tester.Checks = append(tester.Checks, rpc.ClientCheck{Clients: clients})
+	}
+	if traceProbes {
+		tester.Trace = newProbeTracer()
+	}
+	if !noHistory {
+		tester.OnProbe = recordProbe
+	}
+	return tester, nil
+}
+
+// validateScoring checks --min-score and --scores, which need all endpoints tested in one run
+func validateScoring(cmd *cobra.Command, identifiers []string) error {
+	if minScore < 0 || minScore > 100 {
+		return NewParameterErrorWithCmd("min-score must be between 0 and 100", cmd)
+	}
+	if minScore == 0 && !showScores {
+		return nil
+	}
+	if stream || watchInterval > 0 || useCached || noTest {
+		return NewParameterErrorWithCmd("--min-score and --scores cannot be combined with --stream, --watch, --cached or --no-test", cmd)
+	}
+	if showScores && len(identifiers) > 1 {
+		return NewParameterErrorWithCmd("--scores takes a single chain", cmd)
+	}
+	return nil
+}
+
+// enableScoring makes the tester score endpoints, probing the latest block and the
+// method coverage that scores are made of
+func enableScoring(tester *rpc.Tester, chains ...*chain.ChainData) {
+	tracking := make(map[string]string)
+	reliability := endpointReliability(chains...)
+	for _, chainData := range chains {
+		for _, rpc := range chainData.RPCs {
+			template := urls.Normalize(rpc.URL)
+			tracking[template] = rpc.Tracking
+			// Endpoints are tested with their API keys, the history has their template
+			completed, _ := substituteKeys(rpc.URL)
+			if completed := urls.Normalize(completed); completed != template {
+				tracking[completed] = rpc.Tracking
+				if uptime, ok := reliability[template]; ok {
+					reliability[completed] = uptime
+				}
+			}
+		}
+	}
+
+	tester.Checks = append(tester.Checks, scoringChecks()...)
+	tester.Scorer = &rpc.Scorer{Tracking: tracking, Reliability: reliability, MinScore: minScore}
+}
+
+// scoringChecks probe the latest block and the method coverage that scores are made of
+func scoringChecks() []rpc.Check {
+	return []rpc.Check{rpc.BlockCheck{}, rpc.MethodsCheck{Methods: rpc.SCORE_METHODS, Optional: true}}
+}
+
+// printScores lists the endpoints from the highest score with what they were scored on
+func printScores(results []rpc.RPCResult, asJSON bool) error {
+	rpc.SortByScore(results)
+	for _, result := range results {
+		warnInsecure(result.URL)
+	}
+	// Scores rate endpoints rather than hand one out, so API keys are left out
+	for i := range results {
+		results[i].URL = templateURL(results[i].URL)
+	}
+	if asJSON {
+		return printJSON(results)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tSCORE\tLATENCY\tBLOCK\tRATE LIMIT")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", result.URL, result.Score, formatLatency(result.Latency), formatCount(result.BlockNumber), result.RateLimit)
+	}
+	return w.Flush()
+}
+
+// selectionStrategy resolves --strategy and its --fastest shorthand
+func selectionStrategy(cmd *cobra.Command) (rpc.Strategy, error) {
+	name := strategyName
+	if fastest {
+		if cmd.Flags().Changed("strategy") && strategyName != "fastest" {
+			return nil, NewParameterErrorWithCmd("--fastest conflicts with --strategy "+strategyName, cmd)
+		}
+		name = "fastest"
+	}
+
+	strategy, err := rpc.StrategyByName(name)
+	if err != nil {
+		return nil, NewParameterErrorWithCmd(fmt.Sprintf("%v, expected one of: %s", err, strings.Join(rpc.StrategyNames(), ", ")), cmd)
+	}
+	if len(preferProviders) > 0 {
+		return rpc.PreferStrategy{Strategy: strategy, Preferred: isPreferredProvider}, nil
+	}
+	return strategy, nil
+}
+
+// newProbeTracer prints probe lifecycle events to stderr with the time since the first event
+func newProbeTracer() func(rpc.ProbeEvent) {
+	var mu sync.Mutex
+	var start time.Time
+	return func(event rpc.ProbeEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if start.IsZero() {
+			start = event.Time
+		}
+		line := fmt.Sprintf("[trace] %s +%-9s %-9s #%d %s", event.Time.Format("15:04:05.000"), event.Time.Sub(start).Round(time.Microsecond), event.Stage, event.Attempt, templateURL(event.URL))
+		if event.Stage == rpc.PROBE_FINISHED {
+			if event.OK {
+				line += " ok"
+			} else {
+				line += " failed: " + event.Err
+			}
+		} else if event.Err != "" {
+			line += ": " + event.Err
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// cachedWorkingRPCs returns the endpoints among rpcUrls that recently passed testing
+func cachedWorkingRPCs(chainId uint64, rpcUrls []string) []string {
+	recent, err := chain.LoadWorkingRPCs(chainId)
+	if err != nil {
+		warnf("%v", err)
+		return nil
+	}
+
+	isRecent := make(map[string]bool, len(recent))
+	for _, url := range recent {
+		isRecent[url] = true
+	}
+
+	// URLs completed with API keys are recorded as their template
+	cached := make([]string, 0, len(recent))
+	for _, url := range rpcUrls {
+		if isRecent[templateURL(url)] {
+			cached = append(cached, url)
+		}
+	}
+	return cached
+}
+
+// saveWorkingRPCs records the outcome of a test run, with URLs completed with API keys
+// in their template form
+func saveWorkingRPCs(chainId uint64, tested, working []string) {
+	if err := chain.SaveWorkingRPCs(chainId, templateURLs(tested), templateURLs(working)); err != nil {
+		warnf("%v", err)
+	}
 }
 
 func getChainData(identifier string) (*chain.ChainData, error) {
@@ -116,24 +769,53 @@ func getChainData(identifier string) (*chain.ChainData, error) {
 	}
 
 	// If not a number, treat as chain name
-	return chain.FetchChainDataByName(identifier)
+	return fetchChainDataByName(identifier)
 }
 
 func extractRPCUrls(rpcs []chain.RPC, wsOnly, httpsOnly bool) []string {
-	urls := make([]string, 0, len(rpcs))
+	if discoverDocker {
+		rpcs = withDockerRPCs(rpcs)
+	}
+	endpoints := make([]string, 0, len(rpcs))
 	for _, rpc := range rpcs {
 		if rpc.URL != "" {
+			// API keys of the environment or config file complete templated URLs
+			rpcURL, complete := substituteKeys(rpc.URL)
+			if skipKeyed && !complete {
+				continue
+			}
 			// Apply filtering based on flags
-			if wsOnly && !isWebSocketURL(rpc.URL) {
+			if wsOnly && !isWebSocketURL(rpcURL) {
+				continue
+			}
+			if httpsOnly && !isHTTPSURL(rpcURL) {
+				continue
+			}
+			// Onion services are unusable without a Tor proxy
+			if torProxy == "" && isOnionURL(rpcURL) {
+				continue
+			}
+			// Plaintext endpoints can be read and tampered with on the way
+			if !allowInsecure && isInsecureURL(rpcURL) {
 				continue
 			}
-			if httpsOnly && !isHTTPSURL(rpc.URL) {
+			// Malformed URLs, embedded credentials and API key templates are not worth probing
+			if !noLint && hasBlockingLintIssue(rpcURL) {
 				continue
 			}
-			urls = append(urls, rpc.URL)
+			// Tags are kept for the URLs as listed, without API keys
+			if !hasFilterTags(rpc.URL) {
+				continue
+			}
+			if isExcludedProvider(rpcURL) {
+				continue
+			}
+			endpoints = append(endpoints, rpcURL)
 		}
 	}
-	return urls
+	// Endpoints listed several ways, e.g. with a trailing slash, are probed once. Preferred
+	// endpoints are probed first, which matters when testing stops early.
+	return preferredFirst(urls.Dedup(endpoints))
 }
 
 func isWebSocketURL(url string) bool {
@@ -144,6 +826,18 @@ func isHTTPSURL(url string) bool {
 	return strings.HasPrefix(url, "https://")
 }
 
+func isOnionURL(url string) bool {
+	return rpc.IsOnionURL(url)
+}
+
+func isInsecureURL(url string) bool {
+	return rpc.IsInsecureURL(url)
+}
+
+func hasBlockingLintIssue(url string) bool {
+	return rpc.HasBlockingIssue(url)
+}
+
 var cacheCmd = &cobra.Command{
 	Use:   "cache",
 	Short: "Manage chain data cache",
@@ -155,16 +849,65 @@ var cacheCleanCmd = &cobra.Command{
 	Short: "Remove the cache file",
 	Long:  "Removes the local cache file, forcing a fresh download on next use",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return chain.CleanCache()
+		return withExitCode(EXIT_CACHE, chain.CleanCache())
 	},
 }
 
 var cacheBuildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Build/update the cache file",
-	Long:  "Downloads fresh chain data and rebuilds the cache file",
+	Long:  "Downloads fresh chain data and rebuilds the cache file. Datasets that did not change since the last download are not downloaded again unless --force is given",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		return withExitCode(EXIT_CACHE, chain.BuildCache())
+	},
+}
+
+var cacheStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show cache freshness",
+	Long:  "Shows where the cached chain data came from, when the source produced it and when the cache expires",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return chain.BuildCache()
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		status, err := chain.GetCacheStatus()
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(status)
+		}
+
+		fmt.Printf("Cache file:  %s\n", status.Path)
+		if !status.Exists {
+			fmt.Println("Status:      missing")
+			return nil
+		}
+
+		state := "expired"
+		if status.Fresh {
+			state = "fresh"
+		} else if status.Degraded {
+			state = "stale (refresh failed, using expired data)"
+		}
+		fmt.Printf("Status:      %s\n", state)
+		if len(status.Meta.Sources) > 0 {
+			fmt.Printf("Sources:     %s\n", strings.Join(status.Meta.Sources, ", "))
+		}
+		if status.Meta.SourceURL != "" {
+			fmt.Printf("Source:      %s\n", status.Meta.SourceURL)
+		}
+		fmt.Printf("Source time: %s\n", status.Meta.SourceTime.Local().Format(time.RFC1123))
+		fmt.Printf("Fetched at:  %s\n", status.Meta.FetchedAt.Local().Format(time.RFC1123))
+		fmt.Printf("Expires at:  %s\n", status.Meta.ExpiresAt.Local().Format(time.RFC1123))
+		if status.Degraded {
+			fmt.Printf("Last error:  %s (%s)\n", status.Meta.RefreshError, status.Meta.RefreshFailedAt.Local().Format(time.RFC1123))
+		}
+		return nil
 	},
 }
 
@@ -174,10 +917,9 @@ var idCmd = &cobra.Command{
 	Long:  "Returns the chain ID for the given chain name",
 	Args:  exactArgsWithParameterError(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chain.SetVerbose(verbose)
 		chain.SetForceRebuild(force)
 
-		chainData, err := chain.FetchChainDataByName(args[0])
+		chainData, err := fetchChainDataByName(args[0])
 		if err != nil {
 			return err
 		}
@@ -193,7 +935,6 @@ var nameCmd = &cobra.Command{
 	Long:  "Returns the chain name for the given chain ID",
 	Args:  exactArgsWithParameterError(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chain.SetVerbose(verbose)
 		chain.SetForceRebuild(force)
 
 		chainId, err := strconv.ParseUint(args[0], 10, 64)
@@ -211,32 +952,107 @@ var nameCmd = &cobra.Command{
 	},
 }
 
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print the version number",
-	Long:  "Print the version number of chain-rpc",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(version)
-	},
-}
-
 func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", envOrDefault("CHAIN_RPC_CONFIG", config.DefaultPath()), "path to the config file (env CHAIN_RPC_CONFIG)")
+	rootCmd.PersistentFlags().BoolVar(&noWorkspace, "no-workspace", envBool("CHAIN_RPC_NO_WORKSPACE"), "ignore .chain-rpc.yaml files in the current directory and its parents (env CHAIN_RPC_NO_WORKSPACE)")
+	rootCmd.PersistentFlags().StringVar(&registryPath, "registry", envOrDefault("CHAIN_RPC_REGISTRY", config.DefaultRegistryPath()), "path to the local chain registry merged over the dataset (env CHAIN_RPC_REGISTRY)")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", os.Getenv("CHAIN_RPC_CACHE_DIR"), "directory of the chain data cache and endpoint history (default: chain-rpc in the user cache directory; env CHAIN_RPC_CACHE_DIR)")
+	rootCmd.PersistentFlags().BoolVar(&cacheReadOnly, "cache-readonly", envBool("CHAIN_RPC_CACHE_READONLY"), "never write to the cache directory, fail if the cache is missing or expired (env CHAIN_RPC_CACHE_READONLY)")
+	rootCmd.PersistentFlags().StringVar(&tagsPath, "tags-file", envOrDefault("CHAIN_RPC_TAGS_FILE", config.DefaultTagsPath()), "path to the endpoint tags (env CHAIN_RPC_TAGS_FILE)")
+	rootCmd.PersistentFlags().StringSliceVar(&excludeProviders, "exclude-provider", splitList(os.Getenv("CHAIN_RPC_EXCLUDE_PROVIDER")), "never use endpoints of these provider domains, e.g. ankr.com (env CHAIN_RPC_EXCLUDE_PROVIDER)")
+	rootCmd.PersistentFlags().StringSliceVar(&preferProviders, "prefer-provider", splitList(os.Getenv("CHAIN_RPC_PREFER_PROVIDER")), "pick working endpoints of these provider domains first, e.g. llamarpc.com (env CHAIN_RPC_PREFER_PROVIDER)")
+	rootCmd.PersistentFlags().StringVar(&proxyAddr, "proxy", os.Getenv("CHAIN_RPC_PROXY"), "HTTP(S) or SOCKS5 proxy for dataset downloads and endpoint requests, e.g. socks5://127.0.0.1:1080, instead of HTTP_PROXY and HTTPS_PROXY (env CHAIN_RPC_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&tlsOptions.CACert, "ca-cert", os.Getenv("CHAIN_RPC_CA_CERT"), "PEM bundle of CAs to trust for endpoints in addition to the system ones (env CHAIN_RPC_CA_CERT)")
+	rootCmd.PersistentFlags().StringVar(&tlsOptions.ClientCert, "client-cert", os.Getenv("CHAIN_RPC_CLIENT_CERT"), "PEM client certificate presented to endpoints requiring mTLS (env CHAIN_RPC_CLIENT_CERT)")
+	rootCmd.PersistentFlags().StringVar(&tlsOptions.ClientKey, "client-key", os.Getenv("CHAIN_RPC_CLIENT_KEY"), "PEM private key of --client-cert, if not in the certificate file (env CHAIN_RPC_CLIENT_KEY)")
+	rootCmd.PersistentFlags().BoolVar(&tlsOptions.Insecure, "insecure", envBool("CHAIN_RPC_INSECURE"), "skip the verification of endpoint TLS certificates, unlike --allow-insecure which includes plaintext endpoints (env CHAIN_RPC_INSECURE)")
+	rootCmd.PersistentFlags().StringArrayVarP(&requestHeaders, "header", "H", splitLines(os.Getenv("CHAIN_RPC_HEADER")), "add a \"Name: value\" header to the requests of every endpoint, e.g. \"Authorization: Bearer ...\" (repeatable; env CHAIN_RPC_HEADER, one per line)")
+	rootCmd.PersistentFlags().StringSliceVar(&filterTags, "tag", nil, "only use endpoints with all of these tags, see chain-rpc tag")
+	rootCmd.PersistentFlags().StringSliceVar(&sources, "source", splitList(os.Getenv("CHAIN_RPC_SOURCE")), fmt.Sprintf("chain data sources to build the cache from, merged in order: %s (env CHAIN_RPC_SOURCE)", strings.Join(chain.SourceNames(), ", ")))
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", envBool("CHAIN_RPC_OFFLINE"), "never download chain data: use the existing cache or the embedded snapshot (env CHAIN_RPC_OFFLINE)")
+	rootCmd.PersistentFlags().BoolVar(&noDaemon, "no-daemon", envBool("CHAIN_RPC_NO_DAEMON"), "probe endpoints even when a serve daemon has verified them (env CHAIN_RPC_NO_DAEMON)")
+	rootCmd.PersistentFlags().BoolVar(&noHistory, "no-history", envBool("CHAIN_RPC_NO_HISTORY"), "do not record probe results in the history used by the history command and scores (env CHAIN_RPC_NO_HISTORY)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", os.Getenv("CHAIN_RPC_LOG_LEVEL"), "level of the logs on stderr: debug, info, warn or error (default: error, info with -v; env CHAIN_RPC_LOG_LEVEL)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", envOrDefault("CHAIN_RPC_LOG_FORMAT", logFormatText), "format of the logs on stderr: text or json (env CHAIN_RPC_LOG_FORMAT)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print nothing but the result or the error: no warnings, notes or logs")
+	rootCmd.PersistentFlags().BoolVar(&explainFailed, "why", false, "when every endpoint fails, print why each one did, as verbose output does")
+	rootCmd.PersistentFlags().BoolVar(&noInteractive, "no-interactive", false, "never prompt, fail on ambiguous chain names instead")
+	rootCmd.PersistentFlags().StringVar(&ipfsCID, "ipfs-cid", os.Getenv("CHAIN_RPC_IPFS_CID"), "IPFS CID of a chains dataset mirror, downloaded alongside chainlist.org (env CHAIN_RPC_IPFS_CID)")
+	rootCmd.PersistentFlags().StringVar(&ipfsGateway, "ipfs-gateway", envOrDefault("CHAIN_RPC_IPFS_GATEWAY", chain.DEFAULT_IPFS_GATEWAY), "IPFS gateway used to fetch the dataset mirror (env CHAIN_RPC_IPFS_GATEWAY)")
+
 	rootCmd.Flags().BoolVar(&noTest, "no-test", false, "return RPC URLs without testing them")
 	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
 	rootCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
-	rootCmd.Flags().DurationVarP(&timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	durationVarP(rootCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
 	rootCmd.Flags().BoolVar(&wsOnly, "wss", false, "return only WebSocket RPC URLs")
 	rootCmd.Flags().BoolVar(&httpsOnly, "https", false, "return only HTTPS RPC URLs")
+	rootCmd.Flags().BoolVar(&useCached, "cached", false, "return endpoints that passed testing within the last 5 minutes without re-probing")
+	rootCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	durationVar(rootCmd.Flags(), &budget, "budget", 0, "overall time limit for testing, by default long enough for every attempt")
+	rootCmd.Flags().IntVar(&target, "target", 0, "stop testing once this many endpoints are verified (0: test all)")
+	rootCmd.Flags().StringVar(&strategyName, "strategy", "random", fmt.Sprintf("how to pick among working endpoints: %s", strings.Join(rpc.StrategyNames(), ", ")))
+	rootCmd.Flags().BoolVar(&fastest, "fastest", false, "shorthand for --strategy fastest")
+	rootCmd.Flags().IntVar(&perHost, "per-host", rpc.DEFAULT_PER_HOST_PROBES, "maximum simultaneous probes to the same host (0: no limit)")
+	rootCmd.Flags().BoolVar(&excludeSyncing, "exclude-syncing", false, "reject endpoints that report through eth_syncing that they are still syncing")
+	rootCmd.Flags().BoolVar(&excludeLimited, "exclude-rate-limited", false, "reject endpoints that throttled the probe, even if a retry passed")
+	rootCmd.Flags().StringSliceVar(&clients, "client", nil, "only return endpoints running one of these node implementations (e.g. geth,erigon,nethermind,reth)")
+	rootCmd.Flags().StringSliceVar(&requireMethods, "require-methods", nil, "only return endpoints supporting all of these JSON-RPC methods (e.g. debug_traceTransaction,trace_block)")
+	rootCmd.Flags().BoolVar(&requireBatch, "require-batch", false, "only return endpoints that answer JSON-RPC batch requests")
+	rootCmd.Flags().BoolVar(&requireSubs, "require-subscriptions", false, "require WebSocket endpoints to deliver a newHeads notification within --timeout")
+	rootCmd.Flags().BoolVar(&requireCORS, "cors", false, "only return endpoints that browser dapps on other origins can call (CORS preflight)")
+	rootCmd.Flags().IntVar(&minScore, "min-score", 0, "drop endpoints scoring lower, from 0 to 100 (latency, freshness, method coverage, tracking, reliability)")
+	rootCmd.Flags().BoolVar(&traceProbes, "trace-probes", false, "log the lifecycle of every probe to stderr, to tune --timeout and --retries")
+	rootCmd.Flags().StringVar(&torProxy, "tor-proxy", "", "SOCKS5 address of a Tor proxy for .onion endpoints (e.g. 127.0.0.1:9050)")
+	rootCmd.Flags().BoolVar(&noLint, "no-lint", false, "keep malformed URLs, URLs with credentials and API key templates")
+	rootCmd.Flags().BoolVar(&skipKeyed, "skip-keyed", false, "drop URLs with API key templates no key was found for, even with --no-lint")
+	rootCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	rootCmd.Flags().BoolVar(&discoverDocker, "docker", false, "include running Docker containers publishing common RPC ports (8545, 8546, ...) as candidate endpoints")
+	rootCmd.Flags().BoolVar(&preferLocal, "prefer-local", false, "return a local development node on 127.0.0.1:8545 or :8546 serving the chain, if any, before trying public endpoints")
+	rootCmd.Flags().BoolVar(&exportVars, "export", false, "print shell export statements of an HTTP endpoint and, if one works, a WebSocket endpoint (preferably of the same provider), for eval")
+	rootCmd.Flags().StringVar(&exportPrefix, "prefix", "ETH", "prefix of the --export variable names, PREFIX_RPC_URL and PREFIX_WS_URL")
+	rootCmd.Flags().StringVar(&recordPath, "record", "", "write every probe request and response to this file, for analyzing the run offline with replay")
+	rootCmd.Flags().StringSliceVar(&chainList, "chains", nil, "comma-separated chain IDs or names, tested together with the ones given as arguments")
+	addOutputFlags(rootCmd)
 
 	allCmd.Flags().BoolVar(&noTest, "no-test", false, "return all RPC URLs without testing them")
 	allCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
 	allCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
-	allCmd.Flags().DurationVarP(&timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	durationVarP(allCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
 	allCmd.Flags().BoolVar(&wsOnly, "wss", false, "return only WebSocket RPC URLs")
 	allCmd.Flags().BoolVar(&httpsOnly, "https", false, "return only HTTPS RPC URLs")
+	allCmd.Flags().BoolVar(&useCached, "cached", false, "return endpoints that passed testing within the last 5 minutes without re-probing")
+	allCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	durationVar(allCmd.Flags(), &budget, "budget", 0, "overall time limit for testing, by default long enough for every attempt")
+	allCmd.Flags().IntVar(&target, "target", 0, "stop testing once this many endpoints are verified (0: test all)")
+	allCmd.Flags().IntVar(&perHost, "per-host", rpc.DEFAULT_PER_HOST_PROBES, "maximum simultaneous probes to the same host (0: no limit)")
+	allCmd.Flags().BoolVar(&excludeSyncing, "exclude-syncing", false, "reject endpoints that report through eth_syncing that they are still syncing")
+	allCmd.Flags().BoolVar(&excludeLimited, "exclude-rate-limited", false, "reject endpoints that throttled the probe, even if a retry passed")
+	allCmd.Flags().StringSliceVar(&clients, "client", nil, "only return endpoints running one of these node implementations (e.g. geth,erigon,nethermind,reth)")
+	allCmd.Flags().StringSliceVar(&requireMethods, "require-methods", nil, "only return endpoints supporting all of these JSON-RPC methods (e.g. debug_traceTransaction,trace_block)")
+	allCmd.Flags().BoolVar(&requireBatch, "require-batch", false, "only return endpoints that answer JSON-RPC batch requests")
+	allCmd.Flags().BoolVar(&requireSubs, "require-subscriptions", false, "require WebSocket endpoints to deliver a newHeads notification within --timeout")
+	allCmd.Flags().BoolVar(&requireCORS, "cors", false, "only return endpoints that browser dapps on other origins can call (CORS preflight)")
+	allCmd.Flags().IntVar(&minScore, "min-score", 0, "drop endpoints scoring lower, from 0 to 100 (latency, freshness, method coverage, tracking, reliability)")
+	allCmd.Flags().BoolVar(&traceProbes, "trace-probes", false, "log the lifecycle of every probe to stderr, to tune --timeout and --retries")
+	allCmd.Flags().BoolVar(&stream, "stream", false, "print each working endpoint as soon as it passes testing")
+	allCmd.Flags().BoolVar(&showScores, "scores", false, "print endpoints from the highest score with their score, latency, block and rate limit")
+	durationVar(allCmd.Flags(), &watchInterval, "watch", 0, "re-test endpoints at this interval and print changes until interrupted")
+	addBudgetFlags(allCmd)
+	addOutputFlags(allCmd)
+	allCmd.Flags().StringVar(&torProxy, "tor-proxy", "", "SOCKS5 address of a Tor proxy for .onion endpoints (e.g. 127.0.0.1:9050)")
+	allCmd.Flags().BoolVar(&noLint, "no-lint", false, "keep malformed URLs, URLs with credentials and API key templates")
+	allCmd.Flags().BoolVar(&skipKeyed, "skip-keyed", false, "drop URLs with API key templates no key was found for, even with --no-lint")
+	allCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	allCmd.Flags().BoolVar(&discoverDocker, "docker", false, "include running Docker containers publishing common RPC ports (8545, 8546, ...) as candidate endpoints")
+	allCmd.Flags().StringVar(&recordPath, "record", "", "write every probe request and response to this file, for analyzing the run offline with replay")
+	allCmd.Flags().StringSliceVar(&chainList, "chains", nil, "comma-separated chain IDs or names, tested together with the ones given as arguments")
 
 	cacheCmd.AddCommand(cacheCleanCmd)
 	cacheCmd.AddCommand(cacheBuildCmd)
+	cacheCmd.AddCommand(cacheStatusCmd)
+	cacheBuildCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	cacheBuildCmd.Flags().BoolVarP(&force, "force", "f", false, "download the full dataset even if it did not change")
+	addOutputFlags(cacheStatusCmd)
 
 	idCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
 	idCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
@@ -245,7 +1061,7 @@ func init() {
 	nameCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
 
 	// Set SilenceUsage and SilenceErrors for all commands to prevent automatic output on errors
-	commands := []*cobra.Command{rootCmd, allCmd, idCmd, nameCmd, cacheCmd, cacheCleanCmd, cacheBuildCmd, versionCmd}
+	commands := []*cobra.Command{rootCmd, allCmd, idCmd, nameCmd, infoCmd, listCmd, testnetsCmd, searchCmd, statsCmd, historyCmd, replayCmd, mockCmd, execCmd, envCmd, configSnippetCmd, compareCmd, metamaskCmd, sloCmd, callCmd, blockCmd, gasCmd, explorerCmd, currencyCmd, tagCmd, tagAddCmd, tagRemoveCmd, tagListCmd, policyCmd, policyExportCmd, policyImportCmd, resolveCmd, verifyCmd, watchCmd, drCheckCmd, assertCmd, exportCmd, exportGoCmd, cacheCmd, cacheCleanCmd, cacheBuildCmd, cacheStatusCmd, cacheInfoCmd, cacheStatsCmd, serveCmd, proxyCmd, versionCmd}
 	for _, cmd := range commands {
 		cmd.SilenceUsage = true
 		cmd.SilenceErrors = true
@@ -264,11 +1080,67 @@ func init() {
 	rootCmd.AddCommand(cacheCmd)
 	rootCmd.AddCommand(idCmd)
 	rootCmd.AddCommand(nameCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(testnetsCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(mockCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(configSnippetCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(metamaskCmd)
+	rootCmd.AddCommand(sloCmd)
+	rootCmd.AddCommand(callCmd)
+	rootCmd.AddCommand(blockCmd)
+	rootCmd.AddCommand(gasCmd)
+	rootCmd.AddCommand(explorerCmd)
+	rootCmd.AddCommand(currencyCmd)
+	rootCmd.AddCommand(tagCmd)
+	rootCmd.AddCommand(policyCmd)
+	rootCmd.AddCommand(resolveCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(drCheckCmd)
+	rootCmd.AddCommand(assertCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(proxyCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// splitList splits a comma-separated environment value
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envBool(key string) bool {
+	value, _ := strconv.ParseBool(os.Getenv(key))
+	return value
+}
+
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	saveProbeHistory()
+	// exec passes the exit code of the command on, which reported its own errors
+	var exitErr *childExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, formatError(err))
 		if paramErr, ok := err.(*ParameterError); ok {
 			fmt.Fprintln(os.Stderr, "")
@@ -278,6 +1150,6 @@ func main() {
 				rootCmd.Help()
 			}
 		}
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
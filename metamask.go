@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/snippet"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	walletMaxRPCs int
+	walletRequest bool
+)
+
+var metamaskCmd = &cobra.Command{
+	Use:   "metamask <chainId|chainName>",
+	Short: "Print the wallet_addEthereumChain payload of a chain",
+	Long:  "Prints the EIP-3085 wallet_addEthereumChain parameter of the chain, as MetaMask and other wallets expect it: the hexadecimal chain ID, the chain name, the native currency, the working HTTP endpoints from the fastest and the block explorers",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if walletMaxRPCs < 1 {
+			return NewParameterErrorWithCmd("--max must be at least 1", cmd)
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		// Wallets send requests over HTTP only
+		rpcUrls := make([]string, 0, len(chainData.RPCs))
+		for _, url := range extractRPCUrls(chainData.RPCs, false, false) {
+			if !isWebSocketURL(url) {
+				rpcUrls = append(rpcUrls, url)
+			}
+		}
+		if len(rpcUrls) == 0 {
+			return noRPCsError(chainData.RPCs)
+		}
+
+		if !noTest {
+			tester, err := newTester(cmd)
+			if err != nil {
+				return err
+			}
+			tester.WarmUp = true
+
+			results := tester.TestRPCs(rpcUrls, chainData.ChainID)
+			if len(results) == 0 {
+				return rpc.ErrNoRPCsFound
+			}
+			// Wallets use the first endpoint and fall back to the others
+			sort.SliceStable(results, func(i, j int) bool { return results[i].Latency < results[j].Latency })
+			reportResults(results...)
+
+			rpcUrls = rpcUrls[:0]
+			for _, result := range results {
+				rpcUrls = append(rpcUrls, result.URL)
+			}
+		}
+		if len(rpcUrls) > walletMaxRPCs {
+			rpcUrls = rpcUrls[:walletMaxRPCs]
+		}
+		for _, url := range rpcUrls {
+			warnInsecure(url)
+		}
+
+		explorerUrls := make([]string, 0, len(chainData.Explorers))
+		for _, explorer := range chainData.Explorers {
+			if explorer.URL != "" {
+				explorerUrls = append(explorerUrls, explorer.URL)
+			}
+		}
+
+		parameter := snippet.AddEthereumChain(snippetChain(chainData, "", ""), rpcUrls, explorerUrls)
+		if walletRequest {
+			return printJSON(map[string]any{
+				"method": "wallet_addEthereumChain",
+				"params": []snippet.AddEthereumChainParameter{parameter},
+			})
+		}
+		return printJSON(parameter)
+	},
+}
+
+func init() {
+	metamaskCmd.Flags().IntVar(&walletMaxRPCs, "max", 3, "maximum number of endpoints in rpcUrls")
+	metamaskCmd.Flags().BoolVar(&walletRequest, "request", false, "print the whole request, {method, params}, to pass to ethereum.request")
+	metamaskCmd.Flags().BoolVar(&noTest, "no-test", false, "use the listed endpoints without testing them")
+	metamaskCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	metamaskCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(metamaskCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	metamaskCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	metamaskCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// endpoints, which wallets only accept for local nodes")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"chain-rpc/pkg/mock"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mockChainID     uint64
+	mockListen      string
+	mockBlockTime   time.Duration
+	mockLatency     time.Duration
+	mockJitter      time.Duration
+	mockErrorRate   float64
+	mockErrorStatus int
+)
+
+var mockCmd = &cobra.Command{
+	Use:   "mock",
+	Short: "Serve a fake JSON-RPC endpoint for testing",
+	Long:  "Serves a minimal fake JSON-RPC endpoint over HTTP and WebSocket until interrupted: eth_chainId, net_version, an advancing eth_blockNumber, eth_syncing, web3_clientVersion, batches and newHeads subscriptions, with configurable latency and error injection. Useful to test scripts, and chain-rpc itself, without a real node.",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if mockErrorRate < 0 || mockErrorRate > 1 {
+			return NewParameterErrorWithCmd("error-rate must be between 0 and 1", cmd)
+		}
+		if mockErrorStatus != 0 && (mockErrorStatus < 400 || mockErrorStatus > 599) {
+			return NewParameterErrorWithCmd("error-status must be an HTTP error status (400-599), or 0 for JSON-RPC errors", cmd)
+		}
+		if mockBlockTime < 0 || mockLatency < 0 || mockJitter < 0 {
+			return NewParameterErrorWithCmd("block-time, latency and jitter must not be negative", cmd)
+		}
+
+		server := mock.NewServer(mockChainID)
+		server.BlockTime = mockBlockTime
+		server.Latency = mockLatency
+		server.Jitter = mockJitter
+		server.ErrorRate = mockErrorRate
+		server.ErrorStatus = mockErrorStatus
+		if verbose {
+			server.OnRequest = func(method string, injectedError bool) {
+				if injectedError {
+					fmt.Fprintf(os.Stderr, "[mock] %s %s: injected error\n", time.Now().Format("15:04:05.000"), method)
+				} else {
+					fmt.Fprintf(os.Stderr, "[mock] %s %s\n", time.Now().Format("15:04:05.000"), method)
+				}
+			}
+		}
+
+		listener, err := net.Listen("tcp", mockListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", mockListen, err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		httpServer := &http.Server{Handler: server}
+		go func() {
+			<-ctx.Done()
+			httpServer.Close()
+		}()
+
+		address := listener.Addr().String()
+		notef("Serving chain %d at http://%s and ws://%s, interrupt to stop", mockChainID, address, address)
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	mockCmd.Flags().Uint64Var(&mockChainID, "chain-id", 31337, "chain ID answered to eth_chainId")
+	mockCmd.Flags().StringVar(&mockListen, "listen", "127.0.0.1:8545", "address to listen on, e.g. :8545 for all interfaces")
+	durationVar(mockCmd.Flags(), &mockBlockTime, "block-time", 2*time.Second, "how often the block number advances (0: never)")
+	durationVar(mockCmd.Flags(), &mockLatency, "latency", 0, "delay before every answer")
+	durationVar(mockCmd.Flags(), &mockJitter, "jitter", 0, "random extra delay, up to this much")
+	mockCmd.Flags().Float64Var(&mockErrorRate, "error-rate", 0, "share of requests failed on purpose, from 0 to 1")
+	mockCmd.Flags().IntVar(&mockErrorStatus, "error-status", 0, "HTTP status of failed requests, e.g. 429 or 503 (0: JSON-RPC internal error)")
+	mockCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "log every request to stderr")
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var chainList []string
+
+// chainIdentifiers returns the chains given as arguments followed by the ones of --chains
+func chainIdentifiers(cmd *cobra.Command, args []string) ([]string, error) {
+	identifiers := append(append([]string{}, args...), chainList...)
+	if len(identifiers) == 0 {
+		return nil, NewParameterErrorWithCmd("requires at least one chain, as an argument or with --chains", cmd)
+	}
+	return identifiers, nil
+}
+
+// getChainsData resolves every identifier against a single load of the cache.
+// A chain given twice, e.g. by ID and by name, is only returned once.
+func getChainsData(identifiers []string) ([]*chain.ChainData, error) {
+	dataset, err := chain.LoadDataset()
+	if err != nil {
+		return nil, err
+	}
+
+	chains := make([]*chain.ChainData, 0, len(identifiers))
+	seen := make(map[uint64]bool, len(identifiers))
+	for _, identifier := range identifiers {
+		var chainData *chain.ChainData
+		if chainId, parseErr := strconv.ParseUint(identifier, 10, 64); parseErr == nil {
+			chainData, err = dataset.ChainByID(chainId)
+		} else {
+			chainData, err = dataset.ChainByName(identifier)
+
+			var ambiguous *chain.AmbiguousNameError
+			if errors.As(err, &ambiguous) && isInteractive() {
+				var chainId uint64
+				if chainId, err = pickAmbiguousChain(ambiguous); err == nil {
+					chainData, err = dataset.ChainByID(chainId)
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", identifier, err)
+		}
+
+		if !seen[chainData.ChainID] {
+			seen[chainData.ChainID] = true
+			chains = append(chains, chainData)
+		}
+	}
+	return chains, nil
+}
+
+// runMultiChain finds working endpoints for several chains at once, one per chain
+// or all of them, and prints them grouped by chain. Chains without a working
+// endpoint are reported after the others were printed.
+func runMultiChain(cmd *cobra.Command, identifiers []string, all bool) error {
+	if stream || watchInterval > 0 {
+		return NewParameterErrorWithCmd("--stream and --watch take a single chain", cmd)
+	}
+
+	asJSON, err := isJSONOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	var strategy rpc.Strategy
+	if !all {
+		if strategy, err = selectionStrategy(cmd); err != nil {
+			return err
+		}
+	}
+
+	chains, err := getChainsData(identifiers)
+	if err != nil {
+		return err
+	}
+
+	working := make(map[uint64][]string, len(chains))
+	failed := make(map[uint64]error)
+	var toTest []rpc.ChainURLs
+	for _, chainData := range chains {
+		rpcUrls := extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)
+		switch {
+		case len(rpcUrls) == 0:
+			failed[chainData.ChainID] = noRPCsError(chainData.RPCs)
+		case noTest && all:
+			working[chainData.ChainID] = rpcUrls
+		case noTest:
+			working[chainData.ChainID] = rpcUrls[:1]
+		default:
+			if useCached {
+				if cachedRPCs := cachedWorkingRPCs(chainData.ChainID, rpcUrls); len(cachedRPCs) > 0 {
+					working[chainData.ChainID] = cachedRPCs
+					continue
+				}
+			}
+			toTest = append(toTest, rpc.ChainURLs{ChainID: chainData.ChainID, URLs: rpcUrls})
+		}
+	}
+
+	if len(toTest) > 0 {
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		if minScore > 0 || (strategy != nil && rpc.UsesScores(strategy)) {
+			enableScoring(tester, chains...)
+		}
+		testWorkingRPCs(tester, strategy, toTest, working)
+	}
+
+	// Cached endpoints count as equally fast, as for a single chain
+	if !all && !noTest {
+		for chainId, urls := range working {
+			results := make([]rpc.RPCResult, 0, len(urls))
+			for _, url := range urls {
+				results = append(results, rpc.RPCResult{URL: url})
+			}
+			working[chainId] = []string{strategy.Pick(results).URL}
+		}
+	}
+
+	for _, chainData := range chains {
+		if _, exists := working[chainData.ChainID]; !exists && failed[chainData.ChainID] == nil {
+			failed[chainData.ChainID] = rpc.ErrNoRPCsFound
+		}
+	}
+
+	if err := printChainURLs(chains, working, all, asJSON); err != nil {
+		return err
+	}
+	return multiChainError(chains, failed)
+}
+
+// testWorkingRPCs tests the chains at once and adds the working endpoints to working
+func testWorkingRPCs(tester *rpc.Tester, strategy rpc.Strategy, chains []rpc.ChainURLs, working map[uint64][]string) {
+	if strategy != nil {
+		if _, ok := rpc.UnwrapStrategy(strategy).(rpc.FastestStrategy); ok {
+			tester.WarmUp = true
+		}
+		for chainId, result := range rpc.NewSelector(tester, strategy).SelectChains(chains) {
+			reportResults(result)
+			working[chainId] = []string{result.URL}
+			saveWorkingRPCs(chainId, nil, []string{result.URL})
+		}
+		return
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	results := tester.TestChains(chains)
+	for _, tested := range chains {
+		reportResults(results[tested.ChainID]...)
+		urls := make([]string, 0, len(results[tested.ChainID]))
+		for _, result := range results[tested.ChainID] {
+			urls = append(urls, result.URL)
+		}
+		saveWorkingRPCs(tested.ChainID, tested.URLs, urls)
+		if len(urls) == 0 {
+			continue
+		}
+
+		// Shuffle the results for better load distribution
+		r.Shuffle(len(urls), func(i, j int) {
+			urls[i], urls[j] = urls[j], urls[i]
+		})
+		working[tested.ChainID] = preferredFirst(urls)
+	}
+}
+
+// printChainURLs prints the endpoints under a header per chain, in the order the chains
+// were given. JSON maps chain IDs to the endpoint, or to the list of endpoints with all.
+func printChainURLs(chains []*chain.ChainData, working map[uint64][]string, all, asJSON bool) error {
+	for _, chainData := range chains {
+		warnInsecure(working[chainData.ChainID]...)
+	}
+
+	if asJSON && all {
+		return printJSON(working)
+	}
+	if asJSON {
+		picked := make(map[uint64]string, len(working))
+		for chainId, urls := range working {
+			picked[chainId] = urls[0]
+		}
+		return printJSON(picked)
+	}
+
+	first := true
+	for _, chainData := range chains {
+		urls, exists := working[chainData.ChainID]
+		if !exists {
+			continue
+		}
+		if !first {
+			fmt.Println()
+		}
+		first = false
+
+		fmt.Printf("# %s (%d)\n", chainData.Name, chainData.ChainID)
+		for _, url := range urls {
+			fmt.Println(url)
+		}
+	}
+	return nil
+}
+
+// multiChainError reports the chains left without an endpoint on stderr and fails if there are any
+func multiChainError(chains []*chain.ChainData, failed map[uint64]error) error {
+	var failedIDs []string
+	for _, chainData := range chains {
+		if err, exists := failed[chainData.ChainID]; exists {
+			warnf("%s (%d): %v", chainData.Name, chainData.ChainID, err)
+			failedIDs = append(failedIDs, strconv.FormatUint(chainData.ChainID, 10))
+		}
+	}
+	if len(failedIDs) > 0 {
+		return withExitCode(EXIT_NO_ENDPOINTS, fmt.Errorf("no working rpc urls for chains %s", strings.Join(failedIDs, ", ")))
+	}
+	return nil
+}
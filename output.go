@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	outputFormat string
+	jsonOutput   bool
+)
+
+// addOutputFlags registers `--output` and its `--json` shorthand on commands with machine-readable output
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", outputText, "output format: text or json")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "shorthand for --output json")
+}
+
+// isJSONOutput reports whether the command should print JSON
+func isJSONOutput(cmd *cobra.Command) (bool, error) {
+	if jsonOutput {
+		return true, nil
+	}
+
+	switch outputFormat {
+	case outputText:
+		return false, nil
+	case outputJSON:
+		return true, nil
+	default:
+		return false, NewParameterErrorWithCmd(fmt.Sprintf("unknown output format '%s', expected text or json", outputFormat), cmd)
+	}
+}
+
+// cacheStatusForOutput returns the cache status attached to JSON output so that
+// consumers can tell when the data comes from an expired cache
+func cacheStatusForOutput() *chain.CacheStatus {
+	status, err := chain.GetCacheStatus()
+	if err != nil {
+		return nil
+	}
+	return status
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/manifoldco/promptui"
+)
+
+var noInteractive bool
+
+// fetchChainDataByName looks the chain up by name, letting the user pick one
+// when the name is ambiguous and the session is interactive
+func fetchChainDataByName(name string) (*chain.ChainData, error) {
+	chainData, err := chain.FetchChainDataByName(name)
+
+	var ambiguous *chain.AmbiguousNameError
+	if errors.As(err, &ambiguous) && isInteractive() {
+		chainId, err := pickAmbiguousChain(ambiguous)
+		if err != nil {
+			return nil, err
+		}
+		return chain.FetchChainData(chainId)
+	}
+
+	return chainData, err
+}
+
+// isInteractive reports whether the user can be prompted without breaking scripts
+func isInteractive() bool {
+	return !noInteractive && isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// pickAmbiguousChain shows an arrow-key selection of the candidates of an ambiguous name
+func pickAmbiguousChain(ambiguous *chain.AmbiguousNameError) (uint64, error) {
+	items := make([]string, 0, len(ambiguous.Candidates))
+	for _, candidate := range ambiguous.Candidates {
+		items = append(items, fmt.Sprintf("%s (%d)", candidate.Name, candidate.ChainID))
+	}
+
+	prompt := promptui.Select{
+		Label:  fmt.Sprintf("Multiple chains match '%s'", ambiguous.Name),
+		Items:  items,
+		Size:   10,
+		Stdout: os.Stderr,
+	}
+
+	index, _, err := prompt.Run()
+	if err != nil {
+		if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+			return 0, fmt.Errorf("chain selection cancelled")
+		}
+		return 0, fmt.Errorf("chain selection failed: %v", err)
+	}
+
+	return ambiguous.Candidates[index].ChainID, nil
+}
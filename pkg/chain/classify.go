@@ -0,0 +1,131 @@
+package chain
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// testnetMarkers are name fragments that identify test networks
+var testnetMarkers = []string{"testnet", "devnet", "sepolia", "goerli", "holesky", "hoodi", "ropsten", "rinkeby", "kovan", "mumbai", "amoy", "fuji", "chiado"}
+
+// SLIP44_TESTNET is the SLIP-0044 coin type shared by all test networks
+const SLIP44_TESTNET = 1
+
+// IsTestnet tells whether the chain is a test network, as its source declares or as
+// classified when the cache was built, otherwise as guessed now
+func IsTestnet(chainData *ChainData) bool {
+	if chainData.Testnet != nil {
+		return *chainData.Testnet
+	}
+	return classifyTestnet(chainData)
+}
+
+// classifyTestnet guesses whether the chain is a test network from its data: the
+// testnet SLIP-0044 coin type, faucets to get test coins from, or its names
+func classifyTestnet(chainData *ChainData) bool {
+	if chainData.Slip44 != nil && *chainData.Slip44 == SLIP44_TESTNET {
+		return true
+	}
+	var faucets []string
+	if raw, exists := chainData.Extra["faucets"]; exists && json.Unmarshal(raw, &faucets) == nil && len(faucets) > 0 {
+		return true
+	}
+	for _, name := range []string{chainData.Name, chainData.ShortName, chainData.ChainSlug} {
+		name = normalizeChainName(name)
+		for _, marker := range testnetMarkers {
+			if strings.Contains(name, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RelatedTestnets returns the test networks of a main network, guessed from their
+// names: a test network belongs to the main networks its name shares the most
+// leading words with, e.g. Base Sepolia to Base, and when it shares none, such as
+// Sepolia, to the main network named like the chain both belong to, Ethereum for ETH.
+func RelatedTestnets(mainnet *ChainData) ([]*ChainData, error) {
+	var mainnets, testnets []*ChainData
+	err := ForEachChain(func(chainData *ChainData) error {
+		if IsTestnet(chainData) {
+			testnets = append(testnets, chainData)
+		} else {
+			mainnets = append(mainnets, chainData)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	related := make([]*ChainData, 0)
+	for _, testnet := range testnets {
+		if slices.Contains(testnetOwners(testnet, mainnets), mainnet.ChainID) {
+			related = append(related, testnet)
+		}
+	}
+	sort.Slice(related, func(i, j int) bool {
+		return related[i].ChainID < related[j].ChainID
+	})
+	return related, nil
+}
+
+// testnetOwners returns the IDs of the main networks the test network belongs to
+func testnetOwners(testnet *ChainData, mainnets []*ChainData) []uint64 {
+	var owners []uint64
+	longest := 0
+	for _, mainnet := range mainnets {
+		shared := sharedLeadingWords(testnet.Name, mainnet.Name)
+		if shared == 0 || shared < longest {
+			continue
+		}
+		if shared > longest {
+			longest = shared
+			owners = owners[:0]
+		}
+		owners = append(owners, mainnet.ChainID)
+	}
+	if len(owners) > 0 {
+		return owners
+	}
+
+	chainName := normalizeChainName(testnet.Chain)
+	for _, mainnet := range mainnets {
+		if normalizeChainName(mainnet.Chain) != chainName {
+			continue
+		}
+		if chainName == normalizeChainName(mainnet.ShortName) || chainName == normalizeChainName(mainnet.ChainSlug) {
+			owners = append(owners, mainnet.ChainID)
+		}
+	}
+	return owners
+}
+
+// sharedLeadingWords counts the leading words two chain names have in common
+func sharedLeadingWords(a, b string) int {
+	wordsA, wordsB := strings.Fields(strings.ToLower(a)), strings.Fields(strings.ToLower(b))
+	shared := 0
+	for shared < len(wordsA) && shared < len(wordsB) && wordsA[shared] == wordsB[shared] {
+		shared++
+	}
+	return shared
+}
+
+// HasFeature reports whether the chain declares support for the feature, e.g. EIP1559
+func (c *ChainData) HasFeature(name string) bool {
+	name = normalizeFeatureName(name)
+	for _, feature := range c.Features {
+		if normalizeFeatureName(feature.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFeatureName makes "EIP-1559", "eip1559" and "EIP1559" equal
+func normalizeFeatureName(name string) string {
+	return strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(name)), "-", "")
+}
@@ -0,0 +1,67 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Validator holds the HTTP cache validators of a downloaded dataset
+type Validator struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// errNotModified is returned by a source whose dataset did not change since the cache was built
+var errNotModified = errors.New("chains data not modified")
+
+type validatorsKey struct{}
+
+// withValidators makes the downloads under ctx conditional on the dataset having changed
+func withValidators(ctx context.Context, validators map[string]Validator) context.Context {
+	if len(validators) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, validatorsKey{}, validators)
+}
+
+// setConditionalHeaders adds If-None-Match and If-Modified-Since when the URL was downloaded before
+func setConditionalHeaders(req *http.Request) {
+	validators, _ := req.Context().Value(validatorsKey{}).(map[string]Validator)
+	validator, exists := validators[req.URL.String()]
+	if !exists {
+		return
+	}
+
+	if validator.ETag != "" {
+		req.Header.Set("If-None-Match", validator.ETag)
+	}
+	if validator.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validator.LastModified)
+	}
+}
+
+// notModifiedMeta describes a 304 response, keeping the validators that were sent
+// unless the server provided new ones
+func notModifiedMeta(req *http.Request, resp *http.Response) *CacheMeta {
+	sourceURL := req.URL.String()
+	meta := newCacheMeta(sourceURL, resp.Header, time.Now())
+
+	validators, _ := req.Context().Value(validatorsKey{}).(map[string]Validator)
+	validator := validators[sourceURL]
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		validator.ETag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		validator.LastModified = lastModified
+	}
+	meta.Validators = map[string]Validator{sourceURL: validator}
+	return meta
+}
+
+// responseValidator returns the validators of a dataset download
+func responseValidator(header http.Header) (Validator, bool) {
+	validator := Validator{ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}
+	return validator, validator != Validator{}
+}
@@ -0,0 +1,70 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Dataset is the cache loaded into memory once, for looking up several chains
+// without reading the cache file again for each of them
+type Dataset struct {
+	cache CacheData
+	// err is why the cache could not be loaded, only local chains can be looked up then
+	err error
+}
+
+// LoadDataset ensures the cache exists and loads it whole. When the cache is
+// unavailable the dataset still resolves the chains of the local registry.
+func LoadDataset() (*Dataset, error) {
+	if err := ensureCacheExists(); err != nil {
+		if len(localChains) == 0 {
+			return nil, err
+		}
+		return &Dataset{err: err}, nil
+	}
+
+	cacheMux.RLock()
+	defer cacheMux.RUnlock()
+
+	file, err := os.Open(cacheFile())
+	if err != nil {
+		return nil, openCacheError(err)
+	}
+	defer file.Close()
+
+	var dataset Dataset
+	if err := json.NewDecoder(file).Decode(&dataset.cache); err != nil {
+		return nil, fmt.Errorf("failed to decode cache file: %v", err)
+	}
+	return &dataset, nil
+}
+
+// ChainByID returns the chain with the local registry applied
+func (d *Dataset) ChainByID(chainId uint64) (*ChainData, error) {
+	cached, exists := d.cache.ByID[chainId]
+	if !exists {
+		if chainData, ok := localChain(chainId); ok {
+			return chainData, nil
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		return nil, ErrChainNotFound
+	}
+
+	chainData := *cached
+	return overlayLocalChain(&chainData), nil
+}
+
+// ChainByName resolves the name like FetchChainDataByName does
+func (d *Dataset) ChainByName(name string) (*ChainData, error) {
+	chainId, err := findChainIDByName(addLocalNames(d.cache.ByName), normalizeChainName(name))
+	if err != nil {
+		if d.err != nil {
+			return nil, d.err
+		}
+		return nil, err
+	}
+	return d.ChainByID(chainId)
+}
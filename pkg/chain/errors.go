@@ -0,0 +1,41 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Kinds of errors, to tell apart with errors.Is. The errors returned carry more details
+// in their message.
+var (
+	ErrChainNotFound = errors.New("specified chain does not exist or is not known at `chainlist.org`")
+	// ErrAmbiguousName is the kind of AmbiguousNameError, which holds the candidates
+	ErrAmbiguousName = errors.New("chain name matches several chains")
+	// ErrCacheMiss is returned when there is no cache and it cannot be built
+	ErrCacheMiss = errors.New("chain data cache is missing")
+	// ErrCacheStale is returned when the cache expired and cannot be refreshed
+	ErrCacheStale = errors.New("chain data cache is expired")
+)
+
+// kindError is an error of a kind with a message of its own
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string { return e.message }
+
+func (e *kindError) Unwrap() error { return e.kind }
+
+func errorOfKind(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+// openCacheError is the error of failing to open the cache file, ErrCacheMiss when it is missing
+func openCacheError(err error) error {
+	if os.IsNotExist(err) {
+		return errorOfKind(ErrCacheMiss, "failed to open cache file: %v", err)
+	}
+	return fmt.Errorf("failed to open cache file: %v", err)
+}
@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// ARCHIVE_FETCH_TIMEOUT is longer than FETCH_TIMEOUT since the repository archive is much larger than the dataset
+const ARCHIVE_FETCH_TIMEOUT = 3 * time.Minute
+
+var archiveClient = &http.Client{Timeout: ARCHIVE_FETCH_TIMEOUT}
+
+// ethereumListsSource reads the chain files straight from the ethereum-lists/chains
+// repository archive, which is ahead of the sites built from it
+type ethereumListsSource struct{}
+
+func (ethereumListsSource) Name() string {
+	return "ethereum-lists"
+}
+
+func (ethereumListsSource) Fetch(ctx context.Context) ([]ChainData, *CacheMeta, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", ETHEREUM_LISTS_ARCHIVE_URL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch ethereum-lists archive: %v", err)
+	}
+	setConditionalHeaders(req)
+
+	resp, err := archiveClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch ethereum-lists archive: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, notModifiedMeta(req, resp), errNotModified
+	}
+	if resp.StatusCode != 200 {
+		return nil, nil, fmt.Errorf("failed to fetch ethereum-lists archive: HTTP %d", resp.StatusCode)
+	}
+
+	chains, err := readChainsArchive(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := newCacheMeta(ETHEREUM_LISTS_ARCHIVE_URL, resp.Header, time.Now())
+	meta.ChainCount = len(chains)
+	return chains, meta, nil
+}
+
+// readChainsArchive extracts the `_data/chains/*.json` files of a gzipped repository tarball
+func readChainsArchive(r io.Reader) ([]ChainData, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ethereum-lists archive: %v", err)
+	}
+	defer gz.Close()
+
+	chains := make([]ChainData, 0)
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ethereum-lists archive: %v", err)
+		}
+
+		// Entries are prefixed with the repository directory, e.g. chains-master/_data/chains/eip155-1.json
+		if header.Typeflag != tar.TypeReg || path.Ext(header.Name) != ".json" || !strings.HasSuffix(path.Dir(header.Name), "_data/chains") {
+			continue
+		}
+
+		var chainData ChainData
+		if err := json.NewDecoder(archive).Decode(&chainData); err != nil {
+			logger.Warn("skipping chain file", "file", header.Name, "error", err)
+			continue
+		}
+		chains = append(chains, chainData)
+	}
+
+	return chains, nil
+}
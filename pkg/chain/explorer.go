@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EXPLORER_STANDARD is the explorer standard defining the paths of deep links
+const EXPLORER_STANDARD = "EIP3091"
+
+var (
+	txHashPattern  = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+	addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+)
+
+// PrimaryExplorer returns the first explorer of the chain following EIP-3091, or
+// its first explorer when none does
+func (c *ChainData) PrimaryExplorer() (Explorer, bool) {
+	for _, explorer := range c.Explorers {
+		if explorer.URL != "" && explorer.FollowsEIP3091() {
+			return explorer, true
+		}
+	}
+	for _, explorer := range c.Explorers {
+		if explorer.URL != "" {
+			return explorer, true
+		}
+	}
+	return Explorer{}, false
+}
+
+// FollowsEIP3091 reports whether the explorer declares the EIP-3091 paths of deep links
+func (e Explorer) FollowsEIP3091() bool {
+	return normalizeFeatureName(e.Standard) == EXPLORER_STANDARD
+}
+
+// ExplorerLink returns the EIP-3091 link of the explorer to a transaction hash,
+// an address or a block number, told apart by their shape
+func ExplorerLink(explorer Explorer, target string) (string, error) {
+	base := strings.TrimSuffix(explorer.URL, "/")
+	switch {
+	case txHashPattern.MatchString(target):
+		return base + "/tx/" + target, nil
+	case addressPattern.MatchString(target):
+		return base + "/address/" + target, nil
+	default:
+		if _, err := strconv.ParseUint(target, 10, 64); err == nil {
+			return base + "/block/" + target, nil
+		}
+		return "", fmt.Errorf("'%s' is neither a transaction hash, an address nor a block number", target)
+	}
+}
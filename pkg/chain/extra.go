@@ -0,0 +1,74 @@
+package chain
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// chainDataFields has the ChainData fields without its JSON methods
+type chainDataFields ChainData
+
+// knownFields are the JSON keys mapped to ChainData fields
+var knownFields = jsonFieldNames(reflect.TypeOf(chainDataFields{}))
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// UnmarshalJSON decodes the known fields and keeps all others in Extra
+func (c *ChainData) UnmarshalJSON(data []byte) error {
+	var fields chainDataFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for name := range raw {
+		if knownFields[name] {
+			delete(raw, name)
+		}
+	}
+
+	*c = ChainData(fields)
+	if len(raw) > 0 {
+		c.Extra = raw
+	}
+	return nil
+}
+
+// MarshalJSON encodes the known fields together with the preserved Extra fields
+func (c ChainData) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(chainDataFields(c))
+	if err != nil || len(c.Extra) == 0 {
+		return data, err
+	}
+
+	return mergeJSONObject(data, c.Extra)
+}
+
+// mergeJSONObject adds fields to the encoded JSON object without overriding existing keys
+func mergeJSONObject(data []byte, fields map[string]json.RawMessage) ([]byte, error) {
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+
+	for name, value := range fields {
+		if _, exists := merged[name]; !exists {
+			merged[name] = value
+		}
+	}
+
+	return json.Marshal(merged)
+}
@@ -1,11 +1,16 @@
 package chain
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +22,23 @@ type RPC struct {
 	Tracking string `json:"tracking"`
 }
 
+// UnmarshalJSON accepts both chainlist objects and plain ethereum-lists URL strings
+func (r *RPC) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*r = RPC{URL: url}
+		return nil
+	}
+
+	type rpcFields RPC
+	var fields rpcFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*r = RPC(fields)
+	return nil
+}
+
 type NativeCurrency struct {
 	Name     string `json:"name"`
 	Symbol   string `json:"symbol"`
@@ -29,6 +51,14 @@ type Explorer struct {
 	Standard string `json:"standard"`
 }
 
+type Feature struct {
+	Name string `json:"name"`
+}
+
+type ENS struct {
+	Registry string `json:"registry"`
+}
+
 type ChainData struct {
 	Name           string         `json:"name"`
 	Chain          string         `json:"chain"`
@@ -38,6 +68,16 @@ type ChainData struct {
 	ChainID        uint64         `json:"chainId"`
 	Explorers      []Explorer     `json:"explorers"`
 	ChainSlug      string         `json:"chainSlug"`
+	ENS            *ENS           `json:"ens,omitempty"`
+	Features       []Feature      `json:"features,omitempty"`
+	// Slip44 is the SLIP-0044 coin type used in HD wallet derivation paths
+	Slip44 *uint64 `json:"slip44,omitempty"`
+	// Testnet tells whether the chain is a test network, as declared by its source or
+	// classified when the cache was built. Use IsTestnet, which also classifies chains
+	// without it.
+	Testnet *bool `json:"isTestnet,omitempty"`
+	// Extra holds source fields without first-class support, kept verbatim
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type NameToIdMap = map[string]uint64
@@ -48,23 +88,58 @@ type CacheData struct {
 }
 
 var (
-	cacheMux     sync.RWMutex
-	cacheFile    string
-	isVerbose    bool
+	cacheMux sync.RWMutex
+	// cacheDir is empty until SetCacheDir, for the default to be resolved on use
+	cacheDir     string
 	forceRebuild bool
+	isReadOnly   bool
 )
 
 const (
 	CHAINS_DATA_URL = "https://chainlist.org/rpcs.json"
 	CACHE_TTL       = 30 * 24 * time.Hour // 1 month
+	FETCH_TIMEOUT   = 30 * time.Second
 )
 
-var (
-	ErrChainNotFound = fmt.Errorf("specified chain does not exist or is not known at `chainlist.org`")
-)
+var httpClient = &http.Client{Timeout: FETCH_TIMEOUT}
+
+// SetProxy makes dataset downloads go through the proxy the function returns for each
+// request, like http.Transport.Proxy. Without one, the proxy of the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables is used.
+func SetProxy(proxy func(*http.Request) (*url.URL, error)) {
+	var transport http.RoundTripper
+	if proxy != nil {
+		proxyTransport := http.DefaultTransport.(*http.Transport).Clone()
+		proxyTransport.Proxy = proxy
+		transport = proxyTransport
+	}
+	httpClient.Transport = transport
+	archiveClient.Transport = transport
+}
 
-func SetVerbose(verbose bool) {
-	isVerbose = verbose
+// NameMatch is a cached chain name matching a lookup
+type NameMatch struct {
+	Name    string `json:"name"`
+	ChainID uint64 `json:"chainId"`
+}
+
+// AmbiguousNameError is returned when a chain name matches several chains
+type AmbiguousNameError struct {
+	Name       string
+	Candidates []NameMatch
+}
+
+// Is makes AmbiguousNameError an ErrAmbiguousName for errors.Is
+func (e *AmbiguousNameError) Is(target error) bool {
+	return target == ErrAmbiguousName
+}
+
+func (e *AmbiguousNameError) Error() string {
+	errMsg := fmt.Sprintf("found multiple chains matching '%s':\n", e.Name)
+	for _, candidate := range e.Candidates {
+		errMsg += fmt.Sprintf("- %s\n", candidate.Name)
+	}
+	return errMsg + " \nPlease specify a more precise name"
 }
 
 func SetForceRebuild(force bool) {
@@ -75,24 +150,68 @@ func normalizeChainName(name string) string {
 	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
 }
 
-func verbosePrintf(format string, args ...any) {
-	if isVerbose {
-		fmt.Printf(format, args...)
+// SetCacheDir moves the cache, the endpoint history and the working endpoints to dir,
+// which is created on the first write. It defaults to chain-rpc in the user cache
+// directory.
+func SetCacheDir(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid cache directory: %v", err)
 	}
+
+	cacheMux.Lock()
+	defer cacheMux.Unlock()
+	cacheDir = absDir
+	return nil
+}
+
+// SetReadOnly forbids writing to the cache directory: lookups fail rather than build
+// a missing or expired cache, test results and the endpoint history are not recorded,
+// and the directory is never created.
+func SetReadOnly(readOnly bool) {
+	cacheMux.Lock()
+	defer cacheMux.Unlock()
+	isReadOnly = readOnly
 }
 
-func init() {
+// CacheDir returns the directory of the cache
+func CacheDir() string {
+	cacheMux.RLock()
+	defer cacheMux.RUnlock()
+	return resolvedCacheDir()
+}
+
+// resolvedCacheDir returns the cache directory, chain-rpc in the user cache directory
+// unless SetCacheDir was called
+func resolvedCacheDir() string {
+	if cacheDir != "" {
+		return cacheDir
+	}
 	userCacheDir, err := os.UserCacheDir()
 	if err != nil {
 		userCacheDir = os.TempDir()
 	}
-	cacheDir := filepath.Join(userCacheDir, "chain-rpc")
-	os.MkdirAll(cacheDir, 0755)
-	cacheFile = filepath.Join(cacheDir, "cache.json")
+	return filepath.Join(userCacheDir, "chain-rpc")
+}
+
+func cacheFile() string {
+	return filepath.Join(resolvedCacheDir(), "cache.json")
+}
+
+// ensureCacheDir creates the cache directory, before writing to it
+func ensureCacheDir() error {
+	if err := os.MkdirAll(resolvedCacheDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	return nil
 }
 
 func FetchChainData(chainId uint64) (*ChainData, error) {
 	if err := ensureCacheExists(); err != nil {
+		// Locally defined chains do not need the dataset
+		if chainData, ok := localChain(chainId); ok {
+			return chainData, nil
+		}
 		return nil, err
 	}
 
@@ -101,6 +220,12 @@ func FetchChainData(chainId uint64) (*ChainData, error) {
 
 func FetchChainDataByName(name string) (*ChainData, error) {
 	if err := ensureCacheExists(); err != nil {
+		// Locally defined chains do not need the dataset
+		if chainId, lookupErr := findChainIDByName(addLocalNames(nil), normalizeChainName(name)); lookupErr == nil {
+			if chainData, ok := localChain(chainId); ok {
+				return chainData, nil
+			}
+		}
 		return nil, err
 	}
 
@@ -111,12 +236,25 @@ func ensureCacheExists() error {
 	cacheMux.Lock()
 	defer cacheMux.Unlock()
 
+	if isReadOnly {
+		return checkReadOnlyCache()
+	}
+
+	// Offline, any existing cache is good enough
+	if isOffline {
+		if _, err := os.Stat(cacheFile()); err == nil && !forceRebuild {
+			return nil
+		}
+		return buildCacheFromSnapshot()
+	}
+
 	// Check if cache file exists and is not expired (unless force rebuild is requested)
 	cacheExists := false
 	if !forceRebuild {
-		if stat, err := os.Stat(cacheFile); err == nil {
-			// Check if cache is not expired
-			if time.Since(stat.ModTime()) < CACHE_TTL {
+		if meta, err := loadCacheMeta(); err == nil {
+			// Check if cache is not expired and was built from the selected sources
+			// and in the layout of this build
+			if time.Now().Before(meta.ExpiresAt) && selectionMatches(meta) && meta.SchemaVersion == CACHE_SCHEMA_VERSION {
 				cacheExists = true
 			}
 		}
@@ -129,36 +267,84 @@ func ensureCacheExists() error {
 	// Cache doesn't exist, is invalid, or expired - try to build it
 	if err := buildCache(); err != nil {
 		// If we failed to build cache but have an old cache, use it
-		if _, readErr := os.Stat(cacheFile); readErr == nil {
-			verbosePrintf("Warning: Failed to update cache (%v), using existing cache\n", err)
+		if _, readErr := os.Stat(cacheFile()); readErr == nil {
+			logger.Warn("failed to update cache, using existing cache", "error", err)
+			if metaErr := recordRefreshFailure(err); metaErr != nil {
+				logger.Warn("failed to record refresh failure", "error", metaErr)
+			}
 			return nil
 		}
-		// No existing cache and failed to build new one
-		return err
+		// No existing cache and failed to build new one, fall back to the embedded snapshot
+		logger.Warn("failed to build cache, using the embedded snapshot", "error", err)
+		if snapshotErr := buildCacheFromSnapshot(); snapshotErr != nil {
+			return errorOfKind(ErrCacheMiss, "%v", err)
+		}
+		if metaErr := recordRefreshFailure(err); metaErr != nil {
+			logger.Warn("failed to record refresh failure", "error", metaErr)
+		}
+		return nil
 	}
 
 	return nil
 }
 
-func buildCache() error {
-	verbosePrintf("Fetching and building chain data cache...\n")
+// checkReadOnlyCache fails unless the cache can be used as it is
+func checkReadOnlyCache() error {
+	if forceRebuild {
+		return fmt.Errorf("the cache is read-only and cannot be rebuilt")
+	}
+	if _, err := os.Stat(cacheFile()); err != nil {
+		return errorOfKind(ErrCacheMiss, "the cache is read-only and there is no cache at %s, build it with `chain-rpc cache build` first", cacheFile())
+	}
+	// Offline, any existing cache is good enough
+	if isOffline {
+		return nil
+	}
 
-	// Fetch all chains data
-	resp, err := http.Get(CHAINS_DATA_URL)
+	meta, err := loadCacheMeta()
 	if err != nil {
-		return fmt.Errorf("failed to fetch chains data: %v", err)
+		return fmt.Errorf("the cache is read-only and its metadata is unreadable: %v", err)
 	}
-	defer resp.Body.Close()
+	if !selectionMatches(meta) {
+		return errorOfKind(ErrCacheStale, "the cache is read-only and was built from other sources than the selected ones, rebuild it with `chain-rpc cache build` or pass --offline to use it anyway")
+	}
+	if !time.Now().Before(meta.ExpiresAt) {
+		return errorOfKind(ErrCacheStale, "the cache is read-only and expired on %s, refresh it with `chain-rpc cache build` or pass --offline to use it anyway", meta.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to fetch chains data: HTTP %d", resp.StatusCode)
+func buildCache() error {
+	logger.Info("fetching and building chain data cache", "file", cacheFile())
+
+	// Unless forced, only download the datasets that changed since the cache was built.
+	// A 304 keeps the cache file, so without one the datasets are downloaded in full.
+	ctx := context.Background()
+	previous, err := loadCacheMeta()
+	_, statErr := os.Stat(cacheFile())
+	if err == nil && statErr == nil && !forceRebuild && selectionMatches(previous) && previous.SchemaVersion == CACHE_SCHEMA_VERSION {
+		ctx = withValidators(ctx, previous.Validators)
 	}
 
-	var chains []ChainData
-	if err := json.NewDecoder(resp.Body).Decode(&chains); err != nil {
-		return fmt.Errorf("failed to parse chains data: %v", err)
+	// Fetch all chains data
+	chains, meta, err := fetchChains(ctx)
+	if err == errNotModified {
+		logger.Info("chain data not modified, keeping the cache")
+		meta.SourceTime = previous.SourceTime
+		meta.ChainCount = previous.ChainCount
+		meta.SourceStats = previous.SourceStats
+		meta.SchemaVersion = previous.SchemaVersion
+		return saveCacheMeta(meta)
+	}
+	if err != nil {
+		return err
 	}
 
+	return writeCache(chains, meta)
+}
+
+// writeCache indexes the chains and replaces the cache and its metadata
+func writeCache(chains []ChainData, meta *CacheMeta) error {
 	// Process chains concurrently
 	cacheData := &CacheData{
 		ByID:   make(map[uint64]*ChainData),
@@ -169,6 +355,11 @@ func buildCache() error {
 	var mu sync.Mutex
 
 	for i := range chains {
+		if chains[i].Testnet == nil {
+			testnet := classifyTestnet(&chains[i])
+			chains[i].Testnet = &testnet
+		}
+
 		wg.Add(1)
 		go func(chain *ChainData) {
 			defer wg.Done()
@@ -198,18 +389,83 @@ func buildCache() error {
 		return fmt.Errorf("failed to serialize cache: %v", err)
 	}
 
-	if err := os.WriteFile(cacheFile, data, 0644); err != nil {
+	if err := ensureCacheDir(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(cacheFile(), data, 0644); err != nil {
 		return fmt.Errorf("failed to write cache: %v", err)
 	}
 
-	verbosePrintf("Cache built successfully with %d chains\n", len(cacheData.ByID))
+	meta.SchemaVersion = CACHE_SCHEMA_VERSION
+	if err := saveCacheMeta(meta); err != nil {
+		return err
+	}
+
+	logger.Info("cache built", "chains", len(cacheData.ByID))
 	return nil
 }
 
+// fetchChainsFrom downloads a JSON array of chains. With verify, the configured
+// checksum and signature of the chainlist dataset are enforced.
+func fetchChainsFrom(ctx context.Context, dataURL string, verify bool) ([]ChainData, *CacheMeta, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", dataURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch chains data: %v", err)
+	}
+	setConditionalHeaders(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch chains data: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, notModifiedMeta(req, resp), errNotModified
+	}
+	if resp.StatusCode != 200 {
+		return nil, nil, fmt.Errorf("failed to fetch chains data: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch chains data: %v", err)
+	}
+
+	if verify {
+		if err := verifyChainsData(dataURL, data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var chains []ChainData
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse chains data: %v", err)
+	}
+
+	meta := newCacheMeta(dataURL, resp.Header, time.Now())
+	meta.ChainCount = len(chains)
+	return chains, meta, nil
+}
+
+// loadChainByID reads the chain from the cache with the local registry applied
 func loadChainByID(chainId uint64) (*ChainData, error) {
-	file, err := os.Open(cacheFile)
+	chainData, err := loadCachedChainByID(chainId)
+	if errors.Is(err, ErrChainNotFound) {
+		if chainData, ok := localChain(chainId); ok {
+			return chainData, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return overlayLocalChain(chainData), nil
+}
+
+func loadCachedChainByID(chainId uint64) (*ChainData, error) {
+	file, err := os.Open(cacheFile())
 	if err != nil {
-		return nil, fmt.Errorf("failed to open cache file: %v", err)
+		return nil, openCacheError(err)
 	}
 	defer file.Close()
 
@@ -246,7 +502,12 @@ func loadChainByName(name string) (*ChainData, error) {
 	normalizedName := normalizeChainName(name)
 
 	// First, find the chain ID from byName mapping
-	chainId, err := findChainIDByName(normalizedName)
+	nameMapping, err := loadNameMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	chainId, err := findChainIDByName(nameMapping, normalizedName)
 	if err != nil {
 		return nil, err
 	}
@@ -256,9 +517,9 @@ func loadChainByName(name string) (*ChainData, error) {
 }
 
 func loadNameMapping() (NameToIdMap, error) {
-	file, err := os.Open(cacheFile)
+	file, err := os.Open(cacheFile())
 	if err != nil {
-		return nil, fmt.Errorf("failed to open cache file: %v", err)
+		return nil, openCacheError(err)
 	}
 	defer file.Close()
 
@@ -267,16 +528,10 @@ func loadNameMapping() (NameToIdMap, error) {
 		return nil, fmt.Errorf("failed to decode cache file: %v", err)
 	}
 
-	return cacheData.ByName, nil
+	return addLocalNames(cacheData.ByName), nil
 }
 
-func findChainIDByName(normalizedName string) (uint64, error) {
-	// Load the entire name mapping into memory
-	nameMapping, err := loadNameMapping()
-	if err != nil {
-		return 0, err
-	}
-
+func findChainIDByName(nameMapping NameToIdMap, normalizedName string) (uint64, error) {
 	// Look up the chain ID
 	chainID, exists := nameMapping[normalizedName]
 	if !exists {
@@ -304,17 +559,25 @@ func findChainIdByPartialMatch(nameMapping NameToIdMap, name string) (uint64, er
 		}
 	}
 
-	if len(matchingKeys) == 1 {
-		return nameMapping[matchingKeys[0]], nil
-	} else if len(matchingKeys) > 1 {
-		errMsg := fmt.Sprintf("found multiple chains matching '%s':\n", name)
-		for _, key := range matchingKeys {
-			errMsg += fmt.Sprintf("- %s\n", key)
+	// Names, short names and slugs of the same chain are one candidate
+	sort.Strings(matchingKeys)
+	candidates := make([]NameMatch, 0, len(matchingKeys))
+	seen := make(map[uint64]bool, len(matchingKeys))
+	for _, key := range matchingKeys {
+		chainID := nameMapping[key]
+		if !seen[chainID] {
+			seen[chainID] = true
+			candidates = append(candidates, NameMatch{Name: key, ChainID: chainID})
 		}
-		return 0, fmt.Errorf("%s \nPlease specify a more precise name", errMsg)
 	}
 
-	return 0, fmt.Errorf("chain not found for name '%s'", name)
+	if len(candidates) == 1 {
+		return candidates[0].ChainID, nil
+	} else if len(candidates) > 1 {
+		return 0, &AmbiguousNameError{Name: name, Candidates: candidates}
+	}
+
+	return 0, errorOfKind(ErrChainNotFound, "chain not found for name '%s'", name)
 }
 
 func findChainInByID(decoder *json.Decoder, targetChainId uint64) (*ChainData, error) {
@@ -372,11 +635,21 @@ func CleanCache() error {
 	cacheMux.Lock()
 	defer cacheMux.Unlock()
 
-	if err := os.Remove(cacheFile); err != nil && !os.IsNotExist(err) {
+	if isReadOnly {
+		return fmt.Errorf("the cache is read-only")
+	}
+
+	if err := os.Remove(cacheFile()); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove cache file: %v", err)
 	}
+	if err := os.Remove(resultsFile()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove working rpcs file: %v", err)
+	}
+	if err := os.Remove(metaFile()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cache metadata file: %v", err)
+	}
 
-	verbosePrintf("Cache cleaned successfully\n")
+	logger.Info("cache cleaned")
 	return nil
 }
 
@@ -384,5 +657,12 @@ func BuildCache() error {
 	cacheMux.Lock()
 	defer cacheMux.Unlock()
 
+	if isReadOnly {
+		return fmt.Errorf("the cache is read-only")
+	}
+
+	if isOffline {
+		return buildCacheFromSnapshot()
+	}
 	return buildCache()
 }
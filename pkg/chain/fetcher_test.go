@@ -0,0 +1,60 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindChainIdByPartialMatch(t *testing.T) {
+	names := NameToIdMap{
+		"arbitrum-one":     42161,
+		"arb1":             42161,
+		"arbitrum-nova":    42170,
+		"arbitrum-sepolia": 421614,
+		"polygon-mainnet":  137,
+		"matic":            137,
+		"polygon":          137,
+	}
+
+	tests := []struct {
+		name       string
+		chainID    uint64
+		candidates []NameMatch
+	}{
+		{name: "polygon", chainID: 137},
+		{name: "arbitrum-o", chainID: 42161},
+		{name: "arbitrum", candidates: []NameMatch{
+			{Name: "arbitrum-nova", ChainID: 42170},
+			{Name: "arbitrum-one", ChainID: 42161},
+			{Name: "arbitrum-sepolia", ChainID: 421614},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chainID, err := findChainIdByPartialMatch(names, test.name)
+			if test.candidates == nil {
+				if err != nil || chainID != test.chainID {
+					t.Fatalf("got %d, %v, want %d", chainID, err, test.chainID)
+				}
+				return
+			}
+
+			var ambiguous *AmbiguousNameError
+			if !errors.As(err, &ambiguous) {
+				t.Fatalf("got %d, %v, want an AmbiguousNameError", chainID, err)
+			}
+			if len(ambiguous.Candidates) != len(test.candidates) {
+				t.Fatalf("candidates = %v, want %v", ambiguous.Candidates, test.candidates)
+			}
+			for i, candidate := range ambiguous.Candidates {
+				if candidate != test.candidates[i] {
+					t.Errorf("candidate %d = %v, want %v", i, candidate, test.candidates[i])
+				}
+			}
+		})
+	}
+
+	if _, err := findChainIdByPartialMatch(names, "solana"); !errors.Is(err, ErrChainNotFound) {
+		t.Errorf("unknown name: got %v, want ErrChainNotFound", err)
+	}
+}
@@ -0,0 +1,289 @@
+package chain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// HISTORY_TTL is how long probe results are kept
+const HISTORY_TTL = 7 * 24 * time.Hour
+
+// HISTORY_PRUNE_SLACK is how long records may outlive HISTORY_TTL before the history
+// is rewritten without them, so that it is rewritten about once a day rather than by
+// every run once it is a week old. Readers skip expired records meanwhile.
+const HISTORY_PRUNE_SLACK = 24 * time.Hour
+
+// HISTORY_LOCK_STALE is how old the lock of the history gets before it is taken for one
+// left behind by a crashed process
+const HISTORY_LOCK_STALE = 10 * time.Second
+
+// ProbeRecord is the outcome of testing an endpoint once
+type ProbeRecord struct {
+	ChainID   uint64    `json:"chainId"`
+	URL       string    `json:"url"`
+	Time      time.Time `json:"time"`
+	LatencyMs int64     `json:"latencyMs,omitempty"`
+	OK        bool      `json:"ok"`
+}
+
+// EndpointHistory sums up the recorded probes of an endpoint
+type EndpointHistory struct {
+	URL string `json:"url"`
+	// DayUptime and WeekUptime are the shares of passed probes, -1 without probes
+	DayUptime    float64   `json:"dayUptime"`
+	WeekUptime   float64   `json:"weekUptime"`
+	Probes       int       `json:"probes"`
+	AvgLatencyMs int64     `json:"avgLatencyMs"`
+	LastSeen     time.Time `json:"lastSeen"`
+}
+
+func historyFile() string {
+	return filepath.Join(resolvedCacheDir(), "history.jsonl")
+}
+
+// RecordProbes appends probe results to the history, one JSON object per line.
+// Records older than HISTORY_TTL are dropped in batches, see pruneHistory. Nothing
+// is recorded when the cache is read-only.
+func RecordProbes(records []ProbeRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	cacheMux.Lock()
+	defer cacheMux.Unlock()
+
+	if isReadOnly {
+		return nil
+	}
+	if err := ensureCacheDir(); err != nil {
+		return err
+	}
+	// Other processes may record at the same time, a prune must not drop their records
+	unlock, err := lockHistory()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := pruneHistory(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to serialize probe history: %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(historyFile(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open probe history: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write probe history: %v", err)
+	}
+	return nil
+}
+
+// pruneHistory rewrites the history without expired records, once the oldest one is
+// HISTORY_PRUNE_SLACK past expiry. The rewrite replaces the file at once, so that an
+// interrupted one does not lose the history, under the lock of the history, so that
+// records other processes append meanwhile are not lost either.
+func pruneHistory() error {
+	oldest, err := oldestHistoryTime()
+	if err != nil || oldest.IsZero() || time.Since(oldest) < HISTORY_TTL+HISTORY_PRUNE_SLACK {
+		return err
+	}
+
+	records, err := readHistory()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if time.Since(record.Time) < HISTORY_TTL {
+			encoder.Encode(record)
+		}
+	}
+
+	file, err := os.CreateTemp(resolvedCacheDir(), "history-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("failed to write probe history: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write probe history: %v", err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write probe history: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write probe history: %v", err)
+	}
+	if err := os.Rename(file.Name(), historyFile()); err != nil {
+		return fmt.Errorf("failed to write probe history: %v", err)
+	}
+	return nil
+}
+
+// lockHistory keeps other processes from writing the history until unlock is called,
+// waiting for the one holding it if needed. The lock is a file created exclusively,
+// which works the same on every platform.
+func lockHistory() (unlock func(), err error) {
+	lockFile := historyFile() + ".lock"
+	deadline := time.Now().Add(HISTORY_LOCK_STALE)
+	for {
+		file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockFile) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock probe history: %v", err)
+		}
+		if stat, err := os.Stat(lockFile); err == nil && time.Since(stat.ModTime()) > HISTORY_LOCK_STALE {
+			os.Remove(lockFile)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock probe history: %s is held by another process", lockFile)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// oldestHistoryTime returns the time of the first record of the history, the oldest
+// one as records are appended, or the zero time without any
+func oldestHistoryTime() (time.Time, error) {
+	file, err := os.Open(historyFile())
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read probe history: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record ProbeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			return record.Time, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read probe history: %v", err)
+	}
+	return time.Time{}, nil
+}
+
+// LoadProbeHistory returns the recorded probes of the chain, oldest first
+func LoadProbeHistory(chainId uint64) ([]ProbeRecord, error) {
+	cacheMux.RLock()
+	defer cacheMux.RUnlock()
+
+	records, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	chainRecords := make([]ProbeRecord, 0, len(records))
+	for _, record := range records {
+		if record.ChainID == chainId && time.Since(record.Time) < HISTORY_TTL {
+			chainRecords = append(chainRecords, record)
+		}
+	}
+	return chainRecords, nil
+}
+
+func readHistory() ([]ProbeRecord, error) {
+	file, err := os.Open(historyFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read probe history: %v", err)
+	}
+	defer file.Close()
+
+	var records []ProbeRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record ProbeRecord
+		// A line cut short by an interrupted write is not worth failing over
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read probe history: %v", err)
+	}
+	return records, nil
+}
+
+// SummarizeHistory sums up the probes per endpoint, from the highest weekly uptime
+func SummarizeHistory(records []ProbeRecord) []EndpointHistory {
+	type counts struct {
+		dayProbes, dayPassed, probes, passed int
+		latencyMs                            int64
+		lastSeen                             time.Time
+	}
+
+	byURL := make(map[string]*counts)
+	for _, record := range records {
+		c, exists := byURL[record.URL]
+		if !exists {
+			c = &counts{}
+			byURL[record.URL] = c
+		}
+
+		c.probes++
+		if record.OK {
+			c.passed++
+			c.latencyMs += record.LatencyMs
+		}
+		if time.Since(record.Time) < 24*time.Hour {
+			c.dayProbes++
+			if record.OK {
+				c.dayPassed++
+			}
+		}
+		if record.Time.After(c.lastSeen) {
+			c.lastSeen = record.Time
+		}
+	}
+
+	summary := make([]EndpointHistory, 0, len(byURL))
+	for url, c := range byURL {
+		endpoint := EndpointHistory{URL: url, DayUptime: -1, WeekUptime: float64(c.passed) / float64(c.probes), Probes: c.probes, LastSeen: c.lastSeen}
+		if c.dayProbes > 0 {
+			endpoint.DayUptime = float64(c.dayPassed) / float64(c.dayProbes)
+		}
+		if c.passed > 0 {
+			endpoint.AvgLatencyMs = c.latencyMs / int64(c.passed)
+		}
+		summary = append(summary, endpoint)
+	}
+
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].WeekUptime != summary[j].WeekUptime {
+			return summary[i].WeekUptime > summary[j].WeekUptime
+		}
+		return summary[i].URL < summary[j].URL
+	})
+	return summary
+}
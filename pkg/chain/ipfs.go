@@ -0,0 +1,40 @@
+package chain
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DEFAULT_IPFS_GATEWAY = "https://ipfs.io"
+
+var (
+	ipfsCID     string
+	ipfsGateway = DEFAULT_IPFS_GATEWAY
+)
+
+// SetIPFSSource configures an IPFS copy of the chains dataset, fetched alongside
+// the primary source so that lookups keep working when chainlist.org is unreachable.
+// The CID may be given as `ipfs://<cid>` and may include a path (e.g.
+// `<cid>/rpcs.json`). An empty CID disables it.
+func SetIPFSSource(cid, gateway string) error {
+	cid = strings.TrimPrefix(strings.TrimSpace(cid), "ipfs://")
+	if strings.ContainsAny(cid, " \t\n") {
+		return fmt.Errorf("invalid IPFS CID '%s'", cid)
+	}
+
+	gateway = strings.TrimRight(strings.TrimSpace(gateway), "/")
+	if gateway == "" {
+		gateway = DEFAULT_IPFS_GATEWAY
+	}
+	if !strings.HasPrefix(gateway, "https://") && !strings.HasPrefix(gateway, "http://") {
+		return fmt.Errorf("invalid IPFS gateway '%s': must be an http(s) URL", gateway)
+	}
+
+	ipfsCID = strings.Trim(cid, "/")
+	ipfsGateway = gateway
+	return nil
+}
+
+func ipfsURL() string {
+	return ipfsGateway + "/ipfs/" + ipfsCID
+}
@@ -0,0 +1,95 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ForEachChain ensures the cache exists and calls fn for every cached chain,
+// streaming the cache file rather than loading it whole. Chains of the local
+// registry are applied and the ones unknown to the cache are visited last.
+// Iteration stops at the first error returned by fn, which is passed through.
+func ForEachChain(fn func(*ChainData) error) error {
+	if err := ensureCacheExists(); err != nil {
+		return err
+	}
+
+	cacheMux.RLock()
+	defer cacheMux.RUnlock()
+
+	file, err := os.Open(cacheFile())
+	if err != nil {
+		return openCacheError(err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+
+	// Read opening brace
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("failed to read cache file: %v", err)
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("failed to read cache file: %v", err)
+		}
+
+		if str, ok := token.(string); ok && str == "byId" {
+			return iterateByID(decoder, fn)
+		}
+
+		// Skip this field
+		if err := skipValue(decoder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func iterateByID(decoder *json.Decoder, fn func(*ChainData) error) error {
+	visited := make(map[uint64]bool, len(localChains))
+
+	// Read opening brace of byId object
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("failed to read byId object: %v", err)
+	}
+
+	for decoder.More() {
+		// Chain ID key, the value carries the ID as well
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("failed to read byId entry: %v", err)
+		}
+
+		var chainData ChainData
+		if err := decoder.Decode(&chainData); err != nil {
+			return fmt.Errorf("failed to decode chain data: %v", err)
+		}
+
+		visited[chainData.ChainID] = true
+		if err := fn(overlayLocalChain(&chainData)); err != nil {
+			return err
+		}
+	}
+
+	localIDs := make([]uint64, 0, len(localChains))
+	for chainId := range localChains {
+		if !visited[chainId] {
+			localIDs = append(localIDs, chainId)
+		}
+	}
+	sort.Slice(localIDs, func(i, j int) bool { return localIDs[i] < localIDs[j] })
+
+	for _, chainId := range localIDs {
+		chainData, _ := localChain(chainId)
+		if err := fn(chainData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
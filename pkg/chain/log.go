@@ -0,0 +1,17 @@
+package chain
+
+import (
+	"io"
+	"log/slog"
+)
+
+// logger receives what the package does, e.g. rebuilding the cache, discarded by default
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// SetLogger sets the logger of the package. nil discards the logs.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	logger = l
+}
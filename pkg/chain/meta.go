@@ -0,0 +1,199 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MIN_CACHE_TTL keeps sources that forbid caching from forcing a download on every run
+const MIN_CACHE_TTL = time.Hour
+
+// CACHE_SCHEMA_VERSION is the version of the cache file layout written by this build
+const CACHE_SCHEMA_VERSION = 2
+
+// CacheMeta describes where the cached dataset came from and how long it stays fresh
+type CacheMeta struct {
+	// Sources are the names of the sources the cache was built from
+	Sources   []string  `json:"sources,omitempty"`
+	SourceURL string    `json:"sourceUrl"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	// SourceTime is when the source produced the dataset (Last-Modified, or Date minus Age)
+	SourceTime time.Time `json:"sourceTime"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	ChainCount int       `json:"chainCount,omitempty"`
+	// SourceStats breaks the dataset down by source
+	SourceStats []SourceStats `json:"sourceStats,omitempty"`
+	// Validators are the ETag and Last-Modified of each downloaded URL, sent back on refresh
+	Validators map[string]Validator `json:"validators,omitempty"`
+	// RefreshError is set when the last refresh failed and the expired cache was used instead
+	RefreshError    string     `json:"refreshError,omitempty"`
+	RefreshFailedAt *time.Time `json:"refreshFailedAt,omitempty"`
+	// SchemaVersion is the CACHE_SCHEMA_VERSION of the build that wrote the cache, 0 before it was recorded
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// SourceStats is what a source contributed to the cache
+type SourceStats struct {
+	Name      string `json:"name"`
+	Chains    int    `json:"chains"`
+	Endpoints int    `json:"endpoints"`
+	// NewChains and NewEndpoints were not provided by a source earlier in the merge order
+	NewChains    int `json:"newChains"`
+	NewEndpoints int `json:"newEndpoints"`
+}
+
+// CacheStatus is a snapshot of the cache state
+type CacheStatus struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+	// Size is the size of the cache file in bytes
+	Size  int64 `json:"size,omitempty"`
+	Fresh bool  `json:"fresh"`
+	// Degraded means the cache expired and could not be refreshed, so its data may be outdated
+	Degraded bool       `json:"degraded"`
+	Meta     *CacheMeta `json:"meta,omitempty"`
+}
+
+func metaFile() string {
+	return filepath.Join(resolvedCacheDir(), "meta.json")
+}
+
+// GetCacheStatus reports whether the cache exists, where its data came from and when it expires
+func GetCacheStatus() (*CacheStatus, error) {
+	cacheMux.RLock()
+	defer cacheMux.RUnlock()
+
+	status := &CacheStatus{Path: cacheFile()}
+	stat, err := os.Stat(cacheFile())
+	if err != nil {
+		return status, nil
+	}
+	status.Exists = true
+	status.Size = stat.Size()
+
+	meta, err := loadCacheMeta()
+	if err != nil {
+		return nil, err
+	}
+	status.Meta = meta
+	status.Fresh = time.Now().Before(meta.ExpiresAt)
+	status.Degraded = !status.Fresh && meta.RefreshError != ""
+
+	return status, nil
+}
+
+// newCacheMeta derives the dataset freshness from the HTTP caching headers of the source response.
+// The expiry honors Cache-Control/Expires and Age, clamped to [MIN_CACHE_TTL, CACHE_TTL].
+func newCacheMeta(sourceURL string, header http.Header, fetchedAt time.Time) *CacheMeta {
+	meta := &CacheMeta{
+		SourceURL:  sourceURL,
+		FetchedAt:  fetchedAt,
+		SourceTime: fetchedAt,
+	}
+
+	age := time.Duration(0)
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(header.Get("Age")), 10, 64); err == nil && seconds > 0 {
+		age = time.Duration(seconds) * time.Second
+	}
+
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		meta.SourceTime = lastModified
+	} else if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		meta.SourceTime = date.Add(-age)
+	} else {
+		meta.SourceTime = fetchedAt.Add(-age)
+	}
+
+	ttl := CACHE_TTL
+	if maxAge, ok := cacheControlMaxAge(header.Get("Cache-Control")); ok {
+		ttl = maxAge - age
+	} else if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			ttl = expires.Sub(date)
+		} else {
+			ttl = expires.Sub(fetchedAt)
+		}
+	}
+	meta.ExpiresAt = fetchedAt.Add(max(MIN_CACHE_TTL, min(ttl, CACHE_TTL)))
+
+	if validator, ok := responseValidator(header); ok {
+		meta.Validators = map[string]Validator{sourceURL: validator}
+	}
+	return meta
+}
+
+// cacheControlMaxAge returns the freshness lifetime allowed by a Cache-Control header
+func cacheControlMaxAge(cacheControl string) (time.Duration, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0, true
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.ParseInt(strings.Trim(directive[len("max-age="):], `"`), 10, 64)
+			if err != nil {
+				continue
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+func saveCacheMeta(meta *CacheMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to serialize cache metadata: %v", err)
+	}
+
+	if err := ensureCacheDir(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(metaFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache metadata: %v", err)
+	}
+
+	return nil
+}
+
+// recordRefreshFailure marks the cache as used past its expiry because refreshing it failed
+func recordRefreshFailure(refreshErr error) error {
+	meta, err := loadCacheMeta()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	meta.RefreshError = refreshErr.Error()
+	meta.RefreshFailedAt = &now
+	return saveCacheMeta(meta)
+}
+
+// loadCacheMeta reads the cache metadata. Caches built before metadata was
+// recorded fall back to the cache file modification time.
+func loadCacheMeta() (*CacheMeta, error) {
+	data, err := os.ReadFile(metaFile())
+	if err == nil {
+		var meta CacheMeta
+		if err := json.Unmarshal(data, &meta); err == nil {
+			return &meta, nil
+		}
+	}
+
+	stat, err := os.Stat(cacheFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat cache file: %v", err)
+	}
+
+	return &CacheMeta{
+		FetchedAt:  stat.ModTime(),
+		SourceTime: stat.ModTime(),
+		ExpiresAt:  stat.ModTime().Add(CACHE_TTL),
+	}, nil
+}
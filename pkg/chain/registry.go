@@ -0,0 +1,117 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// localChains are user-defined chains merged over the cached dataset during lookup
+var localChains map[uint64]*ChainData
+
+// LoadLocalRegistry reads user-defined chains from a JSON array in the chainlist
+// format. Defined fields override the cached chain of the same ID and RPC endpoints
+// are tried before the cached ones. A missing file leaves the registry empty.
+func LoadLocalRegistry(path string) error {
+	localChains = nil
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read chain registry: %v", err)
+	}
+
+	var chains []ChainData
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return fmt.Errorf("failed to parse chain registry %s: %v", path, err)
+	}
+
+	localChains = make(map[uint64]*ChainData, len(chains))
+	for i := range chains {
+		if chains[i].ChainID == 0 {
+			return fmt.Errorf("invalid chain registry %s: chain '%s' has no chainId", path, chains[i].Name)
+		}
+		localChains[chains[i].ChainID] = &chains[i]
+	}
+
+	logger.Info("loaded local registry", "chains", len(localChains), "path", path)
+	return nil
+}
+
+// overlayLocalChain applies the local definition of the chain, if any
+func overlayLocalChain(chainData *ChainData) *ChainData {
+	local, exists := localChains[chainData.ChainID]
+	if !exists {
+		return chainData
+	}
+
+	merged := *chainData
+	if local.Name != "" {
+		merged.Name = local.Name
+	}
+	if local.Chain != "" {
+		merged.Chain = local.Chain
+	}
+	if local.ShortName != "" {
+		merged.ShortName = local.ShortName
+	}
+	if local.ChainSlug != "" {
+		merged.ChainSlug = local.ChainSlug
+	}
+	if local.NativeCurrency != (NativeCurrency{}) {
+		merged.NativeCurrency = local.NativeCurrency
+	}
+	if len(local.Explorers) > 0 {
+		merged.Explorers = local.Explorers
+	}
+	if local.ENS != nil {
+		merged.ENS = local.ENS
+	}
+	if len(local.Features) > 0 {
+		merged.Features = local.Features
+	}
+	if local.Slip44 != nil {
+		merged.Slip44 = local.Slip44
+	}
+	if local.Testnet != nil {
+		merged.Testnet = local.Testnet
+	}
+	if len(local.Extra) > 0 {
+		merged.Extra = make(map[string]json.RawMessage, len(chainData.Extra)+len(local.Extra))
+		for name, value := range chainData.Extra {
+			merged.Extra[name] = value
+		}
+		for name, value := range local.Extra {
+			merged.Extra[name] = value
+		}
+	}
+	merged.RPCs = mergeRPCs(append([]RPC(nil), local.RPCs...), chainData.RPCs)
+	return &merged
+}
+
+// localChain returns a copy of a chain only known to the local registry
+func localChain(chainId uint64) (*ChainData, bool) {
+	local, exists := localChains[chainId]
+	if !exists {
+		return nil, false
+	}
+	chainData := *local
+	return &chainData, true
+}
+
+// addLocalNames maps the names of local chains, overriding cached names
+func addLocalNames(nameMapping NameToIdMap) NameToIdMap {
+	if nameMapping == nil {
+		nameMapping = make(NameToIdMap)
+	}
+	for chainId, local := range localChains {
+		for _, name := range []string{local.Name, local.ShortName, local.ChainSlug} {
+			if name != "" {
+				nameMapping[normalizeChainName(name)] = chainId
+			}
+		}
+	}
+	return nameMapping
+}
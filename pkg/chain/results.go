@@ -0,0 +1,114 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const RESULTS_TTL = 5 * time.Minute
+
+// workingRPCs maps chain ID to the time each endpoint last passed testing
+type workingRPCs = map[uint64]map[string]time.Time
+
+func resultsFile() string {
+	return filepath.Join(resolvedCacheDir(), "working.json")
+}
+
+// SaveWorkingRPCs records the outcome of a test run: endpoints in working are
+// stamped with the current time, the rest of tested are forgotten. Nothing is
+// recorded when the cache is read-only.
+func SaveWorkingRPCs(chainId uint64, tested, working []string) error {
+	cacheMux.Lock()
+	defer cacheMux.Unlock()
+
+	if isReadOnly {
+		return nil
+	}
+
+	results, err := loadWorkingRPCs()
+	if err != nil {
+		// A corrupted results file is not worth failing over, start over
+		results = make(workingRPCs)
+	}
+
+	chainResults := results[chainId]
+	if chainResults == nil {
+		chainResults = make(map[string]time.Time)
+		results[chainId] = chainResults
+	}
+
+	for _, url := range tested {
+		delete(chainResults, url)
+	}
+	now := time.Now()
+	for _, url := range working {
+		chainResults[url] = now
+	}
+
+	// Drop expired entries so the file doesn't grow forever
+	for id, chainResults := range results {
+		for url, testedAt := range chainResults {
+			if time.Since(testedAt) >= RESULTS_TTL {
+				delete(chainResults, url)
+			}
+		}
+		if len(chainResults) == 0 {
+			delete(results, id)
+		}
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to serialize working rpcs: %v", err)
+	}
+
+	if err := ensureCacheDir(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(resultsFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write working rpcs: %v", err)
+	}
+
+	return nil
+}
+
+// LoadWorkingRPCs returns the endpoints of the chain that passed testing within RESULTS_TTL
+func LoadWorkingRPCs(chainId uint64) ([]string, error) {
+	cacheMux.RLock()
+	defer cacheMux.RUnlock()
+
+	results, err := loadWorkingRPCs()
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(results[chainId]))
+	for url, testedAt := range results[chainId] {
+		if time.Since(testedAt) < RESULTS_TTL {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls, nil
+}
+
+func loadWorkingRPCs() (workingRPCs, error) {
+	results := make(workingRPCs)
+
+	data, err := os.ReadFile(resultsFile())
+	if os.IsNotExist(err) {
+		return results, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read working rpcs: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse working rpcs: %v", err)
+	}
+
+	return results, nil
+}
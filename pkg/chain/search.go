@@ -0,0 +1,117 @@
+package chain
+
+import (
+	"sort"
+	"strings"
+)
+
+// MIN_SEARCH_SCORE is the similarity below which candidates are not reported
+const MIN_SEARCH_SCORE = 0.4
+
+// SearchResult is a chain matching a fuzzy search query
+type SearchResult struct {
+	ChainID uint64 `json:"chainId"`
+	Name    string `json:"name"`
+	// Match is the normalized name, short name or slug that matched best
+	Match string `json:"match"`
+	// Score is the similarity between the query and Match, from 0 to 1
+	Score   float64 `json:"score"`
+	Testnet bool    `json:"testnet"`
+}
+
+// SearchChains ranks cached chains by similarity of their names, short names
+// and slugs to the query and returns at most limit results (all when limit <= 0)
+func SearchChains(query string, limit int) ([]SearchResult, error) {
+	return SearchChainsWhere(query, limit, nil)
+}
+
+// SearchChainsWhere is SearchChains among the chains keep returns true for, e.g.
+// IsTestnet. A nil keep searches all chains.
+func SearchChainsWhere(query string, limit int, keep func(*ChainData) bool) ([]SearchResult, error) {
+	query = normalizeChainName(query)
+	results := make([]SearchResult, 0)
+
+	err := ForEachChain(func(chainData *ChainData) error {
+		if keep != nil && !keep(chainData) {
+			return nil
+		}
+		best := SearchResult{ChainID: chainData.ChainID, Name: chainData.Name, Testnet: IsTestnet(chainData)}
+		for _, name := range []string{chainData.Name, chainData.ShortName, chainData.ChainSlug} {
+			name = normalizeChainName(name)
+			if name == "" {
+				continue
+			}
+			if score := similarity(query, name); score > best.Score {
+				best.Score = score
+				best.Match = name
+			}
+		}
+
+		if best.Score >= MIN_SEARCH_SCORE {
+			results = append(results, best)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ChainID < results[j].ChainID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// similarity scores how well name matches query: 1 for equal strings, high for
+// prefixes and substrings, otherwise based on the Levenshtein distance
+func similarity(query, name string) float64 {
+	if query == name {
+		return 1
+	}
+
+	longest := max(len(query), len(name))
+	score := 1 - float64(levenshtein(query, name))/float64(longest)
+
+	if strings.Contains(name, query) {
+		// Containment is a strong signal, the shorter the remainder the better
+		coverage := float64(len(query)) / float64(len(name))
+		bonus := 0.5
+		if strings.HasPrefix(name, query) {
+			bonus = 0.55
+		}
+		score = max(score, bonus+0.4*coverage)
+	}
+
+	return score
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"sort"
+	"time"
+)
+
+// EndpointSLO is how an endpoint fared against an availability target
+type EndpointSLO struct {
+	URL string `json:"url"`
+	// Availability is the share of passed probes over the whole window
+	Availability float64 `json:"availability"`
+	Probes       int     `json:"probes"`
+	Met          bool    `json:"met"`
+	// Violations are the periods of the window whose availability missed the target, oldest first
+	Violations []SLOViolation `json:"violations"`
+}
+
+// SLOViolation is a period in which an endpoint missed the availability target
+type SLOViolation struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Availability float64   `json:"availability"`
+	Probes       int       `json:"probes"`
+}
+
+// EvaluateSLO checks the availability of every endpoint against target, a share from 0 to 1,
+// over the window ending at now, and over each period of the window. Periods are aligned to
+// now and those without probes are left out. Endpoints are sorted from the highest availability.
+func EvaluateSLO(records []ProbeRecord, target float64, window, period time.Duration, now time.Time) []EndpointSLO {
+	type counts struct{ probes, passed int }
+	type endpointCounts struct {
+		counts
+		periods map[int]*counts
+	}
+
+	start := now.Add(-window)
+	byURL := make(map[string]*endpointCounts)
+	for _, record := range records {
+		if record.Time.Before(start) || record.Time.After(now) {
+			continue
+		}
+		c, exists := byURL[record.URL]
+		if !exists {
+			c = &endpointCounts{periods: make(map[int]*counts)}
+			byURL[record.URL] = c
+		}
+
+		// Periods are numbered back from now, the latest one being 0
+		index := int(now.Sub(record.Time) / period)
+		p, exists := c.periods[index]
+		if !exists {
+			p = &counts{}
+			c.periods[index] = p
+		}
+
+		c.probes++
+		p.probes++
+		if record.OK {
+			c.passed++
+			p.passed++
+		}
+	}
+
+	slos := make([]EndpointSLO, 0, len(byURL))
+	for url, c := range byURL {
+		slo := EndpointSLO{URL: url, Availability: float64(c.passed) / float64(c.probes), Probes: c.probes, Violations: []SLOViolation{}}
+		slo.Met = slo.Availability >= target
+
+		for index, p := range c.periods {
+			availability := float64(p.passed) / float64(p.probes)
+			if availability >= target {
+				continue
+			}
+			end := now.Add(-time.Duration(index) * period)
+			slo.Violations = append(slo.Violations, SLOViolation{Start: maxTime(end.Add(-period), start), End: end, Availability: availability, Probes: p.probes})
+		}
+		sort.Slice(slo.Violations, func(i, j int) bool { return slo.Violations[i].Start.Before(slo.Violations[j].Start) })
+		slos = append(slos, slo)
+	}
+
+	sort.Slice(slos, func(i, j int) bool {
+		if slos[i].Availability != slos[j].Availability {
+			return slos[i].Availability > slos[j].Availability
+		}
+		return slos[i].URL < slos[j].URL
+	})
+	return slos
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package chain
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+//go:generate go run ../../internal/snapshotgen -o snapshot.json.gz
+
+// SNAPSHOT_SOURCE names the embedded snapshot in the cache metadata
+const SNAPSHOT_SOURCE = "snapshot"
+
+//go:embed snapshot.json.gz
+var snapshotData []byte
+
+var isOffline bool
+
+// SetOffline makes lookups use the existing cache, or the embedded snapshot
+// when there is none, without any network access
+func SetOffline(offline bool) {
+	isOffline = offline
+}
+
+// SnapshotTime returns when the dataset embedded at build time was produced
+func SnapshotTime() (time.Time, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(snapshotData))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read embedded snapshot: %v", err)
+	}
+	defer reader.Close()
+	return reader.ModTime, nil
+}
+
+// loadSnapshot decodes the chains dataset embedded at build time
+func loadSnapshot() ([]ChainData, *CacheMeta, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(snapshotData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read embedded snapshot: %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read embedded snapshot: %v", err)
+	}
+
+	var chains []ChainData
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse embedded snapshot: %v", err)
+	}
+
+	// The snapshot is never fresh, so the cache is refreshed as soon as the network is back
+	now := time.Now()
+	meta := &CacheMeta{
+		Sources:     []string{SNAPSHOT_SOURCE},
+		SourceURL:   "embedded snapshot",
+		FetchedAt:   now,
+		SourceTime:  reader.ModTime,
+		ExpiresAt:   now,
+		ChainCount:  len(chains),
+		SourceStats: []SourceStats{newSourceStats(SNAPSHOT_SOURCE, chains)},
+	}
+	return chains, meta, nil
+}
+
+// buildCacheFromSnapshot replaces the cache with the embedded snapshot
+func buildCacheFromSnapshot() error {
+	logger.Info("building chain data cache from the embedded snapshot")
+
+	chains, meta, err := loadSnapshot()
+	if err != nil {
+		return err
+	}
+	return writeCache(chains, meta)
+}
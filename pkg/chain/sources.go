@@ -0,0 +1,359 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"chain-rpc/pkg/urls"
+)
+
+const (
+	CHAINID_NETWORK_URL = "https://chainid.network/chains.json"
+	// ETHEREUM_LISTS_ARCHIVE_URL is the tarball of the ethereum-lists/chains repository
+	ETHEREUM_LISTS_ARCHIVE_URL = "https://codeload.github.com/ethereum-lists/chains/tar.gz/refs/heads/master"
+
+	DEFAULT_SOURCE = "chainlist"
+)
+
+// Source provides the chains dataset
+type Source interface {
+	// Name identifies the source in flags, config and cache metadata
+	Name() string
+	// Fetch downloads the full dataset
+	Fetch(ctx context.Context) ([]ChainData, *CacheMeta, error)
+}
+
+var (
+	dataURL = CHAINS_DATA_URL
+	mirrors []string
+
+	sourcesMux      sync.RWMutex
+	registry        = make(map[string]Source)
+	selectedSources = []string{DEFAULT_SOURCE}
+)
+
+func init() {
+	RegisterSource(chainlistSource{})
+	RegisterSource(&urlSource{name: "chainid", url: CHAINID_NETWORK_URL})
+	RegisterSource(ethereumListsSource{})
+}
+
+// RegisterSource makes a source selectable by its name, replacing any source with the same name
+func RegisterSource(source Source) {
+	sourcesMux.Lock()
+	defer sourcesMux.Unlock()
+	registry[source.Name()] = source
+}
+
+// SourceNames lists the registered sources
+func SourceNames() []string {
+	sourcesMux.RLock()
+	defer sourcesMux.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetSources selects the sources the cache is built from. With several sources
+// the datasets are merged: the first source wins for chain metadata and RPC
+// lists are combined without duplicates. An empty list selects chainlist.org.
+func SetSources(names []string) error {
+	if len(names) == 0 {
+		names = []string{DEFAULT_SOURCE}
+	}
+
+	sourcesMux.Lock()
+	defer sourcesMux.Unlock()
+
+	seen := make(map[string]bool, len(names))
+	selected := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if _, ok := registry[name]; !ok {
+			known := make([]string, 0, len(registry))
+			for knownName := range registry {
+				known = append(known, knownName)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown source '%s', expected one of: %s", name, strings.Join(known, ", "))
+		}
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, name)
+		}
+	}
+
+	selectedSources = selected
+	return nil
+}
+
+// SetDataURL replaces chainlist.org with another location of the same dataset, e.g. an internal mirror.
+// An empty URL restores the default.
+func SetDataURL(url string) {
+	if url == "" {
+		url = CHAINS_DATA_URL
+	}
+	dataURL = url
+}
+
+// SetMirrors configures additional locations of the chainlist dataset. When more
+// than one location is configured, all of them are fetched concurrently and the
+// first valid response wins.
+func SetMirrors(urls []string) {
+	mirrors = urls
+}
+
+type fetchResult struct {
+	chains []ChainData
+	meta   *CacheMeta
+	err    error
+}
+
+// fetchChains downloads the datasets of the selected sources and merges them.
+// With validators in ctx, errNotModified is returned when no dataset changed.
+func fetchChains(ctx context.Context) ([]ChainData, *CacheMeta, error) {
+	sourcesMux.RLock()
+	sources := make([]Source, 0, len(selectedSources))
+	for _, name := range selectedSources {
+		sources = append(sources, registry[name])
+	}
+	sourcesMux.RUnlock()
+
+	results := fetchSources(ctx, sources)
+
+	notModified := 0
+	for _, result := range results {
+		if result.err == errNotModified {
+			notModified++
+		}
+	}
+	if notModified == len(results) {
+		return nil, mergeMeta(results), errNotModified
+	}
+	if notModified > 0 {
+		// The unchanged datasets are needed to merge with the changed ones
+		for i, source := range sources {
+			if results[i].err == errNotModified {
+				results[i] = fetchSources(context.Background(), []Source{source})[0]
+			}
+		}
+	}
+
+	chains, meta, err := mergeResults(results)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if minChains := minChainCount(); len(chains) < minChains {
+		return nil, nil, fmt.Errorf("chains data has only %d chains, expected at least %d", len(chains), minChains)
+	}
+	return chains, meta, nil
+}
+
+// fetchSources fetches the sources concurrently, results are in the order of sources
+func fetchSources(ctx context.Context, sources []Source) []fetchResult {
+	results := make([]fetchResult, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			chains, meta, err := source.Fetch(ctx)
+			if err == nil || err == errNotModified {
+				meta.Sources = []string{source.Name()}
+			}
+			results[i] = fetchResult{chains: chains, meta: meta, err: err}
+		}(i, source)
+	}
+	wg.Wait()
+	return results
+}
+
+// mergeResults combines source datasets in order of precedence. A failing
+// source fails the build so that a partial dataset never replaces the cache.
+func mergeResults(results []fetchResult) ([]ChainData, *CacheMeta, error) {
+	for _, result := range results {
+		if result.err != nil {
+			return nil, nil, result.err
+		}
+	}
+	if len(results) == 1 {
+		meta := results[0].meta
+		meta.SourceStats = []SourceStats{newSourceStats(meta.Sources[0], results[0].chains)}
+		return results[0].chains, meta, nil
+	}
+
+	merged := make([]ChainData, 0, len(results[0].chains))
+	index := make(map[uint64]int)
+	stats := make([]SourceStats, 0, len(results))
+	for _, result := range results {
+		stat := SourceStats{Name: result.meta.Sources[0], Chains: len(result.chains)}
+		for _, chainData := range result.chains {
+			stat.Endpoints += len(chainData.RPCs)
+			i, exists := index[chainData.ChainID]
+			if !exists {
+				index[chainData.ChainID] = len(merged)
+				merged = append(merged, chainData)
+				stat.NewChains++
+				stat.NewEndpoints += len(chainData.RPCs)
+				continue
+			}
+			known := len(merged[i].RPCs)
+			merged[i].RPCs = mergeRPCs(merged[i].RPCs, chainData.RPCs)
+			stat.NewEndpoints += len(merged[i].RPCs) - known
+		}
+		stats = append(stats, stat)
+	}
+
+	meta := mergeMeta(results)
+	meta.ChainCount = len(merged)
+	meta.SourceStats = stats
+	return merged, meta, nil
+}
+
+// newSourceStats counts the chains and endpoints of a source as if it were the only one
+func newSourceStats(name string, chains []ChainData) SourceStats {
+	stat := SourceStats{Name: name, Chains: len(chains), NewChains: len(chains)}
+	for _, chainData := range chains {
+		stat.Endpoints += len(chainData.RPCs)
+	}
+	stat.NewEndpoints = stat.Endpoints
+	return stat
+}
+
+// mergeRPCs appends the endpoints of other that are not in rpcs yet, however they are listed
+func mergeRPCs(rpcs, other []RPC) []RPC {
+	known := make(map[string]bool, len(rpcs))
+	for _, rpc := range rpcs {
+		known[urls.Normalize(rpc.URL)] = true
+	}
+
+	for _, rpc := range other {
+		if url := urls.Normalize(rpc.URL); !known[url] {
+			known[url] = true
+			rpcs = append(rpcs, rpc)
+		}
+	}
+	return rpcs
+}
+
+// mergeMeta keeps the most conservative freshness of all sources
+func mergeMeta(results []fetchResult) *CacheMeta {
+	meta := *results[0].meta
+	sourceURLs := []string{meta.SourceURL}
+	meta.Sources = nil
+	meta.Validators = make(map[string]Validator)
+
+	for _, result := range results {
+		meta.Sources = append(meta.Sources, result.meta.Sources...)
+		if result.meta != results[0].meta {
+			sourceURLs = append(sourceURLs, result.meta.SourceURL)
+		}
+		if result.meta.SourceTime.Before(meta.SourceTime) {
+			meta.SourceTime = result.meta.SourceTime
+		}
+		if result.meta.ExpiresAt.Before(meta.ExpiresAt) {
+			meta.ExpiresAt = result.meta.ExpiresAt
+		}
+		for url, validator := range result.meta.Validators {
+			meta.Validators[url] = validator
+		}
+	}
+
+	meta.SourceURL = strings.Join(sourceURLs, ", ")
+	return &meta
+}
+
+// minChainCount guards against truncated or empty datasets: a fresh download
+// must have at least half as many chains as the cache it replaces
+func minChainCount() int {
+	meta, err := loadCacheMeta()
+	if err != nil || meta.ChainCount == 0 {
+		return 1
+	}
+	return meta.ChainCount / 2
+}
+
+// selectionMatches reports whether the cache was built from the currently selected sources
+func selectionMatches(meta *CacheMeta) bool {
+	sourcesMux.RLock()
+	defer sourcesMux.RUnlock()
+
+	built := meta.Sources
+	if len(built) == 0 {
+		// Caches built before sources were recorded come from chainlist.org
+		built = []string{DEFAULT_SOURCE}
+	}
+	return strings.Join(built, ",") == strings.Join(selectedSources, ",")
+}
+
+// chainlistSource is chainlist.org, or its configured mirrors, which are raced against each other
+type chainlistSource struct{}
+
+func (chainlistSource) Name() string {
+	return DEFAULT_SOURCE
+}
+
+func (chainlistSource) Fetch(ctx context.Context) ([]ChainData, *CacheMeta, error) {
+	locations := append([]string{dataURL}, mirrors...)
+	if ipfsCID != "" {
+		locations = append(locations, ipfsURL())
+	}
+	if len(locations) == 1 {
+		return fetchChainsFrom(ctx, locations[0], true)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	// Abort the slower downloads once a winner is found
+	defer cancel()
+
+	// A truncated copy loses the race rather than winning it and failing the build
+	minChains := minChainCount()
+	resultCh := make(chan fetchResult, len(locations))
+	for _, location := range locations {
+		go func(location string) {
+			chains, meta, err := fetchChainsFrom(ctx, location, true)
+			if err == nil && len(chains) < minChains {
+				err = fmt.Errorf("chains data from %s has only %d chains, expected at least %d", location, len(chains), minChains)
+			}
+			resultCh <- fetchResult{chains: chains, meta: meta, err: err}
+		}(location)
+	}
+
+	errs := make([]string, 0, len(locations))
+	for range locations {
+		result := <-resultCh
+		if result.err == nil {
+			logger.Info("fetched chains", "chains", len(result.chains), "source", result.meta.SourceURL)
+			return result.chains, result.meta, nil
+		}
+		if result.err == errNotModified {
+			return nil, result.meta, result.err
+		}
+		logger.Warn("failed to fetch chain data source", "error", result.err)
+		errs = append(errs, result.err.Error())
+	}
+
+	return nil, nil, fmt.Errorf("all chains data sources failed:\n- %s", strings.Join(errs, "\n- "))
+}
+
+// urlSource is a dataset in the chainlist/ethereum-lists JSON array format at a fixed URL
+type urlSource struct {
+	name string
+	url  string
+}
+
+func (s *urlSource) Name() string {
+	return s.name
+}
+
+func (s *urlSource) Fetch(ctx context.Context) ([]ChainData, *CacheMeta, error) {
+	return fetchChainsFrom(ctx, s.url, false)
+}
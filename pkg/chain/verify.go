@@ -0,0 +1,165 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	expectedSHA256 []byte
+	minisignKey    *minisignPublicKey
+	minisignSigURL string
+)
+
+type minisignPublicKey struct {
+	keyID [8]byte
+	key   ed25519.PublicKey
+}
+
+// SetChecksum pins the hex-encoded SHA-256 digest the downloaded dataset must match.
+// An empty digest disables the check.
+func SetChecksum(sha256Hex string) error {
+	if sha256Hex == "" {
+		expectedSHA256 = nil
+		return nil
+	}
+
+	digest, err := hex.DecodeString(strings.TrimSpace(sha256Hex))
+	if err != nil || len(digest) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 checksum '%s'", sha256Hex)
+	}
+
+	expectedSHA256 = digest
+	return nil
+}
+
+// SetMinisignKey requires the downloaded dataset to be signed with the given minisign public key.
+// The signature is fetched from signatureURL, or from the dataset URL with a `.minisig` suffix when empty.
+// An empty key disables the check.
+func SetMinisignKey(publicKey, signatureURL string) error {
+	if publicKey == "" {
+		minisignKey = nil
+		minisignSigURL = ""
+		return nil
+	}
+
+	key, err := parseMinisignPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	minisignKey = key
+	minisignSigURL = signatureURL
+	return nil
+}
+
+func verifyChainsData(sourceURL string, data []byte) error {
+	if expectedSHA256 != nil {
+		digest := sha256.Sum256(data)
+		if !bytes.Equal(digest[:], expectedSHA256) {
+			return fmt.Errorf("chains data checksum mismatch: expected %x, got %x", expectedSHA256, digest)
+		}
+	}
+
+	if minisignKey != nil {
+		sigURL := minisignSigURL
+		if sigURL == "" {
+			sigURL = sourceURL + ".minisig"
+		}
+
+		signature, err := fetchSignature(sigURL)
+		if err != nil {
+			return err
+		}
+		if err := minisignKey.verify(data, signature); err != nil {
+			return fmt.Errorf("chains data signature verification failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func fetchSignature(sigURL string) (string, error) {
+	resp, err := httpClient.Get(sigURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch chains data signature: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to fetch chains data signature: HTTP %d", resp.StatusCode)
+	}
+
+	signature, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read chains data signature: %v", err)
+	}
+
+	return string(signature), nil
+}
+
+// parseMinisignPublicKey accepts either the bare base64 key or the contents of a `.pub` file
+func parseMinisignPublicKey(publicKey string) (*minisignPublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(publicKey), "\n")
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid minisign public key")
+	}
+
+	key := &minisignPublicKey{key: ed25519.PublicKey(raw[10:])}
+	copy(key.keyID[:], raw[2:10])
+	return key, nil
+}
+
+// verify checks a minisign signature file: both the signature over the data
+// and the global signature over the trusted comment
+func (k *minisignPublicKey) verify(data []byte, signatureFile string) error {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(signatureFile), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("malformed signature file")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("malformed signature")
+	}
+	if !bytes.Equal(sig[2:10], k.keyID[:]) {
+		return fmt.Errorf("signature was made with a different key")
+	}
+
+	message := data
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		// Prehashed signature
+		digest := blake2b.Sum512(data)
+		message = digest[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", sig[:2])
+	}
+
+	if !ed25519.Verify(k.key, message, sig[10:]) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return fmt.Errorf("malformed trusted comment signature")
+	}
+	trustedComment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(k.key, append(sig[10:], trustedComment...), globalSig) {
+		return fmt.Errorf("invalid trusted comment signature")
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CONFIG_FILE_NAME    = "config.yaml"
+	REGISTRY_FILE_NAME  = "chains.json"
+	WORKSPACE_FILE_NAME = ".chain-rpc.yaml"
+)
+
+// Config is the user configuration read from the config file
+type Config struct {
+	Source SourceConfig `yaml:"source"`
+	// Registry is the local chain registry file (default: chains.json next to the config file)
+	Registry  string          `yaml:"registry"`
+	Providers ProvidersConfig `yaml:"providers"`
+	// Headers are added to the requests of the endpoints of their hosts
+	Headers []HeaderRule `yaml:"headers"`
+	// Backups are the standby endpoints of chains by chain ID, checked by dr-check
+	Backups map[uint64][]string `yaml:"backups"`
+	Serve   ServeConfig         `yaml:"serve"`
+	Proxy   ProxyConfig         `yaml:"proxy"`
+}
+
+// ProxyConfig chooses the endpoints the proxy sends requests to. Endpoints are named
+// by one of their tags, their provider, e.g. infura, or a provider domain.
+type ProxyConfig struct {
+	// Prefer are tiers of endpoints, in order: requests go to the endpoints of the
+	// first tier that works, unlisted endpoints come last
+	Prefer []string `yaml:"prefer"`
+	// Weights are the shares of requests of endpoints among the ones of their tier,
+	// 1 for unlisted endpoints
+	Weights map[string]float64 `yaml:"weights"`
+	// Allow, when set, are the only methods served, as globs like eth_*
+	Allow []string `yaml:"allow"`
+	// Deny are methods never served, as globs like admin_*
+	Deny []string `yaml:"deny"`
+}
+
+// ServeConfig configures the serve daemon
+type ServeConfig struct {
+	// Keys are the API keys accepted on the TCP address of the daemon
+	Keys []APIKey `yaml:"keys"`
+}
+
+// APIKey lets a consumer of the serve daemon, e.g. a team, ask it for the endpoints of
+// some chains at a bounded rate. The key may reference environment variables as ${NAME},
+// to keep secrets out of the file.
+type APIKey struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+	// Chains are the chain IDs the key may ask for, all when empty
+	Chains []uint64 `yaml:"chains"`
+	// Rate bounds the requests per second made with the key, no limit when not positive
+	Rate float64 `yaml:"rate"`
+}
+
+// HeaderRule adds headers to the requests sent to the endpoints of a host and its
+// subdomains, e.g. the bearer token of an auth gateway. Values may reference
+// environment variables as ${NAME}, to keep secrets out of the file.
+type HeaderRule struct {
+	Host    string            `yaml:"host"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// ProvidersConfig filters and prioritizes endpoints by provider domain, e.g. ankr.com,
+// which matches the domain itself and its subdomains
+type ProvidersConfig struct {
+	// Exclude drops the endpoints of distrusted providers before testing
+	Exclude []string `yaml:"exclude"`
+	// Prefer picks the working endpoints of these providers first, e.g. paid ones
+	Prefer []string `yaml:"prefer"`
+	// Keys are the API keys of providers by name, substituted for the placeholders of
+	// endpoint URLs, e.g. infura for ${INFURA_API_KEY}
+	Keys map[string]ProviderKey `yaml:",inline"`
+}
+
+// ProviderKey is the API key of a provider
+type ProviderKey struct {
+	Key string `yaml:"key"`
+	// Domains are the hosts the key may be sent to, with their subdomains, for providers
+	// chain-rpc does not know the domains of
+	Domains []string `yaml:"domains"`
+}
+
+// SourceConfig describes where the chains dataset is downloaded from and how it is verified.
+// URL, Mirrors and the verification settings apply to the chainlist source.
+type SourceConfig struct {
+	// Sources selects and orders the datasets the cache is built from (default: chainlist)
+	Sources []string `yaml:"sources"`
+	// URL replaces chainlist.org, e.g. with an internal mirror
+	URL string `yaml:"url"`
+	// Mirrors are additional locations of the dataset, fetched concurrently with URL
+	Mirrors []string `yaml:"mirrors"`
+	// SHA256 is the expected hex digest of the downloaded dataset
+	SHA256 string `yaml:"sha256"`
+	// MinisignKey is the minisign public key the dataset must be signed with
+	MinisignKey string `yaml:"minisign_key"`
+	// SignatureURL is where the minisign signature is fetched from (default: URL + ".minisig")
+	SignatureURL string `yaml:"signature_url"`
+}
+
+// Dir returns the directory holding chain-rpc configuration files
+func Dir() string {
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		userConfigDir = os.TempDir()
+	}
+	return filepath.Join(userConfigDir, "chain-rpc")
+}
+
+// DefaultPath returns the location of the user config file
+func DefaultPath() string {
+	return filepath.Join(Dir(), CONFIG_FILE_NAME)
+}
+
+// DefaultRegistryPath returns the location of the local chain registry
+func DefaultRegistryPath() string {
+	return filepath.Join(Dir(), REGISTRY_FILE_NAME)
+}
+
+// Load reads the config file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// FindWorkspace returns the workspace config file closest to dir, looking in dir and
+// then in its parents like .editorconfig, if any
+func FindWorkspace(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, WORKSPACE_FILE_NAME)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// LoadWorkspace reads the workspace config file at path. The registry path is
+// relative to the directory of the file, so that repositories can ship their own.
+func LoadWorkspace(path string) (*Config, error) {
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Registry != "" && !filepath.IsAbs(cfg.Registry) {
+		cfg.Registry = filepath.Join(filepath.Dir(path), cfg.Registry)
+	}
+	return cfg, nil
+}
+
+// Merge returns the config with the settings of override applied over it
+func (c *Config) Merge(override *Config) *Config {
+	merged := *c
+	if len(override.Source.Sources) > 0 {
+		merged.Source.Sources = override.Source.Sources
+	}
+	if override.Source.URL != "" {
+		merged.Source.URL = override.Source.URL
+	}
+	if len(override.Source.Mirrors) > 0 {
+		merged.Source.Mirrors = override.Source.Mirrors
+	}
+	if override.Source.SHA256 != "" {
+		merged.Source.SHA256 = override.Source.SHA256
+	}
+	if override.Source.MinisignKey != "" {
+		merged.Source.MinisignKey = override.Source.MinisignKey
+	}
+	if override.Source.SignatureURL != "" {
+		merged.Source.SignatureURL = override.Source.SignatureURL
+	}
+	if override.Registry != "" {
+		merged.Registry = override.Registry
+	}
+	if len(override.Providers.Exclude) > 0 {
+		merged.Providers.Exclude = override.Providers.Exclude
+	}
+	if len(override.Providers.Prefer) > 0 {
+		merged.Providers.Prefer = override.Providers.Prefer
+	}
+	if len(override.Providers.Keys) > 0 {
+		keys := make(map[string]ProviderKey, len(c.Providers.Keys)+len(override.Providers.Keys))
+		for name, key := range c.Providers.Keys {
+			keys[name] = key
+		}
+		for name, key := range override.Providers.Keys {
+			keys[name] = key
+		}
+		merged.Providers.Keys = keys
+	}
+	if len(override.Headers) > 0 {
+		// Rules add up, the workspace can add headers for the endpoints of the project
+		merged.Headers = append(append([]HeaderRule{}, c.Headers...), override.Headers...)
+	}
+	if len(override.Backups) > 0 {
+		backups := make(map[uint64][]string, len(c.Backups)+len(override.Backups))
+		for chainID, urls := range c.Backups {
+			backups[chainID] = urls
+		}
+		for chainID, urls := range override.Backups {
+			backups[chainID] = urls
+		}
+		merged.Backups = backups
+	}
+	if len(override.Proxy.Prefer) > 0 {
+		merged.Proxy.Prefer = override.Proxy.Prefer
+	}
+	if len(override.Proxy.Weights) > 0 {
+		weights := make(map[string]float64, len(c.Proxy.Weights)+len(override.Proxy.Weights))
+		for name, weight := range c.Proxy.Weights {
+			weights[name] = weight
+		}
+		for name, weight := range override.Proxy.Weights {
+			weights[name] = weight
+		}
+		merged.Proxy.Weights = weights
+	}
+	if len(override.Proxy.Allow) > 0 {
+		merged.Proxy.Allow = override.Proxy.Allow
+	}
+	if len(override.Proxy.Deny) > 0 {
+		merged.Proxy.Deny = override.Proxy.Deny
+	}
+	if len(override.Serve.Keys) > 0 {
+		merged.Serve.Keys = override.Serve.Keys
+	}
+	return &merged
+}
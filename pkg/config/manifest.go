@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest lists the chains a project needs, with the constraints their endpoints must meet
+type Manifest struct {
+	Chains []ChainRequirement `yaml:"chains"`
+}
+
+// ChainRequirement is a chain of a manifest and the constraints of its endpoints
+type ChainRequirement struct {
+	// Chain is a chain ID or name
+	Chain string `yaml:"chain"`
+	// WSS requires WebSocket endpoints
+	WSS bool `yaml:"wss"`
+	// Archive requires the state of old blocks
+	Archive bool `yaml:"archive"`
+	// Methods are JSON-RPC methods the endpoints must support
+	Methods []string `yaml:"methods"`
+	// MaxLatency drops slower endpoints, 0 for no limit
+	MaxLatency time.Duration `yaml:"maxLatency"`
+	// Count is the number of endpoints to lock (default: 1)
+	Count int `yaml:"count"`
+}
+
+// Lock is the outcome of resolving a manifest, the verified endpoints of every chain
+type Lock struct {
+	ResolvedAt time.Time     `json:"resolvedAt"`
+	Chains     []LockedChain `json:"chains"`
+}
+
+// LockedChain is a chain of a manifest with its verified endpoints, from the fastest,
+// and the constraints they were verified against
+type LockedChain struct {
+	Chain        string           `json:"chain"`
+	ChainID      uint64           `json:"chainId"`
+	Name         string           `json:"name"`
+	WSS          bool             `json:"wss,omitempty"`
+	Archive      bool             `json:"archive,omitempty"`
+	Methods      []string         `json:"methods,omitempty"`
+	MaxLatencyMs int64            `json:"maxLatencyMs,omitempty"`
+	Endpoints    []LockedEndpoint `json:"endpoints"`
+}
+
+// LockedEndpoint is a verified endpoint with its latency when it was resolved
+type LockedEndpoint struct {
+	URL       string `json:"url"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// NewLockedChain returns the locked chain of a requirement, without endpoints yet
+func NewLockedChain(requirement ChainRequirement, chainID uint64, name string) LockedChain {
+	return LockedChain{
+		Chain:        requirement.Chain,
+		ChainID:      chainID,
+		Name:         name,
+		WSS:          requirement.WSS,
+		Archive:      requirement.Archive,
+		Methods:      requirement.Methods,
+		MaxLatencyMs: requirement.MaxLatency.Milliseconds(),
+		Endpoints:    []LockedEndpoint{},
+	}
+}
+
+// Requirement returns the constraints the endpoints of the chain were locked with
+func (c LockedChain) Requirement() ChainRequirement {
+	return ChainRequirement{
+		Chain:      c.Chain,
+		WSS:        c.WSS,
+		Archive:    c.Archive,
+		Methods:    c.Methods,
+		MaxLatency: time.Duration(c.MaxLatencyMs) * time.Millisecond,
+		Count:      len(c.Endpoints),
+	}
+}
+
+// LoadManifest reads and validates the manifest at path
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	manifest := &Manifest{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+	if len(manifest.Chains) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no chains", path)
+	}
+	for i := range manifest.Chains {
+		requirement := &manifest.Chains[i]
+		if requirement.Chain == "" {
+			return nil, fmt.Errorf("invalid manifest %s: chain %d has no chain ID or name", path, i+1)
+		}
+		if requirement.Count < 0 || requirement.MaxLatency < 0 {
+			return nil, fmt.Errorf("invalid manifest %s: count and maxLatency of %s must not be negative", path, requirement.Chain)
+		}
+		if requirement.Count == 0 {
+			requirement.Count = 1
+		}
+	}
+	return manifest, nil
+}
+
+// ReadLock reads and validates a lock file written by resolve
+func ReadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %v", err)
+	}
+
+	lock := &Lock{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %v", path, err)
+	}
+	if len(lock.Chains) == 0 {
+		return nil, fmt.Errorf("lock file %s lists no chains", path)
+	}
+	for _, locked := range lock.Chains {
+		if locked.ChainID == 0 || len(locked.Endpoints) == 0 {
+			return nil, fmt.Errorf("invalid lock file %s: chain '%s' has no chain ID or endpoints", path, locked.Chain)
+		}
+	}
+	return lock, nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// POLICY_VERSION is the version of the policy file format written by this build
+const POLICY_VERSION = 1
+
+// Policy is the personal curation layer bundled into a single file, for teams to
+// share a common endpoint policy
+type Policy struct {
+	Version int `json:"version"`
+	// Tags are the endpoint tags, see Tags
+	Tags Tags `json:"tags,omitempty"`
+	// Chains are the local chain registry entries, kept as written
+	Chains []json.RawMessage `json:"chains,omitempty"`
+}
+
+// ReadPolicy reads and validates a policy file
+func ReadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %v", err)
+	}
+
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %s: %v", path, err)
+	}
+	if policy.Version < 1 || policy.Version > POLICY_VERSION {
+		return nil, fmt.Errorf("unsupported policy version %d in %s, expected %d", policy.Version, path, POLICY_VERSION)
+	}
+	for _, entry := range policy.Chains {
+		if _, err := RegistryChainID(entry); err != nil {
+			return nil, fmt.Errorf("invalid policy %s: %v", path, err)
+		}
+	}
+	return policy, nil
+}
+
+// LoadRegistryEntries reads the local chain registry as raw entries, so that they are
+// written back as they were. A missing file yields no entries.
+func LoadRegistryEntries(path string) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chain registry: %v", err)
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse chain registry %s: %v", path, err)
+	}
+	return entries, nil
+}
+
+// SaveRegistryEntries writes the local chain registry, creating its directory if needed
+func SaveRegistryEntries(path string, entries []json.RawMessage) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize chain registry: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write chain registry: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write chain registry: %v", err)
+	}
+	return nil
+}
+
+// MergeRegistryEntries adds the entries to the registry, replacing the entries of the same chain
+func MergeRegistryEntries(registry, entries []json.RawMessage) ([]json.RawMessage, error) {
+	index := make(map[uint64]int, len(registry))
+	for i, entry := range registry {
+		chainID, err := RegistryChainID(entry)
+		if err != nil {
+			return nil, err
+		}
+		index[chainID] = i
+	}
+
+	merged := append(make([]json.RawMessage, 0, len(registry)+len(entries)), registry...)
+	for _, entry := range entries {
+		chainID, err := RegistryChainID(entry)
+		if err != nil {
+			return nil, err
+		}
+		if i, exists := index[chainID]; exists {
+			merged[i] = entry
+			continue
+		}
+		index[chainID] = len(merged)
+		merged = append(merged, entry)
+	}
+	return merged, nil
+}
+
+// RegistryChainID returns the chain ID of a registry entry
+func RegistryChainID(entry json.RawMessage) (uint64, error) {
+	var chain struct {
+		Name    string `json:"name"`
+		ChainID uint64 `json:"chainId"`
+	}
+	if err := json.Unmarshal(entry, &chain); err != nil {
+		return 0, fmt.Errorf("invalid chain registry entry: %v", err)
+	}
+	if chain.ChainID == 0 {
+		return 0, fmt.Errorf("chain '%s' has no chainId", chain.Name)
+	}
+	return chain.ChainID, nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const TAGS_FILE_NAME = "tags.json"
+
+// Tags are the user tags of endpoints, e.g. "trusted" or "archive", by endpoint URL
+type Tags map[string][]string
+
+// DefaultTagsPath returns the location of the endpoint tags
+func DefaultTagsPath() string {
+	return filepath.Join(Dir(), TAGS_FILE_NAME)
+}
+
+// LoadTags reads the endpoint tags at path. A missing file yields no tags.
+func LoadTags(path string) (Tags, error) {
+	tags := make(Tags)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tags, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read endpoint tags: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint tags %s: %v", path, err)
+	}
+	return tags, nil
+}
+
+// Save writes the tags to path, creating its directory if needed
+func (t Tags) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize endpoint tags: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write endpoint tags: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write endpoint tags: %v", err)
+	}
+	return nil
+}
+
+// Add tags the endpoint, keeping its tags sorted and unique
+func (t Tags) Add(url string, tags ...string) {
+	seen := make(map[string]bool)
+	merged := make([]string, 0, len(t[url])+len(tags))
+	for _, tag := range append(t[url], tags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	sort.Strings(merged)
+	t[url] = merged
+}
+
+// Remove drops tags of the endpoint, all of them without tags, and forgets
+// endpoints left without tags
+func (t Tags) Remove(url string, tags ...string) {
+	if len(tags) == 0 {
+		delete(t, url)
+		return
+	}
+
+	remaining := make([]string, 0, len(t[url]))
+	for _, tag := range t[url] {
+		if !contains(tags, tag) {
+			remaining = append(remaining, tag)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(t, url)
+		return
+	}
+	t[url] = remaining
+}
+
+// HasAll reports whether the endpoint has every one of the tags
+func (t Tags) HasAll(url string, tags []string) bool {
+	for _, tag := range tags {
+		if !contains(t[url], tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
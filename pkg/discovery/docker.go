@@ -0,0 +1,143 @@
+// Package discovery finds RPC endpoints served by the local development environment
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DOCKER_SOCKET is where the Docker daemon listens unless DOCKER_HOST says otherwise
+const DOCKER_SOCKET = "/var/run/docker.sock"
+
+// RPC_PORTS are the container ports node images commonly serve JSON-RPC on
+// (geth, anvil, hardhat, reth, nitro, op-geth, ganache)
+var RPC_PORTS = []int{8545, 8546, 8547, 8548, 9545, 7545}
+
+// Container is a running container publishing RPC ports
+type Container struct {
+	Name  string
+	Image string
+	// URLs reach the published RPC ports from this host
+	URLs []string
+}
+
+type dockerContainer struct {
+	Names []string `json:"Names"`
+	Image string   `json:"Image"`
+	Ports []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+}
+
+// DockerContainers lists the running containers that publish one of RPC_PORTS to the
+// host, through the Docker Engine API. Ports that are not published are not reachable
+// from the host on every platform and are left out.
+func DockerContainers(ctx context.Context) ([]Container, error) {
+	client, base, err := dockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker: HTTP %d", resp.StatusCode)
+	}
+
+	var listed []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		return nil, fmt.Errorf("docker: invalid container list: %v", err)
+	}
+
+	containers := make([]Container, 0)
+	for _, listedContainer := range listed {
+		container := Container{Image: listedContainer.Image}
+		if len(listedContainer.Names) > 0 {
+			container.Name = strings.TrimPrefix(listedContainer.Names[0], "/")
+		}
+
+		seen := make(map[string]bool)
+		for _, port := range listedContainer.Ports {
+			if port.Type != "tcp" || port.PublicPort == 0 || !isRPCPort(port.PrivatePort) {
+				continue
+			}
+			rpcURL := "http://" + net.JoinHostPort(hostIP(port.IP), strconv.Itoa(port.PublicPort))
+			if !seen[rpcURL] {
+				seen[rpcURL] = true
+				container.URLs = append(container.URLs, rpcURL)
+			}
+		}
+		if len(container.URLs) > 0 {
+			sort.Strings(container.URLs)
+			containers = append(containers, container)
+		}
+	}
+	return containers, nil
+}
+
+// dockerClient returns a client of the daemon given by DOCKER_HOST, by default its
+// local socket, with the base URL of its API
+func dockerClient() (*http.Client, string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix://" + DOCKER_SOCKET
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid DOCKER_HOST %q: %v", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+		// The host name is ignored by the socket dialer
+		return &http.Client{Transport: transport}, "http://docker", nil
+	case "tcp", "http":
+		if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			return nil, "", fmt.Errorf("docker: TLS connections to the daemon are not supported")
+		}
+		return &http.Client{}, "http://" + u.Host, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported DOCKER_HOST %q, expected unix:// or tcp://", host)
+	}
+}
+
+// hostIP returns the address a port published on ip is reached at from this host
+func hostIP(ip string) string {
+	if ip == "" || ip == "0.0.0.0" || ip == "::" {
+		return "127.0.0.1"
+	}
+	return ip
+}
+
+func isRPCPort(port int) bool {
+	for _, rpcPort := range RPC_PORTS {
+		if port == rpcPort {
+			return true
+		}
+	}
+	return false
+}
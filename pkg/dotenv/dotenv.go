@@ -0,0 +1,159 @@
+// Package dotenv reads, updates and writes dotenv files, keeping everything but
+// the updated keys as it was
+package dotenv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// File is a dotenv file kept line by line, so that setting keys leaves comments,
+// blank lines, ordering and other keys alone
+type File struct {
+	lines []line
+}
+
+type line struct {
+	// key is empty for comments, blank lines and lines that are not assignments
+	key    string
+	export bool
+	value  string
+	raw    string
+}
+
+var assignmentPattern = regexp.MustCompile(`^\s*(export\s+)?([A-Za-z_][A-Za-z0-9_.]*)\s*=\s*(.*)$`)
+
+// Read parses the dotenv file at path, a missing file is empty
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return Parse(data), nil
+}
+
+// Parse reads KEY=value lines, optionally prefixed with export, with values bare,
+// single-quoted or double-quoted. Anything else is kept as is.
+func Parse(data []byte) *File {
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if text == "" {
+		return &File{}
+	}
+
+	file := &File{}
+	for _, raw := range strings.Split(text, "\n") {
+		l := line{raw: raw}
+		if match := assignmentPattern.FindStringSubmatch(raw); match != nil && !strings.HasPrefix(strings.TrimSpace(raw), "#") {
+			l.export = match[1] != ""
+			l.key = match[2]
+			l.value = unquote(match[3])
+		}
+		file.lines = append(file.lines, l)
+	}
+	return file
+}
+
+// Get returns the value of the key, the last one if it is set several times
+func (f *File) Get(key string) (string, bool) {
+	value, found := "", false
+	for _, l := range f.lines {
+		if l.key == key {
+			value, found = l.value, true
+		}
+	}
+	return value, found
+}
+
+// Set updates every assignment of the key in place, or appends one
+func (f *File) Set(key, value string) {
+	found := false
+	for i, l := range f.lines {
+		if l.key == key {
+			f.lines[i] = newLine(key, value, l.export)
+			found = true
+		}
+	}
+	if !found {
+		f.lines = append(f.lines, newLine(key, value, false))
+	}
+}
+
+// Bytes returns the file content, ending with a newline
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, l := range f.lines {
+		buf.WriteString(l.raw)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Write saves the file at path through a temporary file, so that it is never left
+// half-written, keeping the permissions of an existing file
+func (f *File) Write(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".dotenv-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(f.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+func newLine(key, value string, export bool) line {
+	raw := key + "=" + quote(value)
+	if export {
+		raw = "export " + raw
+	}
+	return line{key: key, export: export, value: value, raw: raw}
+}
+
+// quote leaves simple values bare and double-quotes the others
+func quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"'#$\\`\n") {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`, "`", "\\`")
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	switch {
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1]
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		replacer := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\$`, "$", "\\`", "`")
+		return replacer.Replace(value[1 : len(value)-1])
+	}
+	// An unquoted value ends at an inline comment
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
@@ -0,0 +1,127 @@
+package dotenv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		key   string
+		value string
+		want  string
+	}{
+		{
+			name:  "empty file",
+			key:   "RPC_URL",
+			value: "https://rpc.example",
+			want:  "RPC_URL=https://rpc.example\n",
+		},
+		{
+			name:  "appended after other keys and comments",
+			input: "# chain settings\nCHAIN_ID=1\n\nOTHER=x",
+			key:   "RPC_URL",
+			value: "https://rpc.example",
+			want:  "# chain settings\nCHAIN_ID=1\n\nOTHER=x\nRPC_URL=https://rpc.example\n",
+		},
+		{
+			name:  "updated in place",
+			input: "A=1\nRPC_URL=https://old.example # stale\nB=2\n",
+			key:   "RPC_URL",
+			value: "https://rpc.example",
+			want:  "A=1\nRPC_URL=https://rpc.example\nB=2\n",
+		},
+		{
+			name:  "export kept",
+			input: "export RPC_URL='https://old.example'\n",
+			key:   "RPC_URL",
+			value: "https://rpc.example",
+			want:  "export RPC_URL=https://rpc.example\n",
+		},
+		{
+			name:  "every assignment updated",
+			input: "RPC_URL=a\nRPC_URL=b\n",
+			key:   "RPC_URL",
+			value: "c",
+			want:  "RPC_URL=c\nRPC_URL=c\n",
+		},
+		{
+			name:  "commented assignment left alone",
+			input: "# RPC_URL=https://old.example\n",
+			key:   "RPC_URL",
+			value: "https://rpc.example",
+			want:  "# RPC_URL=https://old.example\nRPC_URL=https://rpc.example\n",
+		},
+		{
+			name:  "special characters quoted",
+			input: "CRLF=1\r\n",
+			key:   "RPC_URL",
+			value: "https://rpc.example/${KEY} #1",
+			want:  "CRLF=1\nRPC_URL=\"https://rpc.example/\\${KEY} #1\"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := Parse([]byte(test.input))
+			file.Set(test.key, test.value)
+			if got := string(file.Bytes()); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if value, _ := Parse(file.Bytes()).Get(test.key); value != test.value {
+				t.Errorf("value read back = %q, want %q", value, test.value)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	file := Parse([]byte("A=bare # comment\nB='single # quoted'\nC=\"double \\\"quoted\\\"\"\nexport D=exported\nA=last\nnot an assignment\n"))
+
+	tests := []struct {
+		key   string
+		value string
+		found bool
+	}{
+		{"A", "last", true},
+		{"B", "single # quoted", true},
+		{"C", `double "quoted"`, true},
+		{"D", "exported", true},
+		{"E", "", false},
+	}
+	for _, test := range tests {
+		value, found := file.Get(test.key)
+		if value != test.value || found != test.found {
+			t.Errorf("Get(%q) = %q, %v, want %q, %v", test.key, value, found, test.value, test.found)
+		}
+	}
+}
+
+func TestWriteKeepsPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("A=1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	file.Set("B", "2")
+	if err := file.Write(path); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("mode = %o, want 600", mode)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "A=1\nB=2\n" {
+		t.Errorf("content = %q", data)
+	}
+}
@@ -0,0 +1,286 @@
+// Package mock serves a minimal fake JSON-RPC endpoint, to test scripts and
+// endpoint testing without a real node
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"chain-rpc/pkg/rpc"
+
+	"github.com/gorilla/websocket"
+)
+
+// CLIENT_VERSION is what the server answers to web3_clientVersion
+const CLIENT_VERSION = "chain-rpc-mock/v1.0.0"
+
+// Error codes of JSON-RPC answers
+const (
+	ERR_PARSE            = -32700
+	ERR_METHOD_NOT_FOUND = -32601
+	ERR_INTERNAL         = -32603
+)
+
+// Server answers eth_chainId, net_version, eth_blockNumber, eth_syncing and
+// web3_clientVersion over HTTP and WebSocket, including batches and newHeads
+// subscriptions. Any other method is not found.
+type Server struct {
+	ChainID uint64
+	// BlockTime is how often the block number advances, 0 keeps it at 1
+	BlockTime time.Duration
+	// Latency delays every answer, by up to Jitter more
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate is the share of requests, from 0 to 1, failed on purpose...
+	ErrorRate float64
+	// ...with this HTTP status, or with a JSON-RPC internal error when 0 or over WebSocket
+	ErrorStatus int
+	// OnRequest, when set, is called concurrently with every request served
+	OnRequest func(method string, injectedError bool)
+
+	start    time.Time
+	mu       sync.Mutex
+	random   *rand.Rand
+	upgrader websocket.Upgrader
+}
+
+// NewServer returns a server of the chain whose block number starts at 1 now
+func NewServer(chainID uint64) *Server {
+	return &Server{
+		ChainID:  chainID,
+		start:    time.Now(),
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+	}
+}
+
+// BlockNumber returns the current block number
+func (s *Server) BlockNumber() uint64 {
+	if s.BlockTime <= 0 {
+		return 1
+	}
+	return 1 + uint64(time.Since(s.start)/s.BlockTime)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Browser dapps need the CORS preflight to pass
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	switch {
+	case websocket.IsWebSocketUpgrade(r):
+		s.serveWebSocket(w, r)
+	case r.Method == http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost:
+		s.servePost(w, r)
+	default:
+		http.Error(w, "JSON-RPC requests are POSTed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) servePost(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, errorResponse(0, ERR_PARSE, "parse error"))
+		return
+	}
+
+	batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	var requests []rpc.RPCRequest
+	if batch {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			writeJSON(w, errorResponse(0, ERR_PARSE, "parse error"))
+			return
+		}
+	} else {
+		var request rpc.RPCRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeJSON(w, errorResponse(0, ERR_PARSE, "parse error"))
+			return
+		}
+		requests = append(requests, request)
+	}
+
+	injected := s.injectError()
+	if err := s.delay(r.Context()); err != nil {
+		return
+	}
+	if injected && s.ErrorStatus != 0 {
+		for _, request := range requests {
+			s.report(request.Method, true)
+		}
+		http.Error(w, "injected error", s.ErrorStatus)
+		return
+	}
+
+	responses := make([]rpc.RPCResponse, 0, len(requests))
+	for _, request := range requests {
+		responses = append(responses, s.answer(request, injected))
+	}
+	if batch {
+		writeJSON(w, responses)
+	} else {
+		writeJSON(w, responses[0])
+	}
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Writes come from the request loop and from subscriptions
+	var writeMu sync.Mutex
+	write := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(v)
+	}
+
+	for {
+		var request rpc.RPCRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			return
+		}
+
+		injected := s.injectError()
+		if err := s.delay(ctx); err != nil {
+			return
+		}
+		if request.Method == "eth_subscribe" && !injected {
+			s.report(request.Method, false)
+			id := s.subscriptionID()
+			if err := write(rpc.RPCResponse{JSONRPC: "2.0", Result: quote(id), ID: request.ID}); err != nil {
+				return
+			}
+			go s.notifyNewHeads(ctx, id, write)
+			continue
+		}
+		if err := write(s.answer(request, injected)); err != nil {
+			return
+		}
+	}
+}
+
+// notifyNewHeads sends a newHeads notification for every new block until ctx is done
+func (s *Server) notifyNewHeads(ctx context.Context, id string, write func(any) error) {
+	interval := s.BlockTime
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		notification := map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "eth_subscription",
+			"params": map[string]any{
+				"subscription": id,
+				"result":       map[string]string{"number": fmt.Sprintf("0x%x", s.BlockNumber())},
+			},
+		}
+		if err := write(notification); err != nil {
+			return
+		}
+	}
+}
+
+// answer returns the response to a single request
+func (s *Server) answer(request rpc.RPCRequest, injected bool) rpc.RPCResponse {
+	s.report(request.Method, injected)
+	if injected {
+		return errorResponse(request.ID, ERR_INTERNAL, "injected error")
+	}
+
+	var result json.RawMessage
+	switch request.Method {
+	case "eth_chainId":
+		result = quote(fmt.Sprintf("0x%x", s.ChainID))
+	case "net_version":
+		result = quote(fmt.Sprintf("%d", s.ChainID))
+	case "eth_blockNumber":
+		result = quote(fmt.Sprintf("0x%x", s.BlockNumber()))
+	case "eth_syncing":
+		result = json.RawMessage("false")
+	case "web3_clientVersion":
+		result = quote(CLIENT_VERSION)
+	default:
+		return errorResponse(request.ID, ERR_METHOD_NOT_FOUND, fmt.Sprintf("the method %s does not exist/is not available", request.Method))
+	}
+	return rpc.RPCResponse{JSONRPC: "2.0", Result: result, ID: request.ID}
+}
+
+func (s *Server) subscriptionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("0x%x", s.random.Uint64())
+}
+
+func (s *Server) injectError() bool {
+	if s.ErrorRate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.random.Float64() < s.ErrorRate
+}
+
+// delay waits for Latency plus a random part of Jitter, or until ctx is done
+func (s *Server) delay(ctx context.Context) error {
+	latency := s.Latency
+	if s.Jitter > 0 {
+		s.mu.Lock()
+		latency += time.Duration(s.random.Int63n(int64(s.Jitter)))
+		s.mu.Unlock()
+	}
+	if latency <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(latency):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) report(method string, injected bool) {
+	if s.OnRequest != nil {
+		s.OnRequest(method, injected)
+	}
+}
+
+func errorResponse(id int, code int, message string) rpc.RPCResponse {
+	return rpc.RPCResponse{JSONRPC: "2.0", Error: &rpc.RPCError{Code: code, Message: message}, ID: id}
+}
+
+func quote(s string) json.RawMessage {
+	data, _ := json.Marshal(s)
+	return data
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
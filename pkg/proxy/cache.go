@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// CACHE_FOREVER is the lifetime of results that never change, e.g. of eth_chainId
+const CACHE_FOREVER time.Duration = -1
+
+// DefaultCacheTTLs returns the lifetimes of the results of the methods cached by
+// default: the chain never changes, the block number and gas price only with blocks
+func DefaultCacheTTLs() map[string]time.Duration {
+	return map[string]time.Duration{
+		"eth_chainId":     CACHE_FOREVER,
+		"net_version":     CACHE_FOREVER,
+		"eth_blockNumber": time.Second,
+		"eth_gasPrice":    time.Second,
+	}
+}
+
+// Cache keeps the results of the calls of its methods for their lifetime, to absorb
+// the bursts of polling clients. Errors are not cached.
+type Cache struct {
+	// TTLs are the lifetimes of the results by method, CACHE_FOREVER for ever. Calls
+	// of other methods are not cached.
+	TTLs map[string]time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	result  json.RawMessage
+	expires time.Time
+}
+
+func NewCache(ttls map[string]time.Duration) *Cache {
+	return &Cache{TTLs: ttls, entries: make(map[string]cacheEntry)}
+}
+
+// get returns the cached result of the call, if it has not expired
+func (c *Cache) get(method string, params json.RawMessage, now time.Time) (json.RawMessage, bool) {
+	if _, cached := c.TTLs[method]; !cached {
+		return nil, false
+	}
+	key := callKey(method, params)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// put caches the result of the call, if its method is cached
+func (c *Cache) put(method string, params json.RawMessage, result json.RawMessage, now time.Time) {
+	ttl, cached := c.TTLs[method]
+	if !cached || ttl == 0 {
+		return
+	}
+	entry := cacheEntry{result: result}
+	if ttl != CACHE_FOREVER {
+		entry.expires = now.Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[callKey(method, params)] = entry
+}
+
+// callKey identifies the calls of a method with the same parameters, however they
+// are spaced out
+func callKey(method string, params json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, params); err != nil || compact.Len() == 0 || compact.String() == "null" {
+		compact.Reset()
+		compact.WriteString("[]")
+	}
+	return method + " " + compact.String()
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CHAOS_FAULTS are the simulated upstream failures chaos testing injects
+var CHAOS_FAULTS = []string{"timeout", "rate-limit", "server-error", "rpc-error", "malformed", "disconnect"}
+
+// CHAOS_TIMEOUT is how long the timeout fault holds a request when the proxy has no Timeout
+const CHAOS_TIMEOUT = 30 * time.Second
+
+// CheckChaosFaults returns an error for the first fault that is not one of CHAOS_FAULTS
+func CheckChaosFaults(faults []string) error {
+	for _, fault := range faults {
+		known := false
+		for _, name := range CHAOS_FAULTS {
+			known = known || fault == name
+		}
+		if !known {
+			return fmt.Errorf("unknown chaos fault %q, expected one of %s", fault, strings.Join(CHAOS_FAULTS, ", "))
+		}
+	}
+	return nil
+}
+
+// chaosFault returns the fault to inject into a request, none when empty
+func (p *Proxy) chaosFault() string {
+	if p.Chaos <= 0 || rand.Float64() >= p.Chaos {
+		return ""
+	}
+	faults := p.ChaosFaults
+	if len(faults) == 0 {
+		faults = CHAOS_FAULTS
+	}
+	return faults[rand.Intn(len(faults))]
+}
+
+// injectFault answers the requests with a simulated upstream failure, for the Chaos
+// share of them, and reports whether it did
+func (p *Proxy) injectFault(w http.ResponseWriter, r *http.Request, requests []request, batch bool) bool {
+	fault := p.chaosFault()
+	if fault == "" {
+		return false
+	}
+	if p.OnChaos != nil {
+		methods := make([]string, len(requests))
+		for i, req := range requests {
+			methods[i] = req.Method
+		}
+		p.OnChaos(fault, methods)
+	}
+
+	// Lets clients tell injected failures from real ones in their logs
+	w.Header().Set("X-Chaos-Fault", fault)
+	switch fault {
+	case "timeout":
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = CHAOS_TIMEOUT
+		}
+		select {
+		case <-time.After(timeout):
+			http.Error(w, "upstream timed out (injected by --chaos)", http.StatusGatewayTimeout)
+		case <-r.Context().Done():
+		}
+	case "rate-limit":
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many requests (injected by --chaos)", http.StatusTooManyRequests)
+	case "server-error":
+		http.Error(w, "bad gateway (injected by --chaos)", http.StatusBadGateway)
+	case "rpc-error":
+		responses := make([]response, len(requests))
+		for i, req := range requests {
+			responses[i] = errorResponse(ERR_UPSTREAM, "internal error (injected by --chaos)")
+			responses[i].ID = req.ID
+		}
+		if batch {
+			writeJSON(w, responses)
+		} else {
+			writeJSON(w, responses[0])
+		}
+	case "malformed":
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":`)
+	case "disconnect":
+		// Closes the connection without an answer
+		panic(http.ErrAbortHandler)
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"strings"
+)
+
+// READ_METHODS are the methods without side effects, besides eth_get* ones, whose
+// identical concurrent calls share an upstream call
+var READ_METHODS = map[string]bool{
+	"eth_call":                 true,
+	"eth_estimateGas":          true,
+	"eth_createAccessList":     true,
+	"eth_blockNumber":          true,
+	"eth_chainId":              true,
+	"eth_gasPrice":             true,
+	"eth_maxPriorityFeePerGas": true,
+	"eth_blobBaseFee":          true,
+	"eth_feeHistory":           true,
+	"eth_syncing":              true,
+	"net_version":              true,
+	"web3_clientVersion":       true,
+}
+
+// isRead reports whether calls of the method only read the chain. Filters are
+// excluded, as their changes are consumed by reading them.
+func isRead(method string) bool {
+	if READ_METHODS[method] {
+		return true
+	}
+	return strings.HasPrefix(method, "eth_get") && !strings.Contains(method, "Filter")
+}
+
+// flight is an upstream call that concurrent identical calls wait for
+type flight struct {
+	done   chan struct{}
+	answer response
+}
+
+// join returns the flight of the call with the key, and whether the caller leads it:
+// the leader makes the call and lands the flight, the others wait for it
+func (p *Proxy) join(key string) (*flight, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if f, exists := p.flights[key]; exists {
+		return f, false
+	}
+	if p.flights == nil {
+		p.flights = make(map[string]*flight)
+	}
+	f := &flight{done: make(chan struct{})}
+	p.flights[key] = f
+	return f, true
+}
+
+// land hands the answer of the call over to the calls waiting for it. Calls made
+// from then on go upstream again.
+func (p *Proxy) land(key string, f *flight, answer response) {
+	p.mu.Lock()
+	delete(p.flights, key)
+	p.mu.Unlock()
+
+	f.answer = answer
+	close(f.done)
+}
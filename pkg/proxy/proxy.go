@@ -0,0 +1,364 @@
+// Package proxy serves a JSON-RPC endpoint forwarding requests to the working endpoints
+// of a chain, failing over to another endpoint when one fails
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"chain-rpc/pkg/rpc"
+)
+
+const (
+	// ATTEMPTS is the default number of endpoints a request is tried on
+	ATTEMPTS = 3
+	// MAX_REQUEST_SIZE bounds the body of the requests served
+	MAX_REQUEST_SIZE = 5 << 20
+)
+
+// Error codes of the JSON-RPC errors answered by the proxy itself
+const (
+	ERR_PARSE           = -32700
+	ERR_INVALID_REQUEST = -32600
+	ERR_UPSTREAM        = -32603
+	// ERR_METHOD_NOT_ALLOWED is "method not supported" of EIP-1474
+	ERR_METHOD_NOT_ALLOWED = -32004
+)
+
+// Proxy forwards the JSON-RPC requests and batches it serves over HTTP to the
+// endpoint of the chain picked by its strategy among the working ones of its pool.
+// When the endpoint fails, times out or answers with an HTTP error, the request is
+// sent to another one. JSON-RPC errors of endpoints are answered as they are.
+type Proxy struct {
+	Pool     *rpc.Pool
+	ChainID  uint64
+	Strategy rpc.Strategy
+	// Attempts bounds the endpoints a request is tried on, ATTEMPTS when not positive
+	Attempts int
+	// Timeout bounds each attempt, no limit when 0
+	Timeout time.Duration
+	// Cache, when set, answers idempotent calls with the results of earlier ones
+	Cache *Cache
+	// Coalesce makes identical concurrent calls of read methods share an upstream call
+	Coalesce bool
+	// Allow, when set, are the only methods forwarded, and Deny methods never forwarded,
+	// as globs of path.Match like admin_*. Others are answered ERR_METHOD_NOT_ALLOWED.
+	Allow []string
+	Deny  []string
+	// Budgets, when set, records the outcome of every attempt, and endpoints it demotes
+	// only get requests when no other endpoint works
+	Budgets *rpc.BudgetTracker
+	// Chaos is the share of requests, from 0 to 1, answered with one of ChaosFaults,
+	// CHAOS_FAULTS when empty, instead of being forwarded, to test the retries of clients
+	Chaos       float64
+	ChaosFaults []string
+	// OnChaos, when set, is called with every fault injected
+	OnChaos func(fault string, methods []string)
+	// OnForward, when set, is called with the outcome of every attempt
+	OnForward func(rpcURL string, methods []string, latency time.Duration, err error)
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func New(pool *rpc.Pool, chainID uint64, strategy rpc.Strategy) *Proxy {
+	return &Proxy{Pool: pool, ChainID: chainID, Strategy: strategy, Attempts: ATTEMPTS}
+}
+
+// request is a JSON-RPC request, with its parameters and ID left undecoded
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response, whose result or error is passed on as it is
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+func errorResponse(code int, message string) response {
+	data, _ := json.Marshal(rpc.RPCError{Code: code, Message: message})
+	return response{JSONRPC: "2.0", Error: data}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Dapps in the browser reach the proxy from their own origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "JSON-RPC requests are POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_REQUEST_SIZE))
+	if err != nil {
+		writeJSON(w, errorResponse(ERR_PARSE, fmt.Sprintf("failed to read request: %v", err)))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []request
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeJSON(w, errorResponse(ERR_PARSE, fmt.Sprintf("invalid JSON: %v", err)))
+			return
+		}
+		if len(batch) == 0 {
+			writeJSON(w, errorResponse(ERR_INVALID_REQUEST, "empty batch"))
+			return
+		}
+		if p.injectFault(w, r, batch, true) {
+			return
+		}
+		writeJSON(w, p.handle(r.Context(), batch))
+		return
+	}
+
+	var single request
+	if err := json.Unmarshal(body, &single); err != nil {
+		writeJSON(w, errorResponse(ERR_PARSE, fmt.Sprintf("invalid JSON: %v", err)))
+		return
+	}
+	if p.injectFault(w, r, []request{single}, false) {
+		return
+	}
+	writeJSON(w, p.handle(r.Context(), []request{single})[0])
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handle answers the requests, from the cache, or with the answer of an identical call
+// in flight, or else from an endpoint, in a single upstream request for a batch
+func (p *Proxy) handle(ctx context.Context, requests []request) []response {
+	responses := make([]response, len(requests))
+	var forwarded []int
+	// The flights led by the forwarded requests, and the ones others wait for
+	led := make(map[int]*flight)
+	waiting := make(map[int]*flight)
+	for i, req := range requests {
+		if req.Method == "" {
+			responses[i] = errorResponse(ERR_INVALID_REQUEST, "missing method")
+			continue
+		}
+		if !p.allowed(req.Method) {
+			responses[i] = errorResponse(ERR_METHOD_NOT_ALLOWED, fmt.Sprintf("method %s is not allowed by the proxy", req.Method))
+			continue
+		}
+		if p.Cache != nil {
+			if result, ok := p.Cache.get(req.Method, req.Params, time.Now()); ok {
+				responses[i] = response{Result: result}
+				continue
+			}
+		}
+		if p.Coalesce && isRead(req.Method) {
+			f, leader := p.join(callKey(req.Method, req.Params))
+			if !leader {
+				waiting[i] = f
+				continue
+			}
+			led[i] = f
+		}
+		forwarded = append(forwarded, i)
+	}
+
+	if len(forwarded) > 0 {
+		upstream := make([]request, len(forwarded))
+		for j, i := range forwarded {
+			upstream[j] = requests[i]
+		}
+		answers, err := p.forward(ctx, upstream)
+		for j, i := range forwarded {
+			if err != nil {
+				responses[i] = errorResponse(ERR_UPSTREAM, err.Error())
+			} else {
+				responses[i] = answers[j]
+				if p.Cache != nil && len(answers[j].Error) == 0 {
+					p.Cache.put(requests[i].Method, requests[i].Params, answers[j].Result, time.Now())
+				}
+			}
+			if f, leads := led[i]; leads {
+				p.land(callKey(requests[i].Method, requests[i].Params), f, responses[i])
+			}
+		}
+	}
+
+	for i, f := range waiting {
+		select {
+		case <-f.done:
+			responses[i] = f.answer
+		case <-ctx.Done():
+			responses[i] = errorResponse(ERR_UPSTREAM, ctx.Err().Error())
+		}
+	}
+
+	for i := range responses {
+		responses[i].JSONRPC = "2.0"
+		responses[i].ID = requests[i].ID
+	}
+	return responses
+}
+
+// allowed reports whether the method may be forwarded
+func (p *Proxy) allowed(method string) bool {
+	if matchesAny(p.Deny, method) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchesAny(p.Allow, method)
+}
+
+func matchesAny(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, method); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckMethodPatterns returns an error for the first malformed method glob
+func CheckMethodPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid method pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// forward sends the requests to the endpoints picked one after the other, until one
+// answers all of them
+func (p *Proxy) forward(ctx context.Context, requests []request) ([]response, error) {
+	attempts := p.Attempts
+	if attempts <= 0 {
+		attempts = ATTEMPTS
+	}
+	methods := make([]string, len(requests))
+	for i, req := range requests {
+		methods[i] = req.Method
+	}
+
+	strategy := p.Strategy
+	if p.Budgets != nil {
+		strategy = p.Budgets.Strategy(strategy)
+	}
+	excluded := make(map[string]bool)
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		picked, err := p.Pool.PickExcluding(ctx, p.ChainID, strategy, excluded)
+		if err != nil {
+			if lastErr == nil || !errors.Is(err, rpc.ErrNoRPCsFound) {
+				lastErr = err
+			}
+			break
+		}
+
+		start := time.Now()
+		responses, err := p.send(ctx, picked.URL, requests)
+		latency := time.Since(start)
+		// Requests given up by their client say nothing of the endpoint
+		if p.Budgets != nil && ctx.Err() == nil {
+			p.Budgets.Observe(picked.URL, latency, err == nil, time.Now())
+		}
+		if p.OnForward != nil {
+			p.OnForward(picked.URL, methods, latency, err)
+		}
+		if err == nil {
+			return responses, nil
+		}
+		excluded[picked.URL] = true
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("no endpoint answered: %v", lastErr)
+}
+
+// send sends the requests to the endpoint, as a batch when there are several, with
+// IDs of their own so that answers are matched whatever IDs clients use
+func (p *Proxy) send(ctx context.Context, rpcURL string, requests []request) ([]response, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	upstream := make([]request, len(requests))
+	for i, req := range requests {
+		upstream[i] = request{JSONRPC: "2.0", ID: json.RawMessage(strconv.Itoa(i + 1)), Method: req.Method, Params: req.Params}
+	}
+	var payload any = upstream
+	if len(upstream) == 1 {
+		// Not every endpoint serves batches
+		payload = upstream[0]
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := rpc.Forward(ctx, rpcURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var answers []response
+	if len(upstream) == 1 {
+		var answer response
+		if err := json.Unmarshal(data, &answer); err != nil {
+			return nil, fmt.Errorf("invalid response: %v", err)
+		}
+		answer.ID = upstream[0].ID
+		answers = []response{answer}
+	} else if err := json.Unmarshal(data, &answers); err != nil {
+		return nil, fmt.Errorf("invalid batch response: %v", err)
+	}
+
+	responses := make([]response, len(requests))
+	answered := make([]bool, len(requests))
+	for _, answer := range answers {
+		id, err := strconv.Atoi(string(answer.ID))
+		if err != nil || id < 1 || id > len(requests) {
+			continue
+		}
+		// Some nodes answer "error": null along with the result
+		if len(answer.Error) > 0 && string(answer.Error) != "null" {
+			answer.Result = nil
+		} else if len(answer.Result) > 0 {
+			answer.Error = nil
+		} else {
+			return nil, fmt.Errorf("invalid response: neither result nor error")
+		}
+		responses[id-1] = answer
+		answered[id-1] = true
+	}
+	for i := range answered {
+		if !answered[i] {
+			return nil, fmt.Errorf("no answer to %s", requests[i].Method)
+		}
+	}
+	return responses, nil
+}
@@ -0,0 +1,324 @@
+package proxy_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"chain-rpc/pkg/proxy"
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/rpctest"
+)
+
+const testChainID = 424242
+
+// startProxy serves a proxy of the endpoints, once each of them was probed
+func startProxy(t *testing.T, rpcURLs ...string) (*proxy.Proxy, *httptest.Server) {
+	t.Helper()
+
+	pool := rpc.NewPool(rpc.NewTester(time.Second))
+	pool.Rate = 1000
+	pool.Add(testChainID, rpcURLs...)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	pool.Start(ctx)
+	for deadline := time.Now().Add(5 * time.Second); !pool.Probed(testChainID); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("endpoints not probed in time")
+		}
+	}
+
+	p := proxy.New(pool, testChainID, rpc.FirstStrategy{})
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	return p, server
+}
+
+// post sends the body to the proxy and decodes its answer
+func post(t *testing.T, url, body string, answer any) {
+	t.Helper()
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(answer); err != nil {
+		t.Fatalf("invalid answer: %v", err)
+	}
+}
+
+type answer struct {
+	ID     json.RawMessage `json:"id"`
+	Result string          `json:"result"`
+	Error  *rpc.RPCError   `json:"error"`
+}
+
+func TestProxyFailover(t *testing.T) {
+	failing := rpctest.NewServer(testChainID)
+	defer failing.Close()
+	healthy := rpctest.NewServer(testChainID)
+	defer healthy.Close()
+
+	_, server := startProxy(t, failing.URL, healthy.URL)
+	failing.SetScript(rpctest.HTTPError(http.StatusBadGateway))
+	healthy.SetScript()
+
+	var got answer
+	post(t, server.URL, `{"jsonrpc":"2.0","id":"a","method":"eth_chainId"}`, &got)
+	if got.Error != nil || got.Result != "0x67932" {
+		t.Fatalf("answer = %+v, want the chain ID", got)
+	}
+	if string(got.ID) != `"a"` {
+		t.Errorf("id = %s, want the one of the request", got.ID)
+	}
+	if failing.Requests() != 1 || healthy.Requests() != 1 {
+		t.Errorf("requests = %d to the failing endpoint and %d to the healthy one, want 1 and 1", failing.Requests(), healthy.Requests())
+	}
+}
+
+func TestProxyBatch(t *testing.T) {
+	upstream := rpctest.NewServer(testChainID)
+	defer upstream.Close()
+
+	_, server := startProxy(t, upstream.URL)
+
+	// Clients may reuse IDs, answers keep the order of the batch
+	var got []answer
+	post(t, server.URL, `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":1,"method":"eth_unknown"},{"jsonrpc":"2.0","id":2}]`, &got)
+	if len(got) != 3 {
+		t.Fatalf("got %d answers, want 3", len(got))
+	}
+	if got[0].Result != "0x67932" {
+		t.Errorf("first answer = %+v, want the chain ID", got[0])
+	}
+	if got[1].Error == nil || got[1].Error.Code != -32601 {
+		t.Errorf("second answer = %+v, want the method not found error of the endpoint", got[1])
+	}
+	if got[2].Error == nil || got[2].Error.Code != proxy.ERR_INVALID_REQUEST {
+		t.Errorf("third answer = %+v, want an invalid request error", got[2])
+	}
+}
+
+func TestProxyCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		wait     time.Duration
+		requests int
+	}{
+		{name: "cached for ever", method: "eth_chainId", wait: 600 * time.Millisecond, requests: 1},
+		{name: "cached briefly", method: "eth_blockNumber", requests: 1},
+		{name: "expired", method: "eth_blockNumber", wait: 600 * time.Millisecond, requests: 2},
+		{name: "not cached", method: "web3_clientVersion", requests: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			upstream := rpctest.NewServer(testChainID)
+			defer upstream.Close()
+
+			p, server := startProxy(t, upstream.URL)
+			p.Cache = proxy.NewCache(map[string]time.Duration{
+				"eth_chainId":     proxy.CACHE_FOREVER,
+				"eth_blockNumber": 500 * time.Millisecond,
+			})
+			upstream.SetScript()
+
+			body := `{"jsonrpc":"2.0","id":1,"method":"` + test.method + `","params":[]}`
+			var first, second answer
+			post(t, server.URL, body, &first)
+			time.Sleep(test.wait)
+			post(t, server.URL, strings.Replace(body, `"params":[]`, `"params":null`, 1), &second)
+
+			if first.Error != nil || second.Error != nil {
+				t.Fatalf("errors: %v, %v", first.Error, second.Error)
+			}
+			if upstream.Requests() != test.requests {
+				t.Errorf("requests = %d, want %d", upstream.Requests(), test.requests)
+			}
+		})
+	}
+}
+
+func TestProxyCoalesce(t *testing.T) {
+	const clients = 5
+
+	tests := []struct {
+		name     string
+		method   string
+		requests int
+	}{
+		{name: "read", method: "eth_blockNumber", requests: 1},
+		{name: "write", method: "eth_sendRawTransaction", requests: clients},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			upstream := rpctest.NewServer(testChainID)
+			defer upstream.Close()
+
+			p, server := startProxy(t, upstream.URL)
+			p.Coalesce = true
+			upstream.SetScript(rpctest.Delay(200 * time.Millisecond))
+
+			done := make(chan answer, clients)
+			for i := 0; i < clients; i++ {
+				go func(id int) {
+					var got answer
+					body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"%s","params":["0x01"]}`, id, test.method)
+					resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+					if err != nil {
+						t.Error(err)
+						done <- got
+						return
+					}
+					defer resp.Body.Close()
+					if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+						t.Errorf("invalid answer: %v", err)
+					}
+					done <- got
+				}(i)
+			}
+			ids := make(map[string]bool)
+			for i := 0; i < clients; i++ {
+				ids[string((<-done).ID)] = true
+			}
+
+			if len(ids) != clients {
+				t.Errorf("got %d distinct IDs, want the %d of the requests", len(ids), clients)
+			}
+			if upstream.Requests() != test.requests {
+				t.Errorf("requests = %d, want %d", upstream.Requests(), test.requests)
+			}
+		})
+	}
+}
+
+func TestProxyMethods(t *testing.T) {
+	upstream := rpctest.NewServer(testChainID)
+	defer upstream.Close()
+
+	p, server := startProxy(t, upstream.URL)
+	p.Allow = []string{"eth_*", "net_version"}
+	p.Deny = []string{"eth_sendRawTransaction", "eth_sign*"}
+
+	tests := []struct {
+		method  string
+		allowed bool
+	}{
+		{method: "eth_chainId", allowed: true},
+		{method: "net_version", allowed: true},
+		{method: "eth_sendRawTransaction"},
+		{method: "eth_signTypedData_v4"},
+		{method: "admin_peers"},
+		{method: "net_peerCount"},
+	}
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			upstream.SetScript()
+
+			var got answer
+			post(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"`+test.method+`"}`, &got)
+			blocked := got.Error != nil && got.Error.Code == proxy.ERR_METHOD_NOT_ALLOWED
+			if blocked == test.allowed {
+				t.Errorf("answer = %+v, allowed %t", got, test.allowed)
+			}
+			if forwarded := upstream.Requests() > 0; forwarded != test.allowed {
+				t.Errorf("forwarded = %t, want %t", forwarded, test.allowed)
+			}
+		})
+	}
+}
+
+func TestProxyBudgets(t *testing.T) {
+	slow := rpctest.NewServer(testChainID)
+	defer slow.Close()
+	fast := rpctest.NewServer(testChainID)
+	defer fast.Close()
+
+	p, server := startProxy(t, slow.URL, fast.URL)
+	p.Budgets = rpc.NewBudgetTracker(rpc.Budget{P95: 50 * time.Millisecond, Recovery: time.Hour})
+	slow.SetScript(rpctest.Delay(100 * time.Millisecond))
+	fast.SetScript()
+
+	for i := 0; i < rpc.BUDGET_MIN_OUTCOMES+2; i++ {
+		var got answer
+		post(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`, &got)
+		if got.Error != nil {
+			t.Fatalf("error: %v", got.Error)
+		}
+	}
+
+	// The slow endpoint is demoted once it has enough outcomes
+	if slow.Requests() != rpc.BUDGET_MIN_OUTCOMES || fast.Requests() != 2 {
+		t.Errorf("requests = %d to the slow endpoint and %d to the fast one, want %d and 2", slow.Requests(), fast.Requests(), rpc.BUDGET_MIN_OUTCOMES)
+	}
+}
+
+func TestProxyChaos(t *testing.T) {
+	upstream := rpctest.NewServer(testChainID)
+	defer upstream.Close()
+
+	p, server := startProxy(t, upstream.URL)
+	p.Chaos = 1
+	p.Timeout = 50 * time.Millisecond
+
+	tests := []struct {
+		fault  string
+		status int
+		code   int
+	}{
+		{fault: "timeout", status: http.StatusGatewayTimeout},
+		{fault: "rate-limit", status: http.StatusTooManyRequests},
+		{fault: "server-error", status: http.StatusBadGateway},
+		{fault: "rpc-error", status: http.StatusOK, code: proxy.ERR_UPSTREAM},
+		{fault: "malformed", status: http.StatusOK},
+		{fault: "disconnect"},
+	}
+	for _, test := range tests {
+		t.Run(test.fault, func(t *testing.T) {
+			upstream.SetScript()
+			p.ChaosFaults = []string{test.fault}
+
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"eth_blockNumber"}`))
+			if upstream.Requests() != 0 {
+				t.Errorf("forwarded %d requests, want none", upstream.Requests())
+			}
+			if test.status == 0 {
+				if err == nil {
+					resp.Body.Close()
+					t.Error("answered, want the connection closed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != test.status || resp.Header.Get("X-Chaos-Fault") != test.fault {
+				t.Errorf("status %d with fault %q, want %d with %q", resp.StatusCode, resp.Header.Get("X-Chaos-Fault"), test.status, test.fault)
+			}
+
+			var got answer
+			err = json.NewDecoder(resp.Body).Decode(&got)
+			switch {
+			case test.fault == "malformed":
+				if err == nil {
+					t.Error("valid answer, want malformed")
+				}
+			case test.code != 0:
+				if err != nil || got.Error == nil || got.Error.Code != test.code || string(got.ID) != "7" {
+					t.Errorf("answer = %+v (%v), want error %d with id 7", got, err, test.code)
+				}
+			}
+		})
+	}
+
+	if err := proxy.CheckChaosFaults([]string{"timeout", "meteor"}); err == nil {
+		t.Error("unknown fault accepted")
+	}
+}
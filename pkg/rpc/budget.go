@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// BUDGET_WINDOW is the default number of recent outcomes of an endpoint checked
+	// against the budget
+	BUDGET_WINDOW = 20
+	// BUDGET_MIN_OUTCOMES is the number of outcomes an endpoint needs to be judged
+	BUDGET_MIN_OUTCOMES = 5
+	// BUDGET_RECOVERY is the default time a demoted endpoint stays within budget before
+	// it is promoted back
+	BUDGET_RECOVERY = time.Minute
+)
+
+// Budget is the latency and error rate endpoints are expected to stay within
+type Budget struct {
+	// P95 is the 95th percentile latency allowed, no limit when 0
+	P95 time.Duration
+	// ErrorRate is the share of failures allowed, from 0 to 1, no limit when 0
+	ErrorRate float64
+	// Window is the number of recent outcomes of an endpoint checked, BUDGET_WINDOW
+	// when not positive
+	Window int
+	// Recovery is how long a demoted endpoint stays within budget before it is promoted
+	Recovery time.Duration
+}
+
+// BudgetChange is the demotion of an endpoint exceeding the budget, or its promotion
+// once back within it, with the figures that caused it
+type BudgetChange struct {
+	Time      time.Time
+	URL       string
+	Demoted   bool
+	P95       time.Duration
+	ErrorRate float64
+}
+
+// BudgetTracker checks the recent outcomes of endpoints, probes or requests, against
+// a budget. Endpoints exceeding it are demoted, and promoted back once they have
+// stayed within it for the recovery time.
+type BudgetTracker struct {
+	Budget Budget
+	// OnChange, when set, is called with every demotion and promotion
+	OnChange func(BudgetChange)
+
+	mu        sync.Mutex
+	endpoints map[string]*budgetEndpoint
+}
+
+type budgetEndpoint struct {
+	latencies []time.Duration
+	failures  []bool
+	demoted   bool
+	// within is since when a demoted endpoint is within budget, zero when it is not
+	within time.Time
+}
+
+func NewBudgetTracker(budget Budget) *BudgetTracker {
+	return &BudgetTracker{Budget: budget, endpoints: make(map[string]*budgetEndpoint)}
+}
+
+// Observe records an outcome of the endpoint, a failure unless ok, and demotes or
+// promotes it
+func (t *BudgetTracker) Observe(rpcURL string, latency time.Duration, ok bool, now time.Time) {
+	window := t.Budget.Window
+	if window <= 0 {
+		window = BUDGET_WINDOW
+	}
+
+	t.mu.Lock()
+	endpoint, exists := t.endpoints[rpcURL]
+	if !exists {
+		endpoint = &budgetEndpoint{}
+		t.endpoints[rpcURL] = endpoint
+	}
+	endpoint.latencies = append(endpoint.latencies, latency)
+	endpoint.failures = append(endpoint.failures, !ok)
+	if len(endpoint.failures) > window {
+		endpoint.latencies = endpoint.latencies[1:]
+		endpoint.failures = endpoint.failures[1:]
+	}
+	if len(endpoint.failures) < BUDGET_MIN_OUTCOMES {
+		t.mu.Unlock()
+		return
+	}
+
+	p95, errorRate := endpoint.stats()
+	exceeded := (t.Budget.P95 > 0 && p95 > t.Budget.P95) || (t.Budget.ErrorRate > 0 && errorRate > t.Budget.ErrorRate)
+	changed := false
+	switch {
+	case exceeded:
+		changed = !endpoint.demoted
+		endpoint.demoted = true
+		endpoint.within = time.Time{}
+	case !endpoint.demoted:
+	case endpoint.within.IsZero():
+		endpoint.within = now
+	case now.Sub(endpoint.within) >= t.Budget.Recovery:
+		changed = true
+		endpoint.demoted = false
+		endpoint.within = time.Time{}
+	}
+	demoted := endpoint.demoted
+	t.mu.Unlock()
+
+	if changed && t.OnChange != nil {
+		t.OnChange(BudgetChange{Time: now, URL: rpcURL, Demoted: demoted, P95: p95, ErrorRate: errorRate})
+	}
+}
+
+// Demoted reports whether the endpoint exceeded the budget and has not recovered yet
+func (t *BudgetTracker) Demoted(rpcURL string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	endpoint, exists := t.endpoints[rpcURL]
+	return exists && endpoint.demoted
+}
+
+// Strategy returns the strategy picking among the endpoints that are not demoted,
+// and among the demoted ones only when no other works
+func (t *BudgetTracker) Strategy(strategy Strategy) Strategy {
+	return TierStrategy{Strategy: strategy, Tier: func(rpcURL string) int {
+		if t.Demoted(rpcURL) {
+			return 1
+		}
+		return 0
+	}}
+}
+
+// stats returns the 95th percentile latency of the successful outcomes, by nearest
+// rank, and the share of failures
+func (e *budgetEndpoint) stats() (time.Duration, float64) {
+	var latencies []time.Duration
+	failures := 0
+	for i, failed := range e.failures {
+		if failed {
+			failures++
+		} else {
+			latencies = append(latencies, e.latencies[i])
+		}
+	}
+	errorRate := float64(failures) / float64(len(e.failures))
+	if len(latencies) == 0 {
+		return 0, errorRate
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+	return latencies[rank], errorRate
+}
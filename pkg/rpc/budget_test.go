@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetTracker(t *testing.T) {
+	const url = "https://rpc.example"
+	fast := budgetStep{latency: 50 * time.Millisecond, ok: true}
+	slow := budgetStep{latency: time.Second, ok: true}
+	failed := budgetStep{}
+
+	tests := []struct {
+		name    string
+		steps   []budgetStep
+		demoted bool
+		changes int
+	}{
+		{name: "within budget", steps: repeat(fast, 10)},
+		{name: "too few outcomes", steps: repeat(failed, BUDGET_MIN_OUTCOMES-1)},
+		{name: "slow", steps: append(repeat(fast, 5), repeat(slow, 2)...), demoted: true, changes: 1},
+		{name: "failing", steps: append(repeat(fast, 8), repeat(failed, 2)...), demoted: true, changes: 1},
+		{name: "not recovered for long enough", steps: append(append(repeat(fast, 5), repeat(failed, 5)...), repeat(fast, 12)...), demoted: true, changes: 1},
+		{name: "recovered", steps: append(append(repeat(fast, 5), repeat(failed, 5)...), repeat(fast, 30)...), changes: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := NewBudgetTracker(Budget{P95: 500 * time.Millisecond, ErrorRate: 0.1, Window: 10, Recovery: 5 * time.Second})
+			changes := 0
+			tracker.OnChange = func(BudgetChange) { changes++ }
+
+			now := time.Now()
+			for _, step := range test.steps {
+				tracker.Observe(url, step.latency, step.ok, now)
+				now = now.Add(time.Second)
+			}
+
+			if tracker.Demoted(url) != test.demoted {
+				t.Errorf("demoted = %t, want %t", tracker.Demoted(url), test.demoted)
+			}
+			if changes != test.changes {
+				t.Errorf("changes = %d, want %d", changes, test.changes)
+			}
+		})
+	}
+}
+
+type budgetStep struct {
+	latency time.Duration
+	ok      bool
+}
+
+func repeat(step budgetStep, n int) []budgetStep {
+	steps := make([]budgetStep, n)
+	for i := range steps {
+		steps[i] = step
+	}
+	return steps
+}
@@ -0,0 +1,343 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Check is an extra probe step run once an endpoint served the expected chain ID.
+// It rejects the endpoint by returning an error and may record what it learned in
+// the result. Checks run in order, within the timeout of the attempt.
+type Check interface {
+	Name() string
+	Run(ctx context.Context, rpcURL string, result *RPCResult) error
+}
+
+// SyncingCheck rejects endpoints reporting through eth_syncing that they are still
+// syncing, as they serve the right chain ID but stale data
+type SyncingCheck struct{}
+
+func (SyncingCheck) Name() string { return "syncing" }
+
+func (SyncingCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	raw, err := Call(ctx, rpcURL, "eth_syncing")
+	if err != nil {
+		return fmt.Errorf("eth_syncing: %v", err)
+	}
+
+	var syncing bool
+	if err := json.Unmarshal(raw, &syncing); err == nil {
+		if syncing {
+			return fmt.Errorf("node is syncing")
+		}
+		return nil
+	}
+
+	var progress struct {
+		CurrentBlock string `json:"currentBlock"`
+		HighestBlock string `json:"highestBlock"`
+	}
+	if err := json.Unmarshal(raw, &progress); err != nil {
+		return fmt.Errorf("eth_syncing: invalid result %s", raw)
+	}
+	return fmt.Errorf("node is syncing (block %s of %s)", progress.CurrentBlock, progress.HighestBlock)
+}
+
+// ClientVersion is the node implementation reported by web3_clientVersion
+type ClientVersion struct {
+	// Name is the lowercase implementation name, e.g. geth, erigon, nethermind or reth
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Raw is the version string as reported by the node
+	Raw string `json:"raw"`
+}
+
+func (v ClientVersion) String() string {
+	if v.Version == "" {
+		return v.Name
+	}
+	return v.Name + " " + v.Version
+}
+
+// ParseClientVersion splits a web3_clientVersion string such as
+// "Geth/v1.13.0-stable/linux-amd64/go1.21" into implementation and version
+func ParseClientVersion(raw string) ClientVersion {
+	parts := strings.Split(raw, "/")
+	version := ClientVersion{Name: strings.ToLower(strings.TrimSpace(parts[0])), Raw: raw}
+
+	// Some nodes add an instance name after the implementation, the version is the
+	// first part that looks like one
+	for _, part := range parts[1:] {
+		part = strings.TrimPrefix(part, "v")
+		if part != "" && unicode.IsDigit(rune(part[0])) {
+			version.Version = part
+			break
+		}
+	}
+	return version
+}
+
+// ClientCheck records the node implementation and, when Clients is set, rejects
+// endpoints running other implementations
+type ClientCheck struct {
+	// Clients are the accepted implementation names, case-insensitive
+	Clients []string
+}
+
+func (ClientCheck) Name() string { return "client" }
+
+func (c ClientCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	raw, err := Call(ctx, rpcURL, "web3_clientVersion")
+	if err != nil {
+		if len(c.Clients) == 0 {
+			// Only a filter needs to know the implementation
+			return nil
+		}
+		return fmt.Errorf("web3_clientVersion: %v", err)
+	}
+
+	var versionString string
+	if err := json.Unmarshal(raw, &versionString); err != nil {
+		return fmt.Errorf("web3_clientVersion: invalid result %s", raw)
+	}
+	client := ParseClientVersion(versionString)
+	result.Client = &client
+
+	if len(c.Clients) == 0 {
+		return nil
+	}
+	for _, name := range c.Clients {
+		if strings.EqualFold(name, client.Name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("client %s is not one of %s", client.Name, strings.Join(c.Clients, ", "))
+}
+
+// methodParams are harmless arguments for methods commonly required of endpoints.
+// Other methods are called without arguments, an invalid params error still shows support.
+var methodParams = map[string][]any{
+	"eth_getLogs":            {map[string]string{"fromBlock": "latest", "toBlock": "latest"}},
+	"eth_feeHistory":         {"0x1", "latest", []int{}},
+	"debug_traceTransaction": {"0x0000000000000000000000000000000000000000000000000000000000000000"},
+	"trace_block":            {"latest"},
+	"txpool_status":          {},
+}
+
+// MethodsCheck records which of the JSON-RPC methods the endpoint supports and
+// rejects it when one of them is not, unless the check is Optional. Methods whose
+// support could not be told, e.g. because the call timed out, are not recorded.
+type MethodsCheck struct {
+	Methods []string
+	// Optional only records support, e.g. for scoring method coverage
+	Optional bool
+}
+
+func (MethodsCheck) Name() string { return "methods" }
+
+func (c MethodsCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	if result.Methods == nil {
+		result.Methods = make(map[string]bool, len(c.Methods))
+	}
+
+	for _, method := range c.Methods {
+		err := checkMethod(ctx, rpcURL, method)
+		var unsupported *methodUnsupportedError
+		if err == nil {
+			result.Methods[method] = true
+		} else if errors.As(err, &unsupported) {
+			result.Methods[method] = false
+		}
+		if err != nil && !c.Optional {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkMethod(ctx context.Context, rpcURL, method string) error {
+	_, err := Call(ctx, rpcURL, method, methodParams[method]...)
+	if err == nil {
+		return nil
+	}
+
+	rpcErr, ok := err.(*RPCError)
+	if !ok {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	if isMethodUnsupported(rpcErr) {
+		return &methodUnsupportedError{method: method, message: rpcErr.Message}
+	}
+	// Any other error comes from running the method, e.g. an unknown transaction
+	return nil
+}
+
+// isMethodUnsupported tells a missing or disabled method apart from a failed call.
+// Providers do not agree on a code, so the message is looked at as well.
+func isMethodUnsupported(err *RPCError) bool {
+	if err.Code == -32601 {
+		return true
+	}
+
+	message := strings.ToLower(err.Message)
+	if !strings.Contains(message, "method") && !strings.Contains(message, "namespace") {
+		return false
+	}
+	for _, phrase := range []string{"not found", "not supported", "unsupported", "does not exist", "not available", "not allowed", "disabled", "not whitelisted"} {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchCheck records whether the endpoint answers a JSON-RPC batch and, when
+// Require is set, rejects endpoints that do not
+type BatchCheck struct {
+	Require bool
+}
+
+func (BatchCheck) Name() string { return "batch" }
+
+func (c BatchCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	err := checkBatch(ctx, rpcURL)
+	supported := err == nil
+	result.Batch = &supported
+
+	if err != nil && c.Require {
+		return fmt.Errorf("batch requests are not supported: %v", err)
+	}
+	return nil
+}
+
+// checkBatch sends a 2-item batch and expects both items to be answered
+func checkBatch(ctx context.Context, rpcURL string) error {
+	responses, err := CallBatch(ctx, rpcURL, []RPCRequest{
+		{JSONRPC: "2.0", Method: "eth_chainId", Params: []any{}, ID: 1},
+		{JSONRPC: "2.0", Method: "eth_blockNumber", Params: []any{}, ID: 2},
+	})
+	if err != nil {
+		return err
+	}
+	if len(responses) != 2 {
+		return fmt.Errorf("got %d responses to 2 requests", len(responses))
+	}
+
+	answered := make(map[int]bool, 2)
+	for _, response := range responses {
+		if response.Error != nil {
+			return response.Error
+		}
+		answered[response.ID] = true
+	}
+	if !answered[1] || !answered[2] {
+		return fmt.Errorf("responses do not match the request ids")
+	}
+	return nil
+}
+
+// SubscriptionCheck rejects WebSocket endpoints whose newHeads subscription delivers
+// no notification within the attempt timeout, which has to cover the block time.
+// HTTP endpoints cannot subscribe and are left alone.
+type SubscriptionCheck struct{}
+
+func (SubscriptionCheck) Name() string { return "subscriptions" }
+
+func (SubscriptionCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	if !isWebSocketURL(rpcURL) {
+		return nil
+	}
+	return WaitForNewHead(ctx, rpcURL)
+}
+
+// CORS_ORIGIN is the page origin the CORS preflight is made for
+const CORS_ORIGIN = "https://dapp.example"
+
+// CORSCheck rejects HTTP endpoints that a browser page on another origin cannot call,
+// judged by the preflight a browser sends before a JSON POST. WebSocket endpoints
+// are not subject to CORS and are left alone.
+type CORSCheck struct{}
+
+func (CORSCheck) Name() string { return "cors" }
+
+func (CORSCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	if isWebSocketURL(rpcURL) {
+		return nil
+	}
+	return recordStep(ctx, rpcURL, http.MethodOptions, corsPreflight)
+}
+
+func corsPreflight(ctx context.Context, rpcURL string) error {
+	// Browsers send preflights without credentials, so the request builder headers are left out
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, rpcURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Origin", CORS_ORIGIN)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+
+	resp, err := newHTTPClient(ctx, rpcURL).Do(req)
+	if err != nil {
+		return fmt.Errorf("cors preflight: %v", withoutURL(err))
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cors preflight: HTTP %d", resp.StatusCode)
+	}
+	if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "*" && origin != CORS_ORIGIN {
+		return fmt.Errorf("cors: origin not allowed")
+	}
+	// POST is allowed without being listed, a JSON body needs the content type header listed
+	if methods := resp.Header.Get("Access-Control-Allow-Methods"); methods != "" && !headerListContains(methods, http.MethodPost) {
+		return fmt.Errorf("cors: POST not allowed")
+	}
+	if !headerListContains(resp.Header.Get("Access-Control-Allow-Headers"), "content-type") {
+		return fmt.Errorf("cors: content-type header not allowed")
+	}
+	return nil
+}
+
+// headerListContains looks the value up in a comma-separated header, where * matches anything
+func headerListContains(list, value string) bool {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockCheck records the latest block of the endpoint, for scoring its freshness
+type BlockCheck struct{}
+
+func (BlockCheck) Name() string { return "block" }
+
+func (BlockCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	blockNumber, err := BlockNumber(ctx, rpcURL)
+	if err != nil {
+		return err
+	}
+	result.BlockNumber = blockNumber
+	return nil
+}
+
+// ArchiveCheck rejects endpoints without the state of old blocks, which full nodes
+// prune after some 128 blocks, by asking for a balance at block 1
+type ArchiveCheck struct{}
+
+func (ArchiveCheck) Name() string { return "archive" }
+
+func (ArchiveCheck) Run(ctx context.Context, rpcURL string, result *RPCResult) error {
+	if _, err := Call(ctx, rpcURL, "eth_getBalance", "0x0000000000000000000000000000000000000000", "0x1"); err != nil {
+		return fmt.Errorf("no archive state: %v", err)
+	}
+	return nil
+}
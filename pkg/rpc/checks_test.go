@@ -0,0 +1,29 @@
+package rpc
+
+import "testing"
+
+func TestParseClientVersion(t *testing.T) {
+	tests := []struct {
+		raw     string
+		name    string
+		version string
+	}{
+		{"Geth/v1.13.0-stable/linux-amd64/go1.21", "geth", "1.13.0-stable"},
+		{"Geth/my-node/v1.13.5-stable-916d6a44/linux-amd64/go1.21.4", "geth", "1.13.5-stable-916d6a44"},
+		{"erigon/2.55.1/linux-amd64/go1.21.5", "erigon", "2.55.1"},
+		{"Nethermind/v1.25.4+20b10b35/linux-x64/dotnet8.0.2", "nethermind", "1.25.4+20b10b35"},
+		{"reth/v0.1.0-alpha.13-6aba1d3c/x86_64-unknown-linux-gnu", "reth", "0.1.0-alpha.13-6aba1d3c"},
+		{"besu/v23.10.2/linux-x86_64/openjdk-java-17", "besu", "23.10.2"},
+		{" Bor /v1.2.3", "bor", "1.2.3"},
+		{"anvil/v0.2.0", "anvil", "0.2.0"},
+		{"CustomNode", "customnode", ""},
+		{"custom/node/without/version", "custom", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		version := ParseClientVersion(test.raw)
+		if version.Name != test.name || version.Version != test.version || version.Raw != test.raw {
+			t.Errorf("ParseClientVersion(%q) = %+v, want name %q, version %q", test.raw, version, test.name, test.version)
+		}
+	}
+}
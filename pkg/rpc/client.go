@@ -0,0 +1,266 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+)
+
+// rawResponse is an RPCResponse whose result is left undecoded for the caller
+type rawResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+	ID      int             `json:"id"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Call performs a single JSON-RPC request over HTTP(S) or WebSocket and returns the raw result.
+// The request is bounded by the context deadline.
+func Call(ctx context.Context, rpcURL string, method string, params ...any) (json.RawMessage, error) {
+	if params == nil {
+		params = []any{}
+	}
+	request := RPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      1,
+	}
+
+	// Onion services are only reachable through the Tor proxy
+	if IsOnionURL(rpcURL) && torProxy == nil {
+		return nil, fmt.Errorf("onion endpoint requires a tor proxy")
+	}
+
+	var rpcResp rawResponse
+	if err := call(ctx, rpcURL, request, &rpcResp); err != nil {
+		return nil, err
+	}
+
+	if rpcResp.Error != nil {
+		return nil, rpcResp.Error
+	}
+	return rpcResp.Result, nil
+}
+
+// CallBatch sends the requests as a single JSON-RPC batch and returns the responses
+// as received, which may be in any order. Errors of single requests are not checked.
+func CallBatch(ctx context.Context, rpcURL string, requests []RPCRequest) ([]RPCResponse, error) {
+	if IsOnionURL(rpcURL) && torProxy == nil {
+		return nil, fmt.Errorf("onion endpoint requires a tor proxy")
+	}
+
+	var responses []RPCResponse
+	if err := call(ctx, rpcURL, requests, &responses); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// call sends a request or batch and decodes the response into rpcResp
+func call(ctx context.Context, rpcURL string, payload any, rpcResp any) error {
+	method := "batch"
+	if request, ok := payload.(RPCRequest); ok {
+		method = request.Method
+	}
+
+	return recordCall(ctx, rpcURL, method, payload, rpcResp, func(ctx context.Context) error {
+		if isWebSocketURL(rpcURL) {
+			return callWebSocket(ctx, rpcURL, payload, rpcResp)
+		}
+		return callHTTP(ctx, rpcURL, payload, rpcResp)
+	})
+}
+
+func callHTTP(ctx context.Context, rpcURL string, payload any, rpcResp any) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", rpcURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header = requestHeader(rpcURL)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := newHTTPClient(ctx, rpcURL).Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	observeResponse(ctx, resp)
+
+	if resp.StatusCode != 200 {
+		return &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(rpcResp); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidResponse, err)
+	}
+	// Drain the body so that the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Forward sends a raw JSON-RPC request or batch to an HTTP(S) endpoint, with the headers
+// and transport of the endpoint, and returns the raw response. Statuses other than 200
+// are HTTPStatusErrors.
+func Forward(ctx context.Context, rpcURL string, body []byte) ([]byte, error) {
+	if isWebSocketURL(rpcURL) {
+		return nil, fmt.Errorf("cannot forward to a WebSocket endpoint")
+	}
+	if IsOnionURL(rpcURL) && torProxy == nil {
+		return nil, fmt.Errorf("onion endpoint requires a tor proxy")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	req.Header = requestHeader(rpcURL)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := newHTTPClient(ctx, rpcURL).Do(req)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		io.Copy(io.Discard, resp.Body)
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// withoutURL drops the request URL net/http puts in its errors, as endpoint URLs may
+// carry API keys and errors are reported along with their endpoint anyway
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// newHTTPClient returns a client reaching the endpoint, through the Tor proxy for onion
+// services, otherwise with the transport of the context if any, otherwise with the
+// shared transport
+func newHTTPClient(ctx context.Context, rpcURL string) *http.Client {
+	client := &http.Client{Transport: endpointTransport}
+	if IsOnionURL(rpcURL) {
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(torProxy), TLSClientConfig: tlsConfig}
+	} else if c := connectorFrom(ctx); c != nil {
+		client.Transport = c.transport
+	}
+	return client
+}
+
+func callWebSocket(ctx context.Context, rpcURL string, payload any, rpcResp any) error {
+	conn, err := dialWebSocket(ctx, rpcURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Send JSON-RPC request
+	if err := conn.WriteJSON(payload); err != nil {
+		return err
+	}
+
+	// Read response
+	if err := conn.ReadJSON(rpcResp); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidResponse, err)
+	}
+	return nil
+}
+
+// WaitForNewHead subscribes to newHeads over WebSocket and waits for the first
+// notification, bounded by the context deadline
+func WaitForNewHead(ctx context.Context, rpcURL string) error {
+	if IsOnionURL(rpcURL) && torProxy == nil {
+		return fmt.Errorf("onion endpoint requires a tor proxy")
+	}
+	return recordStep(ctx, rpcURL, "eth_subscribe", waitForNewHead)
+}
+
+func waitForNewHead(ctx context.Context, rpcURL string) error {
+	conn, err := dialWebSocket(ctx, rpcURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	request := RPCRequest{JSONRPC: "2.0", Method: "eth_subscribe", Params: []any{"newHeads"}, ID: 1}
+	if err := conn.WriteJSON(request); err != nil {
+		return err
+	}
+
+	// The subscription id comes first, then the notifications
+	for {
+		var message struct {
+			Method string    `json:"method"`
+			Error  *RPCError `json:"error"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			return fmt.Errorf("no newHeads notification: %v", err)
+		}
+		if message.Error != nil {
+			return fmt.Errorf("eth_subscribe: %v", message.Error)
+		}
+		if message.Method == "eth_subscription" {
+			return nil
+		}
+	}
+}
+
+// dialWebSocket connects to the endpoint, with the handshake and later reads and writes
+// bounded by the context deadline
+func dialWebSocket(ctx context.Context, rpcURL string) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{TLSClientConfig: tlsConfig}
+	if IsOnionURL(rpcURL) {
+		dialer.Proxy = http.ProxyURL(torProxy)
+	} else if c := connectorFrom(ctx); c != nil {
+		dialer.NetDialContext = c.transport.DialContext
+		dialer.Proxy = c.transport.Proxy
+		dialer.TLSClientConfig = c.transport.TLSClientConfig
+	} else {
+		dialer.Proxy = Proxy
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, rpcURL, requestHeader(rpcURL))
+	observeResponse(ctx, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+		conn.SetWriteDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// parseQuantity decodes a hex-encoded JSON-RPC quantity such as "0x1"
+func parseQuantity(result json.RawMessage) (uint64, error) {
+	var hex string
+	if err := json.Unmarshal(result, &hex); err != nil {
+		return 0, fmt.Errorf("invalid quantity %s", result)
+	}
+	return strconv.ParseUint(hex, 0, 64)
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// DialFunc opens the network connections of requests, like net.Dialer.DialContext
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+type connectorKey struct{}
+
+// connector is how the requests of a context reach endpoints: the HTTP transport they
+// share, whose dialer and proxy WebSocket connections use as well
+type connector struct {
+	transport *http.Transport
+}
+
+// WithDialer makes the requests made with the context open their connections with dial,
+// e.g. to reach fake host names served by local listeners in tests. Idle connections
+// are closed once the context is done.
+func WithDialer(ctx context.Context, dial DialFunc) context.Context {
+	transport := NewTransport(DefaultTransportOptions())
+	transport.DialContext = dial
+	// Dialled addresses are chosen by dial, never by a proxy
+	transport.Proxy = nil
+	context.AfterFunc(ctx, transport.CloseIdleConnections)
+	return context.WithValue(ctx, connectorKey{}, &connector{transport: transport})
+}
+
+// WithTransport makes the requests made with the context go through the transport,
+// e.g. one tuned with NewTransport
+func WithTransport(ctx context.Context, transport *http.Transport) context.Context {
+	return context.WithValue(ctx, connectorKey{}, &connector{transport: transport})
+}
+
+func connectorFrom(ctx context.Context) *connector {
+	c, _ := ctx.Value(connectorKey{}).(*connector)
+	return c
+}
+
+// MapHosts returns a DialFunc connecting to the addresses the hosts are mapped to:
+// "host:port" keys map to an address, host name keys to another host on the same
+// port. Other hosts are not dialled at all, so that tests never reach the network.
+func MapHosts(hosts map[string]string) DialFunc {
+	var netDialer net.Dialer
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if target, exists := hosts[address]; exists {
+			return netDialer.DialContext(ctx, network, target)
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if target, exists := hosts[host]; exists {
+			return netDialer.DialContext(ctx, network, net.JoinHostPort(target, port))
+		}
+		return nil, fmt.Errorf("dial %s: host is not mapped", address)
+	}
+}
@@ -0,0 +1,200 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoRPCsFound = fmt.Errorf("all known rpc urls are failing. Try searching for it manually or increase the timeout")
+	// ErrAllEndpointsFailed is ErrNoRPCsFound, the kind of EndpointsFailedError
+	ErrAllEndpointsFailed = ErrNoRPCsFound
+)
+
+// Causes of endpoint failures, by ClassifyError
+const (
+	FAILURE_DNS              = "dns"
+	FAILURE_CONNECTION       = "connection"
+	FAILURE_TLS              = "tls"
+	FAILURE_TIMEOUT          = "timeout"
+	FAILURE_HTTP_STATUS      = "http status"
+	FAILURE_INVALID_RESPONSE = "invalid response"
+	FAILURE_RPC_ERROR        = "rpc error"
+	FAILURE_CHAIN_ID         = "wrong chain id"
+	FAILURE_CHECK            = "check"
+	FAILURE_THROTTLED        = "throttled"
+	FAILURE_SCORE            = "low score"
+	FAILURE_CANCELLED        = "cancelled"
+	FAILURE_OTHER            = "other"
+)
+
+// errInvalidResponse is the kind of errors of responses that are not JSON-RPC
+var errInvalidResponse = errors.New("invalid response")
+
+// errBudgetExhausted is why endpoints still probed when the testing budget ran out failed
+var errBudgetExhausted error = budgetExhaustedError{}
+
+type budgetExhaustedError struct{}
+
+func (budgetExhaustedError) Error() string { return "testing budget exhausted" }
+
+func (budgetExhaustedError) Unwrap() error { return context.DeadlineExceeded }
+
+// HTTPStatusError is returned when an endpoint answers with an HTTP status other than 200
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.StatusCode)
+}
+
+// ChainIDMismatchError is returned when an endpoint serves another chain than expected
+type ChainIDMismatchError struct {
+	ChainID, Expected uint64
+}
+
+func (e *ChainIDMismatchError) Error() string {
+	return fmt.Sprintf("chain id %d does not match %d", e.ChainID, e.Expected)
+}
+
+// checkError is the rejection of an endpoint by a Check
+type checkError struct {
+	err error
+}
+
+func (e *checkError) Error() string { return e.err.Error() }
+
+func (e *checkError) Unwrap() error { return e.err }
+
+// methodUnsupportedError is the answer of an endpoint that lacks or disabled a method
+type methodUnsupportedError struct {
+	method, message string
+}
+
+func (e *methodUnsupportedError) Error() string {
+	return fmt.Sprintf("%s is not supported: %s", e.method, e.message)
+}
+
+// ClassifyError returns the FAILURE_* cause of an endpoint failing testing with err,
+// or "" for no error
+func ClassifyError(err error) string {
+	var (
+		check        *checkError
+		chainID      *ChainIDMismatchError
+		rpcErr       *RPCError
+		status       *HTTPStatusError
+		dnsErr       *net.DNSError
+		verification *tls.CertificateVerificationError
+		record       tls.RecordHeaderError
+		authority    x509.UnknownAuthorityError
+		hostname     x509.HostnameError
+		certificate  x509.CertificateInvalidError
+		netErr       net.Error
+		opErr        *net.OpError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &check):
+		return FAILURE_CHECK
+	case errors.Is(err, errThrottled):
+		return FAILURE_THROTTLED
+	case errors.As(err, &chainID):
+		return FAILURE_CHAIN_ID
+	case errors.As(err, &rpcErr):
+		return FAILURE_RPC_ERROR
+	case errors.As(err, &status):
+		return FAILURE_HTTP_STATUS
+	case errors.Is(err, errInvalidResponse):
+		return FAILURE_INVALID_RESPONSE
+	case errors.As(err, &dnsErr):
+		return FAILURE_DNS
+	case errors.As(err, &verification), errors.As(err, &record), errors.As(err, &authority),
+		errors.As(err, &hostname), errors.As(err, &certificate),
+		// net/http does not export the error of plaintext answers to TLS handshakes
+		strings.Contains(err.Error(), "HTTP response to HTTPS client"):
+		return FAILURE_TLS
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return FAILURE_TIMEOUT
+	case errors.Is(err, context.Canceled):
+		return FAILURE_CANCELLED
+	case errors.As(err, &opErr):
+		return FAILURE_CONNECTION
+	}
+	return FAILURE_OTHER
+}
+
+// EndpointsFailedError is returned when no endpoint passed testing, with why each of
+// them failed. It is an ErrAllEndpointsFailed for errors.Is.
+type EndpointsFailedError struct {
+	// Failures are the results of the endpoints, in the order they were given, with Err set
+	Failures []RPCResult
+}
+
+func (e *EndpointsFailedError) Error() string {
+	return ErrNoRPCsFound.Error()
+}
+
+func (e *EndpointsFailedError) Unwrap() error {
+	return ErrNoRPCsFound
+}
+
+// failureLog records why endpoints failed testing, concurrently from their probes
+type failureLog struct {
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+func newFailureLog() *failureLog {
+	return &failureLog{errs: make(map[string]error)}
+}
+
+func (l *failureLog) record(rpcURL string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs[rpcURL] = err
+}
+
+// result returns the result of an endpoint that did not pass testing. Endpoints without
+// a recorded failure were still being probed when the budget ran out.
+func (l *failureLog) result(rpcURL string) RPCResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.errs[rpcURL]
+	if err == nil {
+		err = errBudgetExhausted
+	}
+	return RPCResult{URL: rpcURL, IsWebSocket: isWebSocketURL(rpcURL), Err: err.Error(), Cause: ClassifyError(err)}
+}
+
+// error returns the EndpointsFailedError of the endpoints, none of which passed testing
+func (l *failureLog) error(rpcURLs []string) error {
+	failures := make([]RPCResult, 0, len(rpcURLs))
+	for _, rpcURL := range rpcURLs {
+		failures = append(failures, l.result(rpcURL))
+	}
+	return &EndpointsFailedError{Failures: failures}
+}
+
+// lowScoreError is why an endpoint that passed testing was dropped by the Scorer
+type lowScoreError struct {
+	minScore int
+}
+
+func errLowScore(minScore int) error {
+	return &lowScoreError{minScore: minScore}
+}
+
+func (e *lowScoreError) Error() string {
+	return fmt.Sprintf("score below %d", e.minScore)
+}
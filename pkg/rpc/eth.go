@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Block is the header of a block with its transaction count
+type Block struct {
+	Number     uint64    `json:"number"`
+	Hash       string    `json:"hash"`
+	ParentHash string    `json:"parentHash"`
+	Timestamp  time.Time `json:"timestamp"`
+	Miner      string    `json:"miner"`
+	GasUsed    uint64    `json:"gasUsed"`
+	GasLimit   uint64    `json:"gasLimit"`
+	// BaseFeePerGas is nil before London and on chains without EIP-1559
+	BaseFeePerGas *big.Int `json:"baseFeePerGas,omitempty"`
+	Transactions  int      `json:"transactions"`
+}
+
+// FeeHistory is the eth_feeHistory of the latest blocks, oldest first
+type FeeHistory struct {
+	OldestBlock uint64 `json:"oldestBlock"`
+	// BaseFeePerGas has one more entry than the blocks: the base fee of the next block
+	BaseFeePerGas []*big.Int `json:"baseFeePerGas"`
+	GasUsedRatio  []float64  `json:"gasUsedRatio"`
+	// Reward holds the priority fees at the requested percentiles, per block
+	Reward [][]*big.Int `json:"reward"`
+}
+
+// BlockNumber returns the latest block number of the endpoint
+func BlockNumber(ctx context.Context, rpcURL string) (uint64, error) {
+	raw, err := Call(ctx, rpcURL, "eth_blockNumber")
+	if err != nil {
+		return 0, fmt.Errorf("eth_blockNumber: %v", err)
+	}
+
+	blockNumber, err := parseQuantity(raw)
+	if err != nil {
+		return 0, fmt.Errorf("eth_blockNumber: %v", err)
+	}
+	return blockNumber, nil
+}
+
+// LatestBlock returns the header of the latest block of the endpoint
+func LatestBlock(ctx context.Context, rpcURL string) (*Block, error) {
+	raw, err := Call(ctx, rpcURL, "eth_getBlockByNumber", "latest", false)
+	if err != nil {
+		return nil, fmt.Errorf("eth_getBlockByNumber: %v", err)
+	}
+
+	var header struct {
+		Number        string            `json:"number"`
+		Hash          string            `json:"hash"`
+		ParentHash    string            `json:"parentHash"`
+		Timestamp     string            `json:"timestamp"`
+		Miner         string            `json:"miner"`
+		GasUsed       string            `json:"gasUsed"`
+		GasLimit      string            `json:"gasLimit"`
+		BaseFeePerGas string            `json:"baseFeePerGas"`
+		Transactions  []json.RawMessage `json:"transactions"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil || header.Number == "" {
+		return nil, fmt.Errorf("eth_getBlockByNumber: invalid block %s", raw)
+	}
+
+	block := &Block{Hash: header.Hash, ParentHash: header.ParentHash, Miner: header.Miner, Transactions: len(header.Transactions)}
+	var timestamp uint64
+	for _, quantity := range []struct {
+		hex   string
+		value *uint64
+	}{{header.Number, &block.Number}, {header.Timestamp, &timestamp}, {header.GasUsed, &block.GasUsed}, {header.GasLimit, &block.GasLimit}} {
+		if *quantity.value, err = parseHexUint(quantity.hex); err != nil {
+			return nil, fmt.Errorf("eth_getBlockByNumber: %v", err)
+		}
+	}
+	block.Timestamp = time.Unix(int64(timestamp), 0).UTC()
+	if header.BaseFeePerGas != "" {
+		if block.BaseFeePerGas, err = parseHexBig(header.BaseFeePerGas); err != nil {
+			return nil, fmt.Errorf("eth_getBlockByNumber: %v", err)
+		}
+	}
+	return block, nil
+}
+
+// GasPrice returns the legacy gas price suggested by the endpoint, in wei
+func GasPrice(ctx context.Context, rpcURL string) (*big.Int, error) {
+	raw, err := Call(ctx, rpcURL, "eth_gasPrice")
+	if err != nil {
+		return nil, fmt.Errorf("eth_gasPrice: %v", err)
+	}
+
+	var hex string
+	if err := json.Unmarshal(raw, &hex); err != nil {
+		return nil, fmt.Errorf("eth_gasPrice: invalid quantity %s", raw)
+	}
+	gasPrice, err := parseHexBig(hex)
+	if err != nil {
+		return nil, fmt.Errorf("eth_gasPrice: %v", err)
+	}
+	return gasPrice, nil
+}
+
+// GetFeeHistory returns the fee history of the latest blocks, with the priority fees
+// paid at the percentiles, from 0 to 100
+func GetFeeHistory(ctx context.Context, rpcURL string, blocks int, percentiles []float64) (*FeeHistory, error) {
+	raw, err := Call(ctx, rpcURL, "eth_feeHistory", fmt.Sprintf("0x%x", blocks), "latest", percentiles)
+	if err != nil {
+		return nil, fmt.Errorf("eth_feeHistory: %v", err)
+	}
+
+	var history struct {
+		OldestBlock   string     `json:"oldestBlock"`
+		BaseFeePerGas []string   `json:"baseFeePerGas"`
+		GasUsedRatio  []float64  `json:"gasUsedRatio"`
+		Reward        [][]string `json:"reward"`
+	}
+	if err := json.Unmarshal(raw, &history); err != nil {
+		return nil, fmt.Errorf("eth_feeHistory: invalid fee history %s", raw)
+	}
+
+	feeHistory := &FeeHistory{GasUsedRatio: history.GasUsedRatio}
+	if feeHistory.OldestBlock, err = parseHexUint(history.OldestBlock); err != nil {
+		return nil, fmt.Errorf("eth_feeHistory: %v", err)
+	}
+	for _, hex := range history.BaseFeePerGas {
+		baseFee, err := parseHexBig(hex)
+		if err != nil {
+			return nil, fmt.Errorf("eth_feeHistory: %v", err)
+		}
+		feeHistory.BaseFeePerGas = append(feeHistory.BaseFeePerGas, baseFee)
+	}
+	for _, blockRewards := range history.Reward {
+		rewards := make([]*big.Int, 0, len(blockRewards))
+		for _, hex := range blockRewards {
+			reward, err := parseHexBig(hex)
+			if err != nil {
+				return nil, fmt.Errorf("eth_feeHistory: %v", err)
+			}
+			rewards = append(rewards, reward)
+		}
+		feeHistory.Reward = append(feeHistory.Reward, rewards)
+	}
+	return feeHistory, nil
+}
+
+func parseHexUint(hex string) (uint64, error) {
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(hex, "0x"), 16)
+	if !strings.HasPrefix(hex, "0x") || !ok || !value.IsUint64() {
+		return 0, fmt.Errorf("invalid quantity %q", hex)
+	}
+	return value.Uint64(), nil
+}
+
+// parseHexBig decodes quantities that may not fit 64 bits, such as wei amounts
+func parseHexBig(hex string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(hex, "0x"), 16)
+	if !strings.HasPrefix(hex, "0x") || !ok {
+		return nil, fmt.Errorf("invalid quantity %q", hex)
+	}
+	return value, nil
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// RequestBuilder adds headers to the requests sent to an endpoint, e.g. the bearer
+// token of an auth gateway. They apply to HTTP requests and WebSocket handshakes alike.
+type RequestBuilder func(rpcURL string, header http.Header)
+
+var requestBuilders []RequestBuilder
+
+// SetRequestBuilders replaces the builders applied, in order, to every request
+func SetRequestBuilders(builders ...RequestBuilder) {
+	requestBuilders = builders
+}
+
+// StaticHeaders adds the headers to the requests of every endpoint
+func StaticHeaders(header http.Header) RequestBuilder {
+	return func(rpcURL string, h http.Header) {
+		addHeaders(h, header)
+	}
+}
+
+// HostHeaders adds the headers to the requests of the endpoints of the host or its
+// subdomains, so that credentials only reach the endpoints they are for
+func HostHeaders(host string, header http.Header) RequestBuilder {
+	host = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), ".")
+	return func(rpcURL string, h http.Header) {
+		u, err := url.Parse(rpcURL)
+		if err != nil {
+			return
+		}
+		hostname := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		if hostname == host || strings.HasSuffix(hostname, "."+host) {
+			addHeaders(h, header)
+		}
+	}
+}
+
+// ParseHeader parses a "Name: value" header
+func ParseHeader(header string) (string, string, error) {
+	name, value, found := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid header %q: expected \"Name: value\"", header)
+	}
+	return textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+func addHeaders(h http.Header, header http.Header) {
+	for name, values := range header {
+		for _, value := range values {
+			h.Add(name, value)
+		}
+	}
+}
+
+// requestHeader returns the headers the builders add to the requests of the endpoint
+func requestHeader(rpcURL string) http.Header {
+	header := http.Header{}
+	for _, build := range requestBuilders {
+		build(rpcURL, header)
+	}
+	return header
+}
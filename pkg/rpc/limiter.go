@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// hostLimiter bounds the number of simultaneous requests to each host name
+type hostLimiter struct {
+	limit int
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, hosts: make(map[string]chan struct{})}
+}
+
+// acquire waits for a free slot of the URL host and returns the function releasing it
+func (l *hostLimiter) acquire(ctx context.Context, rpcURL string) (func(), error) {
+	if l.limit <= 0 {
+		return func() {}, nil
+	}
+
+	slots := l.slots(rpcURL)
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *hostLimiter) slots(rpcURL string) chan struct{} {
+	host := hostName(rpcURL)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, exists := l.hosts[host]
+	if !exists {
+		slots = make(chan struct{}, l.limit)
+		l.hosts[host] = slots
+	}
+	return slots
+}
+
+// hostName returns the lowercase host name of the endpoint, or the URL itself if it has none
+func hostName(rpcURL string) string {
+	if u, err := url.Parse(rpcURL); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return rpcURL
+}
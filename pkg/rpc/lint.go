@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// LintIssue is a problem found in an endpoint URL
+type LintIssue string
+
+const (
+	// LINT_MALFORMED marks URLs that cannot be parsed or use an unsupported scheme
+	LINT_MALFORMED LintIssue = "malformed"
+	// LINT_PLAINTEXT marks unencrypted http:// and ws:// endpoints outside of the local host
+	LINT_PLAINTEXT LintIssue = "plaintext"
+	// LINT_CREDENTIALS marks URLs with embedded user info
+	LINT_CREDENTIALS LintIssue = "credentials"
+	// LINT_PLACEHOLDER marks URLs with an API key template, e.g. ${INFURA_API_KEY}
+	LINT_PLACEHOLDER LintIssue = "placeholder"
+)
+
+// LintIssues lists every issue kind in report order
+var LintIssues = []LintIssue{LINT_MALFORMED, LINT_PLAINTEXT, LINT_CREDENTIALS, LINT_PLACEHOLDER}
+
+var placeholderPattern = regexp.MustCompile(`(?i)\$\{[^}]*\}|\{[a-z_-]*\}|<[^>]*>|your[-_]?(api[-_]?)?key|api[-_]?key[-_]?here`)
+
+// LintURL returns the issues found in the endpoint URL
+func LintURL(rpcURL string) []LintIssue {
+	var issues []LintIssue
+
+	placeholder := placeholderPattern.MatchString(rpcURL)
+	u, err := url.Parse(rpcURL)
+	if err != nil || u.Host == "" || !isSupportedScheme(u.Scheme) {
+		// Templates often fail to parse, report them as what they are
+		if placeholder {
+			return []LintIssue{LINT_PLACEHOLDER}
+		}
+		return []LintIssue{LINT_MALFORMED}
+	}
+
+	if isPlaintext(u) {
+		issues = append(issues, LINT_PLAINTEXT)
+	}
+	if u.User != nil {
+		issues = append(issues, LINT_CREDENTIALS)
+	}
+	if placeholder {
+		issues = append(issues, LINT_PLACEHOLDER)
+	}
+	return issues
+}
+
+// IsBlocking reports whether the issue makes the endpoint unfit for probing
+func (i LintIssue) IsBlocking() bool {
+	return i != LINT_PLAINTEXT
+}
+
+// HasBlockingIssue reports whether the endpoint should be excluded from probing
+func HasBlockingIssue(rpcURL string) bool {
+	for _, issue := range LintURL(rpcURL) {
+		if issue.IsBlocking() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInsecureURL reports whether the endpoint is reached without encryption.
+// Loopback endpoints are not considered insecure as traffic never leaves the host,
+// neither are onion services which Tor encrypts end to end.
+func IsInsecureURL(rpcURL string) bool {
+	u, err := url.Parse(rpcURL)
+	if err != nil {
+		return false
+	}
+	return isPlaintext(u)
+}
+
+func isPlaintext(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "ws" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".onion") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return false
+	}
+	return true
+}
+
+func isSupportedScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "http", "https", "ws", "wss":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// PROVIDER_DOMAINS are the domains of the providers whose API key templates are
+// filled in by SubstituteProviderKeys, by provider name as in INFURA_API_KEY
+var PROVIDER_DOMAINS = map[string][]string{
+	"alchemy":    {"alchemy.com", "alchemyapi.io"},
+	"ankr":       {"ankr.com"},
+	"blast":      {"blastapi.io"},
+	"chainstack": {"chainstack.com"},
+	"drpc":       {"drpc.org"},
+	"getblock":   {"getblock.io"},
+	"infura":     {"infura.io"},
+	"nodereal":   {"nodereal.io"},
+	"onfinality": {"onfinality.io"},
+	"quicknode":  {"quiknode.pro"},
+	"tenderly":   {"tenderly.co"},
+}
+
+// SubstitutePlaceholders replaces the ${NAME} templates of the value with the values
+// lookup finds for their names. It also reports whether the value is free of
+// placeholders afterwards, including ones of other forms like <your-key>.
+func SubstitutePlaceholders(value string, lookup func(name string) (string, bool)) (string, bool) {
+	substituted := variablePattern.ReplaceAllStringFunc(value, func(variable string) string {
+		name := variablePattern.FindStringSubmatch(variable)[1]
+		if value, ok := lookup(name); ok {
+			return value
+		}
+		return variable
+	})
+	return substituted, !placeholderPattern.MatchString(substituted)
+}
+
+// SubstituteProviderKeys replaces the API key templates of the endpoint URL, e.g.
+// ${INFURA_API_KEY}, with the values lookup finds for their names, and reports whether
+// the URL is free of placeholders afterwards. Endpoint URLs come from datasets anyone
+// can edit, so only templates named like a provider key are filled in, and only when
+// the URL host is a domain of that provider: domains, or PROVIDER_DOMAINS when it has
+// none there. A template in the host itself is never filled in.
+func SubstituteProviderKeys(rpcURL string, domains map[string][]string, lookup func(name string) (string, bool)) (string, bool) {
+	host := ""
+	if u, err := url.Parse(rpcURL); err == nil && !variablePattern.MatchString(u.Host) {
+		host = strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	}
+	return SubstitutePlaceholders(rpcURL, func(name string) (string, bool) {
+		provider, ok := PlaceholderProvider(name)
+		if !ok || host == "" {
+			return "", false
+		}
+		providerDomains := domains[provider]
+		if len(providerDomains) == 0 {
+			providerDomains = PROVIDER_DOMAINS[provider]
+		}
+		for _, domain := range providerDomains {
+			domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+			if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+				return lookup(name)
+			}
+		}
+		return "", false
+	})
+}
+
+// PlaceholderProvider returns the provider an API key placeholder name is for, e.g.
+// infura for INFURA_API_KEY. Only names ending in _API_KEY or _PROJECT_ID are keys.
+func PlaceholderProvider(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for _, suffix := range []string{"_api_key", "_project_id"} {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != name && trimmed != "" {
+			return trimmed, true
+		}
+	}
+	return "", false
+}
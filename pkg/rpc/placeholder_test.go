@@ -0,0 +1,65 @@
+package rpc
+
+import "testing"
+
+func TestSubstituteProviderKeys(t *testing.T) {
+	env := map[string]string{
+		"INFURA_API_KEY":        "infura-key",
+		"ACME_PROJECT_ID":       "acme-id",
+		"AWS_SECRET_ACCESS_KEY": "aws-secret",
+		"GITHUB_TOKEN":          "github-token",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	domains := map[string][]string{"acme": {"acme-rpc.io"}}
+
+	tests := []struct {
+		name     string
+		url      string
+		want     string
+		complete bool
+	}{
+		{"provider host", "https://mainnet.infura.io/v3/${INFURA_API_KEY}", "https://mainnet.infura.io/v3/infura-key", true},
+		{"configured domain", "https://eth.acme-rpc.io/${ACME_PROJECT_ID}", "https://eth.acme-rpc.io/acme-id", true},
+		{"unset key", "https://eth-mainnet.g.alchemy.com/v2/${ALCHEMY_API_KEY}", "https://eth-mainnet.g.alchemy.com/v2/${ALCHEMY_API_KEY}", false},
+		{"key on another host", "https://evil.example/rpc/${INFURA_API_KEY}", "https://evil.example/rpc/${INFURA_API_KEY}", false},
+		{"lookalike host", "https://infura.io.evil.example/${INFURA_API_KEY}", "https://infura.io.evil.example/${INFURA_API_KEY}", false},
+		{"suffix lookalike host", "https://notinfura.io/${INFURA_API_KEY}", "https://notinfura.io/${INFURA_API_KEY}", false},
+		{"not a key name", "https://evil.example/rpc/${AWS_SECRET_ACCESS_KEY}", "https://evil.example/rpc/${AWS_SECRET_ACCESS_KEY}", false},
+		{"not a key name on a provider host", "https://mainnet.infura.io/v3/${GITHUB_TOKEN}", "https://mainnet.infura.io/v3/${GITHUB_TOKEN}", false},
+		{"key in the host", "https://${INFURA_API_KEY}.infura.io/v3", "https://${INFURA_API_KEY}.infura.io/v3", false},
+		{"provider host as user info", "https://mainnet.infura.io@evil.example/${INFURA_API_KEY}", "https://mainnet.infura.io@evil.example/${INFURA_API_KEY}", false},
+		{"key in the user info", "https://${INFURA_API_KEY}@evil.example/", "https://${INFURA_API_KEY}@evil.example/", false},
+		{"no template", "https://rpc.ankr.com/eth", "https://rpc.ankr.com/eth", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, complete := SubstituteProviderKeys(test.url, domains, lookup)
+			if got != test.want || complete != test.complete {
+				t.Errorf("SubstituteProviderKeys(%q) = %q, %v, want %q, %v", test.url, got, complete, test.want, test.complete)
+			}
+		})
+	}
+}
+
+func TestPlaceholderProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		ok       bool
+	}{
+		{"INFURA_API_KEY", "infura", true},
+		{"ACME_PROJECT_ID", "acme", true},
+		{"AWS_SECRET_ACCESS_KEY", "", false},
+		{"GITHUB_TOKEN", "", false},
+		{"_API_KEY", "", false},
+	}
+	for _, test := range tests {
+		provider, ok := PlaceholderProvider(test.name)
+		if provider != test.provider || ok != test.ok {
+			t.Errorf("PlaceholderProvider(%q) = %q, %v, want %q, %v", test.name, provider, ok, test.provider, test.ok)
+		}
+	}
+}
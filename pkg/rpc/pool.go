@@ -0,0 +1,234 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// POOL_INTERVAL is the default time between two probes of a pool endpoint
+	POOL_INTERVAL = 30 * time.Second
+	// POOL_RATE is the default number of probes a pool makes per second
+	POOL_RATE = 10
+)
+
+// Pool keeps the endpoints of several chains verified by probing them in the
+// background, so that picking one does not wait for testing
+type Pool struct {
+	// Tester probes the endpoints one by one. Its checks, retries, OnProbe and Trace
+	// apply; its Scorer scores the working endpoints of a chain when picking.
+	Tester *Tester
+	// Interval is the time between two probes of an endpoint
+	Interval time.Duration
+	// Rate bounds the probes made per second over all chains, POOL_RATE when not positive
+	Rate float64
+
+	mu      sync.Mutex
+	chains  map[uint64][]*poolEndpoint
+	changed chan struct{}
+	limiter *hostLimiter
+}
+
+// poolEndpoint is an endpoint of a pool with the outcome of its last probe
+type poolEndpoint struct {
+	url     string
+	result  RPCResult
+	ok      bool
+	checked time.Time
+	probing bool
+}
+
+func NewPool(tester *Tester) *Pool {
+	return &Pool{
+		Tester:   tester,
+		Interval: POOL_INTERVAL,
+		Rate:     POOL_RATE,
+		chains:   make(map[uint64][]*poolEndpoint),
+		changed:  make(chan struct{}),
+	}
+}
+
+// Add adds endpoints to the chain, they are probed first thing
+func (p *Pool) Add(chainID uint64, rpcURLs ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, rpcURL := range rpcURLs {
+		if p.find(chainID, rpcURL) == nil {
+			p.chains[chainID] = append(p.chains[chainID], &poolEndpoint{url: rpcURL})
+		}
+	}
+	p.notify()
+}
+
+// Remove removes a chain and its endpoints from the pool
+func (p *Pool) Remove(chainID uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.chains, chainID)
+	p.notify()
+}
+
+// Start probes the endpoints in the background until the context is cancelled
+func (p *Pool) Start(ctx context.Context) {
+	p.mu.Lock()
+	if p.limiter == nil {
+		p.limiter = newHostLimiter(p.Tester.PerHost)
+	}
+	p.mu.Unlock()
+
+	rate := p.Rate
+	if rate <= 0 {
+		rate = POOL_RATE
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if chainID, endpoint := p.next(); endpoint != nil {
+					go p.probe(chainID, endpoint)
+				}
+			}
+		}
+	}()
+}
+
+// Results returns the working endpoints of the chain as of their last probe
+func (p *Pool) Results(chainID uint64) []RPCResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	results, _ := p.results(chainID)
+	return results
+}
+
+// Probed reports whether the chain is in the pool and each of its endpoints was
+// probed at least once, so that Results lists every working one
+func (p *Pool) Probed(chainID uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.chains[chainID]; !exists {
+		return false
+	}
+	_, pending := p.results(chainID)
+	return !pending
+}
+
+// Pick returns the endpoint picked by the strategy among the working ones of the chain.
+// Until every endpoint of the chain was probed once, it waits for a working one.
+func (p *Pool) Pick(ctx context.Context, chainID uint64, strategy Strategy) (RPCResult, error) {
+	return p.PickExcluding(ctx, chainID, strategy, nil)
+}
+
+// PickExcluding is Pick among the working endpoints not in excluded, e.g. the ones a
+// request already failed on
+func (p *Pool) PickExcluding(ctx context.Context, chainID uint64, strategy Strategy, excluded map[string]bool) (RPCResult, error) {
+	for {
+		p.mu.Lock()
+		_, exists := p.chains[chainID]
+		results, pending := p.results(chainID)
+		changed := p.changed
+		p.mu.Unlock()
+
+		if !exists {
+			return RPCResult{}, fmt.Errorf("chain %d is not in the pool", chainID)
+		}
+		if len(excluded) > 0 {
+			remaining := results[:0]
+			for _, result := range results {
+				if !excluded[result.URL] {
+					remaining = append(remaining, result)
+				}
+			}
+			results = remaining
+		}
+		if results = p.Tester.score(results); len(results) > 0 {
+			return strategy.Pick(results), nil
+		}
+		if !pending {
+			return RPCResult{}, ErrNoRPCsFound
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return RPCResult{}, ctx.Err()
+		}
+	}
+}
+
+// results returns the working endpoints of the chain and whether some were never probed
+func (p *Pool) results(chainID uint64) (results []RPCResult, pending bool) {
+	for _, endpoint := range p.chains[chainID] {
+		if endpoint.ok {
+			results = append(results, endpoint.result)
+		}
+		if endpoint.checked.IsZero() {
+			pending = true
+		}
+	}
+	return results, pending
+}
+
+// next returns the endpoint whose probe is the most overdue, if any is due
+func (p *Pool) next() (uint64, *poolEndpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var dueChainID uint64
+	var due *poolEndpoint
+	now := time.Now()
+	for chainID, endpoints := range p.chains {
+		for _, endpoint := range endpoints {
+			if endpoint.probing || now.Sub(endpoint.checked) < p.Interval {
+				continue
+			}
+			if due == nil || endpoint.checked.Before(due.checked) {
+				dueChainID, due = chainID, endpoint
+			}
+		}
+	}
+	if due != nil {
+		due.probing = true
+	}
+	return dueChainID, due
+}
+
+func (p *Pool) probe(chainID uint64, endpoint *poolEndpoint) {
+	results := p.Tester.findWorkingRPCsConcurrently(context.Background(), []string{endpoint.url}, chainID, p.limiter, nil, nil)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	endpoint.probing = false
+	endpoint.checked = time.Now()
+	endpoint.ok = len(results) > 0
+	if endpoint.ok {
+		endpoint.result = results[0]
+	}
+	p.notify()
+}
+
+func (p *Pool) find(chainID uint64, rpcURL string) *poolEndpoint {
+	for _, endpoint := range p.chains[chainID] {
+		if endpoint.url == rpcURL {
+			return endpoint
+		}
+	}
+	return nil
+}
+
+// notify wakes up the Pick calls waiting for probes
+func (p *Pool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
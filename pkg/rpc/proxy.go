@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var proxyURL *url.URL
+
+// SetProxy routes the requests to endpoints through an HTTP(S) or SOCKS5 proxy, e.g.
+// socks5://127.0.0.1:1080, instead of the proxy of the HTTP_PROXY and HTTPS_PROXY
+// environment variables. Loopback endpoints and the hosts of NO_PROXY are still reached
+// directly. An empty address restores the proxy of the environment.
+func SetProxy(proxyAddr string) error {
+	if proxyAddr == "" {
+		proxyURL = nil
+		updateTransport()
+		return nil
+	}
+
+	if !strings.Contains(proxyAddr, "://") {
+		proxyAddr = "http://" + proxyAddr
+	}
+	u, err := url.Parse(proxyAddr)
+	if err != nil {
+		return fmt.Errorf("invalid proxy address: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("invalid proxy address: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy address: missing host")
+	}
+
+	proxyURL = u
+	updateTransport()
+	return nil
+}
+
+// Proxy returns the proxy to reach the URL of the request through, like
+// http.Transport.Proxy: the one of SetProxy, otherwise the one of the environment
+func Proxy(req *http.Request) (*url.URL, error) {
+	if proxyURL == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	if bypassProxy(req.URL.Hostname()) {
+		return nil, nil
+	}
+	return proxyURL, nil
+}
+
+// bypassProxy reports whether the host is reached directly: loopback hosts and the
+// hosts, domains, IP addresses and networks of NO_PROXY
+func bypassProxy(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		// Ports are ignored, hosts are reached directly on every port
+		if entryHost, _, err := net.SplitHostPort(entry); err == nil {
+			entry = entryHost
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if entry != "" && (host == entry || strings.HasSuffix(host, "."+entry)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Rate limit classifications of an endpoint, from what its probe ran into
+const (
+	// RATE_LIMIT_STRICT means the probe was throttled
+	RATE_LIMIT_STRICT = "strict"
+	// RATE_LIMIT_LENIENT means limits are advertised but the probe stayed within them
+	RATE_LIMIT_LENIENT = "lenient"
+	// RATE_LIMIT_UNKNOWN means there were no signs of rate limiting
+	RATE_LIMIT_UNKNOWN = "unknown"
+)
+
+// errThrottled rejects endpoints that throttled the probe when Tester.ExcludeRateLimited is set
+var errThrottled = errors.New("endpoint throttled the probe")
+
+// rateLimits collects the rate limiting signals of all requests of a probe
+type rateLimits struct {
+	mu         sync.Mutex
+	throttled  bool
+	advertised bool
+}
+
+type responseHookKey struct{}
+
+// withRateLimits makes the requests under ctx report their responses to limits
+func withRateLimits(ctx context.Context, limits *rateLimits) context.Context {
+	return context.WithValue(ctx, responseHookKey{}, limits)
+}
+
+// observeResponse passes an HTTP response, including WebSocket handshakes, to the probe's rate limits
+// and to the exchange being recorded
+func observeResponse(ctx context.Context, resp *http.Response) {
+	observeExchangeResponse(ctx, resp)
+	if limits, ok := ctx.Value(responseHookKey{}).(*rateLimits); ok && resp != nil {
+		limits.observe(resp)
+	}
+}
+
+func (l *rateLimits) observe(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "" {
+		l.throttled = true
+	}
+	for name := range resp.Header {
+		// x-ratelimit-* is the common convention, RateLimit-* the IETF draft
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ratelimit-") || strings.HasPrefix(name, "ratelimit") {
+			l.advertised = true
+		}
+	}
+}
+
+// observeError notes JSON-RPC errors some providers use instead of HTTP 429
+func (l *rateLimits) observeError(err error) {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return
+	}
+
+	message := strings.ToLower(rpcErr.Message)
+	if rpcErr.Code == -32005 || strings.Contains(message, "rate limit") || strings.Contains(message, "too many requests") {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.throttled = true
+	}
+}
+
+func (l *rateLimits) isThrottled() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.throttled
+}
+
+func (l *rateLimits) classification() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case l.throttled:
+		return RATE_LIMIT_STRICT
+	case l.advertised:
+		return RATE_LIMIT_LENIENT
+	default:
+		return RATE_LIMIT_UNKNOWN
+	}
+}
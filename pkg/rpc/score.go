@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Weights of the score components. Components without data for an endpoint are
+// left out and the others scaled up, so scores stay comparable from 0 to 100.
+const (
+	SCORE_WEIGHT_LATENCY     = 30
+	SCORE_WEIGHT_FRESHNESS   = 25
+	SCORE_WEIGHT_RELIABILITY = 20
+	SCORE_WEIGHT_METHODS     = 15
+	SCORE_WEIGHT_TRACKING    = 10
+
+	// MAX_BLOCK_LAG is the lag behind the highest block seen at which freshness scores 0
+	MAX_BLOCK_LAG = 10
+)
+
+// SCORE_METHODS are probed for the method coverage component
+var SCORE_METHODS = []string{"eth_getLogs", "eth_feeHistory", "debug_traceTransaction", "trace_block", "txpool_status"}
+
+// Tracking policies of endpoints as listed by chainlist
+const (
+	TRACKING_NONE    = "none"
+	TRACKING_LIMITED = "limited"
+	TRACKING_YES     = "yes"
+)
+
+// Scorer rates verified endpoints from 0 to 100 out of their latency and block
+// freshness relative to the other endpoints, method coverage, tracking policy
+// and historical reliability. Freshness and coverage need a BlockCheck and a
+// MethodsCheck to have run.
+type Scorer struct {
+	// Tracking maps endpoint URLs to their TRACKING_* policy
+	Tracking map[string]string
+	// Reliability maps endpoint URLs to the share of past probes that passed, from 0 to 1
+	Reliability map[string]float64
+	// MinScore drops endpoints scoring lower
+	MinScore int
+}
+
+// Score sets the score of every result and returns the ones reaching MinScore
+func (s *Scorer) Score(results []RPCResult) []RPCResult {
+	var fastest time.Duration
+	var highestBlock uint64
+	for _, result := range results {
+		if result.Latency > 0 && (fastest == 0 || result.Latency < fastest) {
+			fastest = result.Latency
+		}
+		highestBlock = max(highestBlock, result.BlockNumber)
+	}
+
+	scored := make([]RPCResult, 0, len(results))
+	for _, result := range results {
+		var total, weights float64
+		add := func(weight int, value float64) {
+			total += float64(weight) * value
+			weights += float64(weight)
+		}
+
+		if fastest > 0 && result.Latency > 0 {
+			add(SCORE_WEIGHT_LATENCY, float64(fastest)/float64(result.Latency))
+		}
+		if highestBlock > 0 && result.BlockNumber > 0 {
+			lag := float64(highestBlock - result.BlockNumber)
+			add(SCORE_WEIGHT_FRESHNESS, math.Max(0, 1-lag/MAX_BLOCK_LAG))
+		}
+		if reliability, exists := s.Reliability[result.URL]; exists {
+			add(SCORE_WEIGHT_RELIABILITY, reliability)
+		}
+		if len(result.Methods) > 0 {
+			supported := 0
+			for _, ok := range result.Methods {
+				if ok {
+					supported++
+				}
+			}
+			add(SCORE_WEIGHT_METHODS, float64(supported)/float64(len(result.Methods)))
+		}
+		add(SCORE_WEIGHT_TRACKING, trackingScore(s.Tracking[result.URL]))
+
+		result.Score = int(math.Round(100 * total / weights))
+		if result.Score >= s.MinScore {
+			scored = append(scored, result)
+		}
+	}
+	return scored
+}
+
+// trackingScore prefers endpoints that do not track users, unknown policies count as limited
+func trackingScore(tracking string) float64 {
+	switch tracking {
+	case TRACKING_NONE:
+		return 1
+	case TRACKING_YES:
+		return 0
+	default:
+		return 0.5
+	}
+}
+
+// SortByScore orders results from the highest score, ties by latency
+func SortByScore(results []RPCResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Latency < results[j].Latency
+	})
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScorerWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		scorer  Scorer
+		results []RPCResult
+		scores  []int
+	}{
+		{
+			name:    "tracking only",
+			scorer:  Scorer{Tracking: map[string]string{"a": TRACKING_NONE, "b": TRACKING_YES}},
+			results: []RPCResult{{URL: "a"}, {URL: "b"}, {URL: "c"}},
+			scores:  []int{100, 0, 50},
+		},
+		{
+			// Latency 30 of 40: the fastest gets it in full, twice as slow gets half
+			name:    "latency relative to the fastest",
+			scorer:  Scorer{Tracking: map[string]string{"a": TRACKING_NONE, "b": TRACKING_NONE}},
+			results: []RPCResult{{URL: "a", Latency: 100 * time.Millisecond}, {URL: "b", Latency: 200 * time.Millisecond}},
+			scores:  []int{100, 63},
+		},
+		{
+			// Freshness 25 of 35: 5 blocks behind is half of MAX_BLOCK_LAG
+			name:    "freshness relative to the highest block",
+			scorer:  Scorer{Tracking: map[string]string{"a": TRACKING_YES, "b": TRACKING_YES, "c": TRACKING_YES}},
+			results: []RPCResult{{URL: "a", BlockNumber: 100}, {URL: "b", BlockNumber: 95}, {URL: "c", BlockNumber: 80}},
+			scores:  []int{71, 36, 0},
+		},
+		{
+			// Reliability 16 of 20, methods 9 of 15 and tracking 5 of 10
+			name:   "reliability and methods",
+			scorer: Scorer{Reliability: map[string]float64{"a": 0.8}},
+			results: []RPCResult{{URL: "a", Methods: map[string]bool{
+				"eth_getLogs": true, "eth_feeHistory": true, "txpool_status": true, "trace_block": false, "debug_traceTransaction": false,
+			}}},
+			scores: []int{67},
+		},
+		{
+			name: "every component",
+			scorer: Scorer{
+				Tracking:    map[string]string{"a": TRACKING_NONE},
+				Reliability: map[string]float64{"a": 1},
+			},
+			results: []RPCResult{{URL: "a", Latency: time.Millisecond, BlockNumber: 10, Methods: map[string]bool{"eth_getLogs": true}}},
+			scores:  []int{100},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scored := test.scorer.Score(test.results)
+			if len(scored) != len(test.scores) {
+				t.Fatalf("got %d results, want %d", len(scored), len(test.scores))
+			}
+			for i, result := range scored {
+				if result.Score != test.scores[i] {
+					t.Errorf("score of %s = %d, want %d", result.URL, result.Score, test.scores[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScorerMinScore(t *testing.T) {
+	scorer := Scorer{Tracking: map[string]string{"a": TRACKING_NONE, "b": TRACKING_YES}, MinScore: 50}
+	scored := scorer.Score([]RPCResult{{URL: "a"}, {URL: "b"}, {URL: "c"}})
+
+	var kept []string
+	for _, result := range scored {
+		kept = append(kept, result.URL)
+	}
+	if len(kept) != 2 || kept[0] != "a" || kept[1] != "c" {
+		t.Errorf("kept %v, want [a c]", kept)
+	}
+}
@@ -0,0 +1,349 @@
+package rpc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Strategy picks the endpoint to use out of the verified ones
+type Strategy interface {
+	Name() string
+	// Pick chooses one of the results, given in the order they were verified
+	Pick(results []RPCResult) RPCResult
+}
+
+// RandomStrategy spreads load by picking any verified endpoint
+type RandomStrategy struct{}
+
+func (RandomStrategy) Name() string { return "random" }
+
+func (RandomStrategy) Pick(results []RPCResult) RPCResult {
+	return results[rand.Intn(len(results))]
+}
+
+// FastestStrategy picks the endpoint with the lowest probe latency
+type FastestStrategy struct{}
+
+func (FastestStrategy) Name() string { return "fastest" }
+
+func (FastestStrategy) Pick(results []RPCResult) RPCResult {
+	fastest := results[0]
+	for _, result := range results[1:] {
+		if result.Latency < fastest.Latency {
+			fastest = result
+		}
+	}
+	return fastest
+}
+
+// EarlyStrategy is a Strategy that can tell what it picks before every endpoint is
+// tested. Selectors stop testing and cancel outstanding probes as soon as it can, so
+// that an endpoint is found in the time the fastest ones take rather than the timeout.
+type EarlyStrategy interface {
+	Strategy
+	// Decided tells whether Pick picks out of the results verified so far, given in the
+	// order they were verified, the endpoint it would pick once all are tested
+	Decided(results []RPCResult) bool
+}
+
+// FirstStrategy picks the first endpoint to be verified, so testing stops there
+type FirstStrategy struct{}
+
+func (FirstStrategy) Name() string { return "first" }
+
+func (FirstStrategy) Pick(results []RPCResult) RPCResult {
+	return results[0]
+}
+
+func (FirstStrategy) Decided(results []RPCResult) bool {
+	return len(results) > 0
+}
+
+// ScoreStrategy picks the endpoint with the highest score, needs a Scorer
+type ScoreStrategy struct{}
+
+func (ScoreStrategy) Name() string { return "score" }
+
+func (ScoreStrategy) Pick(results []RPCResult) RPCResult {
+	best := results[0]
+	for _, result := range results[1:] {
+		if result.Score > best.Score || (result.Score == best.Score && result.Latency < best.Latency) {
+			best = result
+		}
+	}
+	return best
+}
+
+// WeightedStrategy spreads load like RandomStrategy, favouring endpoints by score
+type WeightedStrategy struct{}
+
+func (WeightedStrategy) Name() string { return "weighted" }
+
+func (WeightedStrategy) Pick(results []RPCResult) RPCResult {
+	total := 0
+	for _, result := range results {
+		total += result.Score
+	}
+	if total == 0 {
+		return RandomStrategy{}.Pick(results)
+	}
+
+	n := rand.Intn(total)
+	for _, result := range results {
+		if n < result.Score {
+			return result
+		}
+		n -= result.Score
+	}
+	return results[len(results)-1]
+}
+
+// PreferStrategy picks with Strategy among the results whose URL is preferred, or
+// among all of them when none is
+type PreferStrategy struct {
+	Strategy  Strategy
+	Preferred func(rpcURL string) bool
+}
+
+func (s PreferStrategy) Name() string { return s.Strategy.Name() }
+
+func (s PreferStrategy) Pick(results []RPCResult) RPCResult {
+	preferred := s.preferred(results)
+	if len(preferred) == 0 {
+		return s.Strategy.Pick(results)
+	}
+	return s.Strategy.Pick(preferred)
+}
+
+// Decided tells whether the strategy can pick among the preferred endpoints verified so
+// far. Until one is, any of the others may be overtaken by a preferred one.
+func (s PreferStrategy) Decided(results []RPCResult) bool {
+	early, ok := s.Strategy.(EarlyStrategy)
+	if !ok {
+		return false
+	}
+	preferred := s.preferred(results)
+	return len(preferred) > 0 && early.Decided(preferred)
+}
+
+func (s PreferStrategy) preferred(results []RPCResult) []RPCResult {
+	var preferred []RPCResult
+	for _, result := range results {
+		if s.Preferred(result.URL) {
+			preferred = append(preferred, result)
+		}
+	}
+	return preferred
+}
+
+// TierStrategy picks among the results of the first tier, the lowest, so that the
+// endpoints of later tiers only take over when all of the first fail. Within a tier,
+// it picks at random in proportion to the weights of the endpoints, or with Strategy
+// when they weigh the same.
+type TierStrategy struct {
+	Strategy Strategy
+	Tier     func(rpcURL string) int
+	// Weight, when set, returns the share of requests of the endpoint
+	Weight func(rpcURL string) float64
+}
+
+func (s TierStrategy) Name() string { return s.Strategy.Name() }
+
+func (s TierStrategy) Pick(results []RPCResult) RPCResult {
+	var tier []RPCResult
+	best := 0
+	for _, result := range results {
+		rank := s.Tier(result.URL)
+		if len(tier) == 0 || rank < best {
+			tier, best = nil, rank
+		}
+		if rank == best {
+			tier = append(tier, result)
+		}
+	}
+	if s.Weight == nil {
+		return s.Strategy.Pick(tier)
+	}
+
+	weights := make([]float64, len(tier))
+	total := 0.0
+	uniform := true
+	for i, result := range tier {
+		weights[i] = math.Max(s.Weight(result.URL), 0)
+		total += weights[i]
+		uniform = uniform && weights[i] == weights[0]
+	}
+	if uniform || total == 0 {
+		return s.Strategy.Pick(tier)
+	}
+	n := rand.Float64() * total
+	for i, result := range tier {
+		if n < weights[i] {
+			return result
+		}
+		n -= weights[i]
+	}
+	return tier[len(tier)-1]
+}
+
+// UnwrapStrategy returns the strategy a PreferStrategy or TierStrategy picks with, or
+// the strategy itself
+func UnwrapStrategy(strategy Strategy) Strategy {
+	switch wrapper := strategy.(type) {
+	case PreferStrategy:
+		return UnwrapStrategy(wrapper.Strategy)
+	case TierStrategy:
+		return UnwrapStrategy(wrapper.Strategy)
+	}
+	return strategy
+}
+
+// UsesScores tells whether the strategy picks by score, so that endpoints need scoring
+func UsesScores(strategy Strategy) bool {
+	switch UnwrapStrategy(strategy).(type) {
+	case ScoreStrategy, WeightedStrategy:
+		return true
+	}
+	return false
+}
+
+var (
+	strategiesMux sync.RWMutex
+	strategies    = map[string]Strategy{}
+)
+
+func init() {
+	RegisterStrategy(RandomStrategy{})
+	RegisterStrategy(FastestStrategy{})
+	RegisterStrategy(FirstStrategy{})
+	RegisterStrategy(ScoreStrategy{})
+	RegisterStrategy(WeightedStrategy{})
+}
+
+// RegisterStrategy makes a strategy selectable by name, replacing one of the same name
+func RegisterStrategy(strategy Strategy) {
+	strategiesMux.Lock()
+	defer strategiesMux.Unlock()
+	strategies[strategy.Name()] = strategy
+}
+
+// StrategyNames returns the names of the registered strategies in alphabetical order
+func StrategyNames() []string {
+	strategiesMux.RLock()
+	defer strategiesMux.RUnlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StrategyByName returns a registered strategy
+func StrategyByName(name string) (Strategy, error) {
+	strategiesMux.RLock()
+	defer strategiesMux.RUnlock()
+
+	strategy, exists := strategies[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown strategy '%s'", name)
+	}
+	return strategy, nil
+}
+
+// Selector tests endpoints and picks one of the working ones with its strategy
+type Selector struct {
+	Tester   *Tester
+	Strategy Strategy
+}
+
+func NewSelector(tester *Tester, strategy Strategy) *Selector {
+	return &Selector{Tester: tester, Strategy: strategy}
+}
+
+// Select returns the endpoint picked by the strategy among the working ones
+func (s *Selector) Select(rpcURLs []string, expectedChainID uint64) (string, error) {
+	result, err := s.SelectResult(rpcURLs, expectedChainID)
+	if err != nil {
+		return "", err
+	}
+	return result.URL, nil
+}
+
+// SelectResult is Select returning what testing found out about the picked endpoint
+func (s *Selector) SelectResult(rpcURLs []string, expectedChainID uint64) (RPCResult, error) {
+	failures := newFailureLog()
+	results := s.Tester.testRPCs(rpcURLs, expectedChainID, s.decided(), failures)
+	if len(results) == 0 {
+		return RPCResult{}, failures.error(rpcURLs)
+	}
+	return s.Strategy.Pick(results), nil
+}
+
+// SelectChains picks an endpoint for each of the chains, testing them all at once.
+// Chains without a working endpoint are left out.
+func (s *Selector) SelectChains(chains []ChainURLs) map[uint64]RPCResult {
+	picked := make(map[uint64]RPCResult, len(chains))
+	for chainID, results := range s.Tester.testChains(chains, s.decided()) {
+		if len(results) > 0 {
+			picked[chainID] = s.Strategy.Pick(results)
+		}
+	}
+	return picked
+}
+
+// SelectPair returns an HTTP and a WebSocket endpoint picked by PickPair among the
+// working ones. Every endpoint is tested, whatever the strategy.
+func (s *Selector) SelectPair(rpcURLs []string, expectedChainID uint64) (httpResult, wsResult RPCResult, err error) {
+	failures := newFailureLog()
+	results := s.Tester.testRPCs(rpcURLs, expectedChainID, nil, failures)
+	if len(results) == 0 {
+		return RPCResult{}, RPCResult{}, failures.error(rpcURLs)
+	}
+	httpResult, wsResult = PickPair(s.Strategy, results)
+	return httpResult, wsResult, nil
+}
+
+// PickPair picks an HTTP and a WebSocket endpoint out of the results. The HTTP one
+// is picked by the strategy, the WebSocket one preferably on the same host, as
+// providers usually serve both; otherwise by the strategy too. A result is left
+// zero when there is no endpoint of its kind.
+func PickPair(strategy Strategy, results []RPCResult) (httpResult, wsResult RPCResult) {
+	var httpResults, wsResults []RPCResult
+	for _, result := range results {
+		if isWebSocketURL(result.URL) {
+			wsResults = append(wsResults, result)
+		} else {
+			httpResults = append(httpResults, result)
+		}
+	}
+
+	if len(httpResults) > 0 {
+		httpResult = strategy.Pick(httpResults)
+		var sameHost []RPCResult
+		for _, result := range wsResults {
+			if hostName(result.URL) == hostName(httpResult.URL) {
+				sameHost = append(sameHost, result)
+			}
+		}
+		if len(sameHost) > 0 {
+			wsResults = sameHost
+		}
+	}
+	if len(wsResults) > 0 {
+		wsResult = strategy.Pick(wsResults)
+	}
+	return httpResult, wsResult
+}
+
+// decided returns when testing can stop for the strategy to pick, nil to test all endpoints
+func (s *Selector) decided() func([]RPCResult) bool {
+	if early, ok := s.Strategy.(EarlyStrategy); ok {
+		return early.Decided
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTierStrategy(t *testing.T) {
+	results := []RPCResult{
+		{URL: "https://public.example", Latency: 10 * time.Millisecond},
+		{URL: "https://paid.example", Latency: 30 * time.Millisecond},
+		{URL: "http://my-node.internal", Latency: 50 * time.Millisecond},
+		{URL: "http://my-node-2.internal", Latency: 40 * time.Millisecond},
+	}
+	tierOf := func(rpcURL string) int {
+		switch {
+		case strings.Contains(rpcURL, "my-node"):
+			return 0
+		case strings.Contains(rpcURL, "paid"):
+			return 1
+		}
+		return 2
+	}
+
+	tests := []struct {
+		name    string
+		results []RPCResult
+		weight  func(string) float64
+		want    string
+	}{
+		{name: "first tier", results: results, want: "http://my-node-2.internal"},
+		{name: "later tier when the first fails", results: results[:2], want: "https://paid.example"},
+		{name: "same weights", results: results, weight: func(string) float64 { return 2 }, want: "http://my-node-2.internal"},
+		{name: "weights", results: results, weight: func(rpcURL string) float64 {
+			if rpcURL == "http://my-node.internal" {
+				return 1
+			}
+			return 0
+		}, want: "http://my-node.internal"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strategy := TierStrategy{Strategy: FastestStrategy{}, Tier: tierOf, Weight: test.weight}
+			if got := strategy.Pick(test.results); got.URL != test.want {
+				t.Errorf("picked %s, want %s", got.URL, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Session records the exchanges of probes with the endpoints, or replays recorded
+// ones instead of reaching the endpoints, to analyze a test run offline
+type Session struct {
+	ChainID   uint64     `json:"chainId"`
+	StartedAt time.Time  `json:"startedAt"`
+	Exchanges []Exchange `json:"exchanges"`
+	// RedactURL, when set, rewrites the endpoint URLs of the exchanges when the session is
+	// saved and when replaying, e.g. to keep API keys out of session files meant to be
+	// shared. Replays then find the exchanges of endpoints by their redacted URL.
+	RedactURL func(string) string `json:"-"`
+
+	mu        sync.Mutex
+	replaying bool
+	// next is the index of the next exchange to replay per endpoint and method
+	next map[string]int
+}
+
+// Exchange is a request to an endpoint with what came back
+type Exchange struct {
+	URL string `json:"url"`
+	// Method is the JSON-RPC method, "batch" for batches, or a probe step that is not
+	// a JSON-RPC call: "eth_subscribe" for subscriptions and "OPTIONS" for CORS preflights
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// Status and Header are the HTTP response status and headers, if any
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	// Error is why the exchange failed below JSON-RPC, e.g. a timeout
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// NewSession starts recording the probes of a chain
+func NewSession(chainID uint64) *Session {
+	return &Session{ChainID: chainID, StartedAt: time.Now(), Exchanges: []Exchange{}}
+}
+
+// LoadSession reads a recorded session for replaying
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %v", err)
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %v", path, err)
+	}
+	session.replaying = true
+	session.next = make(map[string]int)
+	return session, nil
+}
+
+// Save writes the recorded session, with redacted endpoint URLs and without the
+// cookies endpoints set. Request headers are not recorded.
+func (s *Session) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := Session{ChainID: s.ChainID, StartedAt: s.StartedAt, Exchanges: make([]Exchange, 0, len(s.Exchanges))}
+	for _, exchange := range s.Exchanges {
+		exchange.URL = s.redact(exchange.URL)
+		if exchange.Header != nil {
+			exchange.Header = exchange.Header.Clone()
+			exchange.Header.Del("Set-Cookie")
+		}
+		saved.Exchanges = append(saved.Exchanges, exchange)
+	}
+
+	data, err := json.MarshalIndent(&saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize session: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session: %v", err)
+	}
+	return nil
+}
+
+// URLs returns the recorded endpoints in the order they were first reached
+func (s *Session) URLs() []string {
+	seen := make(map[string]bool)
+	urls := make([]string, 0)
+	for _, exchange := range s.Exchanges {
+		if !seen[exchange.URL] {
+			seen[exchange.URL] = true
+			urls = append(urls, exchange.URL)
+		}
+	}
+	return urls
+}
+
+func (s *Session) redact(rpcURL string) string {
+	if s.RedactURL == nil {
+		return rpcURL
+	}
+	return s.RedactURL(rpcURL)
+}
+
+type sessionKey struct{}
+type exchangeKey struct{}
+
+func withSession(ctx context.Context, session *Session) context.Context {
+	if session == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionFrom(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// observeExchangeResponse notes the HTTP status and headers of the exchange being recorded
+func observeExchangeResponse(ctx context.Context, resp *http.Response) {
+	if exchange, ok := ctx.Value(exchangeKey{}).(*Exchange); ok && resp != nil {
+		exchange.Status = resp.StatusCode
+		exchange.Header = resp.Header.Clone()
+	}
+}
+
+// recordCall makes a JSON-RPC call through send, recording it, or replays it in a replaying session
+func recordCall(ctx context.Context, rpcURL, method string, payload any, rpcResp any, send func(context.Context) error) error {
+	session := sessionFrom(ctx)
+	if session == nil {
+		return send(ctx)
+	}
+	if session.replaying {
+		return session.replay(ctx, rpcURL, method, rpcResp)
+	}
+
+	exchange := &Exchange{URL: rpcURL, Method: method, Time: time.Now()}
+	exchange.Request, _ = json.Marshal(payload)
+	err := send(context.WithValue(ctx, exchangeKey{}, exchange))
+	exchange.DurationMs = time.Since(exchange.Time).Milliseconds()
+	if err != nil {
+		exchange.Error = err.Error()
+	} else {
+		exchange.Response, _ = json.Marshal(rpcResp)
+	}
+
+	session.add(*exchange)
+	return err
+}
+
+// recordStep runs a probe step that is not a JSON-RPC call, recording its outcome,
+// or replays the outcome in a replaying session
+func recordStep(ctx context.Context, rpcURL, method string, step func(context.Context, string) error) error {
+	return recordCall(ctx, rpcURL, method, nil, nil, func(ctx context.Context) error {
+		return step(ctx, rpcURL)
+	})
+}
+
+func (s *Session) add(exchange Exchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Exchanges = append(s.Exchanges, exchange)
+}
+
+// replay answers with the next recorded exchange of the endpoint and method, taking
+// as long as the recorded one did
+func (s *Session) replay(ctx context.Context, rpcURL, method string, rpcResp any) error {
+	exchange, err := s.nextExchange(rpcURL, method)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-time.After(time.Duration(exchange.DurationMs) * time.Millisecond):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if exchange.Status != 0 {
+		observeResponse(ctx, &http.Response{StatusCode: exchange.Status, Header: exchange.Header})
+	}
+	if exchange.Error != "" {
+		return errors.New(exchange.Error)
+	}
+	if rpcResp != nil && len(exchange.Response) > 0 {
+		if err := json.Unmarshal(exchange.Response, rpcResp); err != nil {
+			return fmt.Errorf("invalid recorded response: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *Session) nextExchange(rpcURL, method string) (Exchange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rpcURL = s.redact(rpcURL)
+	key := rpcURL + " " + method
+	for i := s.next[key]; i < len(s.Exchanges); i++ {
+		if s.Exchanges[i].URL == rpcURL && s.Exchanges[i].Method == method {
+			s.next[key] = i + 1
+			return s.Exchanges[i], nil
+		}
+	}
+	return Exchange{}, fmt.Errorf("%s was not recorded", method)
+}
@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const DEFAULT_TEST_TIMEOUT = 5 * time.Second
+
+// TestOptions configure TestEndpoints
+type TestOptions struct {
+	// ChainID is the chain the endpoints must serve
+	ChainID uint64
+	// Tester probes the endpoints with its settings, by default a NewTester with
+	// DEFAULT_TEST_TIMEOUT. Its Scorer is not applied to streamed endpoints.
+	Tester *Tester
+}
+
+// TestEndpoints tests the endpoints in the background and sends every one that passes
+// testing on the returned channel as soon as it does, for callers to pick the first
+// one or to select among them their own way. The channel is closed once testing is
+// over: every endpoint was tested, the tester's Budget ran out or Target was reached,
+// or ctx is done. Cancel ctx to stop testing early, e.g. after the first endpoint.
+func TestEndpoints(ctx context.Context, rpcURLs []string, options TestOptions) (<-chan RPCResult, error) {
+	if options.ChainID == 0 {
+		return nil, fmt.Errorf("missing chain ID")
+	}
+	if len(rpcURLs) == 0 {
+		return nil, fmt.Errorf("no endpoints to test")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tester := options.Tester
+	if tester == nil {
+		tester = NewTester(DEFAULT_TEST_TIMEOUT)
+	}
+
+	// Every endpoint fits, testing never waits for the receiver
+	results := make(chan RPCResult, len(rpcURLs))
+	go func() {
+		defer close(results)
+		tester.findWorkingRPCsConcurrently(ctx, rpcURLs, options.ChainID, newHostLimiter(tester.PerHost), func(result RPCResult) bool {
+			results <- result
+			return true
+		}, nil)
+	}()
+	return results, nil
+}
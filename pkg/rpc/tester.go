@@ -1,19 +1,15 @@
 package rpc
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"net/url"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 type RPCRequest struct {
@@ -35,51 +31,344 @@ type RPCError struct {
 	Message string `json:"message"`
 }
 
-var (
-	ErrNoRPCsFound = fmt.Errorf("all known rpc urls are failing. Try searching for it manually or increase the timeout")
+var torProxy *url.URL
+
+// SetTorProxy configures the SOCKS5 proxy used to reach .onion endpoints.
+// An empty address disables onion probing.
+func SetTorProxy(proxyAddr string) error {
+	if proxyAddr == "" {
+		torProxy = nil
+		return nil
+	}
+
+	if !strings.Contains(proxyAddr, "://") {
+		proxyAddr = "socks5://" + proxyAddr
+	}
+
+	u, err := url.Parse(proxyAddr)
+	if err != nil {
+		return fmt.Errorf("invalid tor proxy address: %v", err)
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return fmt.Errorf("invalid tor proxy address: unsupported scheme %q", u.Scheme)
+	}
+
+	torProxy = u
+	return nil
+}
+
+// IsOnionURL reports whether the URL points to a Tor onion service
+func IsOnionURL(rpcURL string) bool {
+	u, err := url.Parse(rpcURL)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(u.Hostname()), ".onion")
+}
+
+const (
+	RETRY_BASE_DELAY = 100 * time.Millisecond
+	RETRY_MAX_DELAY  = 2 * time.Second
+
+	// DEFAULT_PER_HOST_PROBES keeps the fan-out below typical per-IP rate limits of providers
+	DEFAULT_PER_HOST_PROBES = 2
 )
 
+// Tester probes RPC endpoints with a shared set of settings
+type Tester struct {
+	// Timeout bounds a single probe attempt
+	Timeout time.Duration
+	// Retries is the number of extra attempts made before an endpoint is declared dead
+	Retries int
+	// Budget bounds the whole test run, by default it is long enough for every attempt
+	Budget time.Duration
+	// Target stops testing once this many endpoints are verified, 0 tests all of them
+	Target int
+	// PerHost limits simultaneous probes to the same host name, 0 means no limit
+	PerHost int
+	// WarmUp makes a throwaway request before the measured one, so that connection
+	// setup (DNS, TCP, TLS) does not count towards the latency
+	WarmUp bool
+	// Checks run in order once an endpoint served the expected chain ID
+	Checks []Check
+	// ExcludeRateLimited rejects endpoints that throttled the probe, even if a retry passed
+	ExcludeRateLimited bool
+	// Scorer, when set, scores the endpoints once testing is over and drops low scoring ones.
+	// Streamed endpoints are not scored.
+	Scorer *Scorer
+	// Trace, when set, is called concurrently with every probe lifecycle event
+	Trace func(ProbeEvent)
+	// OnProbe, when set, is called concurrently with the outcome of every probe that
+	// was not cancelled, e.g. to keep a history of endpoint reliability
+	OnProbe func(ProbeOutcome)
+	// Session, when set, records every exchange of the probes, or answers them from
+	// the recording when loaded with LoadSession
+	Session *Session
+	// Dial, when set, opens the connections of the probes instead of the system
+	// resolver and dialer, e.g. MapHosts for hermetic tests
+	Dial DialFunc
+	// Transport, when set, carries the HTTP requests of the probes instead of the
+	// transport shared by the package, e.g. one tuned with NewTransport
+	Transport *http.Transport
+}
+
+// ProbeOutcome is the conclusion of probing an endpoint, after any retries
+type ProbeOutcome struct {
+	ChainID uint64
+	URL     string
+	Time    time.Time
+	// Latency is the eth_chainId round trip of the successful attempt
+	Latency time.Duration
+	OK      bool
+}
+
+// RPCResult is the outcome of an endpoint test, a successful one unless Err is set
+type RPCResult struct {
+	URL string `json:"url"`
+	// IsWebSocket tells whether the endpoint is reached over WebSocket
+	IsWebSocket bool `json:"webSocket,omitempty"`
+	// Latency is the eth_chainId round trip of the successful probe attempt
+	Latency time.Duration `json:"latency"`
+	// Client is the node implementation, when a ClientCheck ran
+	Client *ClientVersion `json:"client,omitempty"`
+	// Batch tells whether JSON-RPC batches work, when a BatchCheck ran
+	Batch *bool `json:"batch,omitempty"`
+	// RateLimit is one of the RATE_LIMIT_* classifications
+	RateLimit string `json:"rateLimit,omitempty"`
+	// BlockNumber is the latest block of the endpoint, when a BlockCheck ran
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	// Methods tells which methods are supported, when a MethodsCheck ran
+	Methods map[string]bool `json:"methods,omitempty"`
+	// Score rates the endpoint from 0 to 100 when the tester has a Scorer, it is 0 otherwise
+	Score int `json:"score"`
+	// Err is why the endpoint failed testing, only set by CheckRPCs and in
+	// EndpointsFailedError
+	Err string `json:"error,omitempty"`
+	// Cause classifies Err, one of the FAILURE_* causes
+	Cause string `json:"cause,omitempty"`
+}
+
+func NewTester(timeout time.Duration) *Tester {
+	return &Tester{Timeout: timeout, PerHost: DEFAULT_PER_HOST_PROBES}
+}
+
 func FindAllWorkingRPCs(rpcURLs []string, expectedChainID uint64, timeout time.Duration) ([]string, error) {
-	workingRPCs := findWorkingRPCsConcurrently(rpcURLs, expectedChainID, timeout)
-	if len(workingRPCs) == 0 {
-		return nil, ErrNoRPCsFound
-	}
-	return workingRPCs, nil
+	return NewTester(timeout).FindAllWorkingRPCs(rpcURLs, expectedChainID)
 }
 
 func FindRandomWorkingRPC(rpcURLs []string, expectedChainID uint64, timeout time.Duration) (string, error) {
-	workingRPCs := findWorkingRPCsConcurrently(rpcURLs, expectedChainID, timeout)
-	if len(workingRPCs) == 0 {
-		return "", ErrNoRPCsFound
+	return NewTester(timeout).FindRandomWorkingRPC(rpcURLs, expectedChainID)
+}
+
+func FindFirstWorkingRPC(rpcURLs []string, expectedChainID uint64, timeout time.Duration) (string, error) {
+	return NewTester(timeout).FindFirstWorkingRPC(rpcURLs, expectedChainID)
+}
+
+func (t *Tester) FindAllWorkingRPCs(rpcURLs []string, expectedChainID uint64) ([]string, error) {
+	results, err := t.FindAllWorkingResults(rpcURLs, expectedChainID)
+	if err != nil {
+		return nil, err
 	}
+	return resultURLs(results), nil
+}
 
-	// Return a random working RPC
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	randomIndex := r.Intn(len(workingRPCs))
-	return workingRPCs[randomIndex], nil
+func (t *Tester) FindRandomWorkingRPC(rpcURLs []string, expectedChainID uint64) (string, error) {
+	return NewSelector(t, RandomStrategy{}).Select(rpcURLs, expectedChainID)
+}
+
+// FindFirstWorkingRPC returns the first endpoint to pass testing and cancels the
+// probes of the others, rather than waiting for all of them like FindRandomWorkingRPC
+func (t *Tester) FindFirstWorkingRPC(rpcURLs []string, expectedChainID uint64) (string, error) {
+	return NewSelector(t, FirstStrategy{}).Select(rpcURLs, expectedChainID)
+}
+
+// FindAllWorkingResults is FindAllWorkingRPCs returning what testing found out about
+// the endpoints, e.g. their latency and latest block
+func (t *Tester) FindAllWorkingResults(rpcURLs []string, expectedChainID uint64) ([]RPCResult, error) {
+	failures := newFailureLog()
+	results := t.testRPCs(rpcURLs, expectedChainID, nil, failures)
+	if len(results) == 0 {
+		return nil, failures.error(rpcURLs)
+	}
+	return results, nil
 }
 
-func findWorkingRPCsConcurrently(rpcURLs []string, expectedChainID uint64, timeout time.Duration) []string {
-	var workingRPCs []string
+// FindRandomWorkingResult is FindRandomWorkingRPC returning what testing found out
+// about the endpoint
+func (t *Tester) FindRandomWorkingResult(rpcURLs []string, expectedChainID uint64) (RPCResult, error) {
+	return NewSelector(t, RandomStrategy{}).SelectResult(rpcURLs, expectedChainID)
+}
+
+// TestRPCs returns the endpoints that passed testing together with their probe latency.
+// Unlike FindAllWorkingRPCs, no working endpoints is not an error.
+func (t *Tester) TestRPCs(rpcURLs []string, expectedChainID uint64) []RPCResult {
+	return t.testRPCs(rpcURLs, expectedChainID, nil, nil)
+}
+
+// testRPCs is TestRPCs stopping once decided, when set, is true of the endpoints
+// verified so far, and recording why the others failed in failures, when set
+func (t *Tester) testRPCs(rpcURLs []string, expectedChainID uint64, decided func([]RPCResult) bool, failures *failureLog) []RPCResult {
+	var onFailure func(string, error)
+	if failures != nil {
+		onFailure = failures.record
+	}
+	results := t.findWorkingRPCsConcurrently(context.Background(), rpcURLs, expectedChainID, newHostLimiter(t.PerHost), untilDecided(decided), onFailure)
+	if t.Scorer == nil || failures == nil {
+		return t.score(results)
+	}
+
+	scored := t.score(results)
+	for _, result := range results {
+		if !slices.ContainsFunc(scored, func(kept RPCResult) bool { return kept.URL == result.URL }) {
+			failures.record(result.URL, errLowScore(t.Scorer.MinScore))
+		}
+	}
+	return scored
+}
+
+// untilDecided turns decided into a callback of findWorkingRPCsConcurrently, which
+// calls it from a single goroutine
+func untilDecided(decided func([]RPCResult) bool) func(RPCResult) bool {
+	if decided == nil {
+		return nil
+	}
+	var verified []RPCResult
+	return func(result RPCResult) bool {
+		verified = append(verified, result)
+		return !decided(verified)
+	}
+}
+
+// CheckRPCs tests every endpoint, whatever the Target, and returns a result for each of
+// them in order. Endpoints that failed testing have Err set to the error of their last
+// attempt, the ones left untested when the budget ran out to why they were cancelled.
+func (t *Tester) CheckRPCs(rpcURLs []string, expectedChainID uint64) []RPCResult {
+	tester := *t
+	tester.Target = 0
+
+	failures := newFailureLog()
+	working := make(map[string]RPCResult, len(rpcURLs))
+	for _, result := range tester.findWorkingRPCsConcurrently(context.Background(), rpcURLs, expectedChainID, newHostLimiter(t.PerHost), nil, failures.record) {
+		working[result.URL] = result
+	}
+	// Unlike TestRPCs, endpoints scoring too low are reported rather than dropped
+	if t.Scorer != nil {
+		scored := make([]RPCResult, 0, len(working))
+		for _, result := range working {
+			scored = append(scored, result)
+		}
+		scorer := *t.Scorer
+		scorer.MinScore = 0
+		for _, result := range scorer.Score(scored) {
+			working[result.URL] = result
+		}
+	}
+
+	results := make([]RPCResult, 0, len(rpcURLs))
+	for _, rpcURL := range rpcURLs {
+		result, ok := working[rpcURL]
+		switch {
+		case !ok:
+			result = failures.result(rpcURL)
+		case t.Scorer != nil && result.Score < t.Scorer.MinScore:
+			result.Err = fmt.Sprintf("score %d below %d", result.Score, t.Scorer.MinScore)
+			result.Cause = FAILURE_SCORE
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// StreamRPCs calls fn with every endpoint as soon as it passes testing, from a single
+// goroutine. Testing stops when fn returns false. All passed endpoints are returned.
+func (t *Tester) StreamRPCs(rpcURLs []string, expectedChainID uint64, fn func(RPCResult) bool) []RPCResult {
+	return t.findWorkingRPCsConcurrently(context.Background(), rpcURLs, expectedChainID, newHostLimiter(t.PerHost), fn, nil)
+}
+
+// ChainURLs are the endpoints of a chain to test
+type ChainURLs struct {
+	ChainID uint64
+	URLs    []string
+}
+
+// TestChains tests the endpoints of several chains at once and returns the passed ones
+// by chain ID. All probes share the per-host limit, so that a provider serving several
+// of the chains is not probed harder than when testing a single chain.
+func (t *Tester) TestChains(chains []ChainURLs) map[uint64][]RPCResult {
+	return t.testChains(chains, nil)
+}
+
+// testChains is TestChains stopping to test a chain once decided, when set, is true of
+// its endpoints verified so far
+func (t *Tester) testChains(chains []ChainURLs, decided func([]RPCResult) bool) map[uint64][]RPCResult {
+	limiter := newHostLimiter(t.PerHost)
+	results := make(map[uint64][]RPCResult, len(chains))
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	// Channel to signal when timeout is reached
-	timeoutCh := time.After(timeout)
-	resultCh := make(chan string, len(rpcURLs))
+	for _, chain := range chains {
+		wg.Add(1)
+		go func(chain ChainURLs) {
+			defer wg.Done()
+			chainResults := t.score(t.findWorkingRPCsConcurrently(context.Background(), chain.URLs, chain.ChainID, limiter, untilDecided(decided), nil))
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[chain.ChainID] = chainResults
+		}(chain)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func (t *Tester) score(results []RPCResult) []RPCResult {
+	if t.Scorer == nil {
+		return results
+	}
+	return t.Scorer.Score(results)
+}
+
+func resultURLs(results []RPCResult) []string {
+	urls := make([]string, 0, len(results))
+	for _, result := range results {
+		urls = append(urls, result.URL)
+	}
+	return urls
+}
+
+// findWorkingRPCsConcurrently tests the endpoints until ctx is done and returns the
+// working ones. onFailure, when set, is called concurrently with why every other endpoint
+// failed or was cancelled, possibly once the working ones are returned.
+func (t *Tester) findWorkingRPCsConcurrently(ctx context.Context, rpcURLs []string, expectedChainID uint64, limiter *hostLimiter, onResult func(RPCResult) bool, onFailure func(string, error)) []RPCResult {
+	var workingRPCs []RPCResult
+	var wg sync.WaitGroup
+
+	// Context is cancelled when the testing window is over or the target is reached
+	ctx, cancel := context.WithTimeout(ctx, t.window())
+	defer cancel()
+	resultCh := make(chan RPCResult, len(rpcURLs))
 
 	// Test all RPCs concurrently
 	for _, rpcURL := range rpcURLs {
 		wg.Add(1)
+		t.trace(rpcURL, PROBE_QUEUED, 0, false, nil)
 		go func(url string) {
 			defer wg.Done()
-			if isRPCWorkingWithTimeout(url, expectedChainID, timeout) {
+			result, err := t.probe(ctx, limiter, url, expectedChainID)
+			if err == nil {
 				select {
-				case resultCh <- url:
-				case <-timeoutCh:
+				case resultCh <- result:
+					return
+				case <-ctx.Done():
 					// Timeout reached, don't add to results
+					err = ctx.Err()
+					t.trace(url, PROBE_CANCELLED, 0, false, err)
 				}
 			}
+			if onFailure != nil {
+				onFailure(url, err)
+			}
 		}(rpcURL)
 	}
 
@@ -90,23 +379,32 @@ func findWorkingRPCsConcurrently(rpcURLs []string, expectedChainID uint64, timeo
 		close(done)
 	}()
 
-	// Collect results until timeout or all tests complete
+	// collect records a result and reports whether testing should stop
+	collect := func(result RPCResult) bool {
+		workingRPCs = append(workingRPCs, result)
+		if onResult != nil && !onResult(result) {
+			return true
+		}
+		return t.Target > 0 && len(workingRPCs) >= t.Target
+	}
+
+	// Collect results until timeout, the target is reached or all tests complete
 	for {
 		select {
-		case url := <-resultCh:
-			mu.Lock()
-			workingRPCs = append(workingRPCs, url)
-			mu.Unlock()
-		case <-timeoutCh:
+		case result := <-resultCh:
+			if collect(result) {
+				return workingRPCs
+			}
+		case <-ctx.Done():
 			return workingRPCs
 		case <-done:
 			// Drain any remaining results
 			for {
 				select {
-				case url := <-resultCh:
-					mu.Lock()
-					workingRPCs = append(workingRPCs, url)
-					mu.Unlock()
+				case result := <-resultCh:
+					if collect(result) {
+						return workingRPCs
+					}
 				default:
 					return workingRPCs
 				}
@@ -115,132 +413,139 @@ func findWorkingRPCsConcurrently(rpcURLs []string, expectedChainID uint64, timeo
 	}
 }
 
-func isRPCWorkingWithTimeout(rpcURL string, expectedChainID uint64, timeout time.Duration) bool {
-	if isWebSocketURL(rpcURL) {
-		return isWebSocketRPCWorking(rpcURL, expectedChainID, timeout)
-	}
-	return isHTTPRPCWorking(rpcURL, expectedChainID, timeout)
-}
-
-func isWebSocketURL(rpcURL string) bool {
-	return strings.HasPrefix(rpcURL, "wss://")
-}
-
-func isHTTPRPCWorking(rpcURL string, expectedChainID uint64, timeout time.Duration) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	request := RPCRequest{
-		JSONRPC: "2.0",
-		Method:  "eth_chainId",
-		Params:  []any{},
-		ID:      1,
+// probe tests the endpoint, retrying failed attempts with jittered exponential backoff.
+// It returns the result of the successful attempt, otherwise the error of the last one
+// or why probing was cancelled.
+func (t *Tester) probe(ctx context.Context, limiter *hostLimiter, rpcURL string, expectedChainID uint64) (RPCResult, error) {
+	limits := &rateLimits{}
+	ctx = withSession(withRateLimits(ctx, limits), t.Session)
+	if t.Dial != nil {
+		ctx = WithDialer(ctx, t.Dial)
+	} else if t.Transport != nil {
+		ctx = WithTransport(ctx, t.Transport)
 	}
 
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return false
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", rpcURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return false
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
+	for attempt := 0; ; attempt++ {
+		release, err := limiter.acquire(ctx, rpcURL)
+		if err != nil {
+			t.trace(rpcURL, PROBE_CANCELLED, attempt, false, err)
+			return RPCResult{}, err
+		}
 
-	if resp.StatusCode != 200 {
-		return false
-	}
+		if t.WarmUp && attempt == 0 {
+			t.warmUp(ctx, rpcURL)
+		}
 
-	var rpcResp RPCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return false
-	}
+		result := RPCResult{URL: rpcURL, IsWebSocket: isWebSocketURL(rpcURL)}
+		err = t.attempt(ctx, rpcURL, expectedChainID, attempt, &result)
+		release()
+		if err == nil && t.ExcludeRateLimited && limits.isThrottled() {
+			err = errThrottled
+		}
+		if err == nil {
+			result.RateLimit = limits.classification()
+			t.trace(rpcURL, PROBE_FINISHED, attempt, true, nil)
+			t.conclude(expectedChainID, rpcURL, result.Latency, true)
+			logger.Debug("probe passed", "url", rpcURL, "attempt", attempt, "latency", result.Latency)
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			t.trace(rpcURL, PROBE_CANCELLED, attempt, false, ctx.Err())
+			return RPCResult{}, ctx.Err()
+		}
+		limits.observeError(err)
+		t.trace(rpcURL, PROBE_FINISHED, attempt, false, err)
+		logger.Debug("probe failed", "url", rpcURL, "attempt", attempt, "cause", ClassifyError(err), "error", err)
+		// Retrying cannot undo having been throttled
+		if attempt >= t.Retries || (t.ExcludeRateLimited && limits.isThrottled()) {
+			t.conclude(expectedChainID, rpcURL, 0, false)
+			return RPCResult{}, err
+		}
 
-	if rpcResp.Error != nil {
-		return false
+		delay := backoffDelay(attempt)
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			// The failed attempt tells more than the cancellation
+			t.trace(rpcURL, PROBE_CANCELLED, attempt, false, ctx.Err())
+			return RPCResult{}, err
+		}
 	}
+}
 
-	chainIDHex, ok := rpcResp.Result.(string)
-	if !ok {
-		return false
+func (t *Tester) conclude(chainID uint64, rpcURL string, latency time.Duration, ok bool) {
+	if t.OnProbe != nil {
+		t.OnProbe(ProbeOutcome{ChainID: chainID, URL: rpcURL, Time: time.Now(), Latency: latency, OK: ok})
 	}
+}
 
-	chainID, err := strconv.ParseUint(chainIDHex, 0, 64)
-	if err != nil {
-		return false
-	}
+// warmUp sets up a reusable connection to the endpoint, the outcome does not matter
+func (t *Tester) warmUp(ctx context.Context, rpcURL string) {
+	t.trace(rpcURL, PROBE_WARMUP, 0, false, nil)
 
-	return chainID == expectedChainID
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	Call(ctx, rpcURL, "eth_chainId")
 }
 
-func isWebSocketRPCWorking(rpcURL string, expectedChainID uint64, timeout time.Duration) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+// attempt makes a single eth_chainId request followed by the checks, bounded by the tester timeout.
+// The latency of the result is the eth_chainId round trip only, checks are not part of it.
+func (t *Tester) attempt(ctx context.Context, rpcURL string, expectedChainID uint64, attempt int, result *RPCResult) error {
+	t.trace(rpcURL, PROBE_STARTED, attempt, false, nil)
+
+	ctx, cancel := context.WithTimeout(t.withConnectTrace(ctx, rpcURL, attempt), t.Timeout)
 	defer cancel()
 
-	// Parse URL for websocket connection
-	u, err := url.Parse(rpcURL)
+	start := time.Now()
+	raw, err := Call(ctx, rpcURL, "eth_chainId")
 	if err != nil {
-		return false
+		return err
 	}
+	latency := time.Since(start)
 
-	// Create websocket dialer with timeout
-	dialer := websocket.Dialer{
-		HandshakeTimeout: timeout,
-	}
-
-	// Connect to websocket
-	conn, _, err := dialer.DialContext(ctx, u.String(), nil)
+	chainID, err := parseQuantity(raw)
 	if err != nil {
-		return false
+		return err
 	}
-	defer conn.Close()
-
-	// Set read/write deadlines
-	deadline := time.Now().Add(timeout)
-	conn.SetReadDeadline(deadline)
-	conn.SetWriteDeadline(deadline)
-
-	// Prepare RPC request
-	request := RPCRequest{
-		JSONRPC: "2.0",
-		Method:  "eth_chainId",
-		Params:  []any{},
-		ID:      1,
+	if chainID != expectedChainID {
+		return &ChainIDMismatchError{ChainID: chainID, Expected: expectedChainID}
 	}
+	result.Latency = latency
 
-	// Send JSON-RPC request
-	if err := conn.WriteJSON(request); err != nil {
-		return false
+	for _, check := range t.Checks {
+		if err := check.Run(ctx, rpcURL, result); err != nil {
+			return &checkError{err: err}
+		}
 	}
+	return nil
+}
 
-	// Read response
-	var rpcResp RPCResponse
-	if err := conn.ReadJSON(&rpcResp); err != nil {
-		return false
+// window is the time budget, by default the longest time a probe may take including
+// the warm-up and all retries
+func (t *Tester) window() time.Duration {
+	if t.Budget > 0 {
+		return t.Budget
 	}
 
-	if rpcResp.Error != nil {
-		return false
+	window := t.Timeout
+	if t.WarmUp {
+		window += t.Timeout
 	}
-
-	chainIDHex, ok := rpcResp.Result.(string)
-	if !ok {
-		return false
+	for attempt := 0; attempt < t.Retries; attempt++ {
+		window += backoffDelay(attempt) + t.Timeout
 	}
+	return window
+}
 
-	chainID, err := strconv.ParseUint(chainIDHex, 0, 64)
-	if err != nil {
-		return false
+func backoffDelay(attempt int) time.Duration {
+	delay := RETRY_BASE_DELAY
+	for i := 0; i < attempt && delay < RETRY_MAX_DELAY; i++ {
+		delay *= 2
 	}
+	return min(delay, RETRY_MAX_DELAY)
+}
 
-	return chainID == expectedChainID
+func isWebSocketURL(rpcURL string) bool {
+	return strings.HasPrefix(rpcURL, "wss://") || strings.HasPrefix(rpcURL, "ws://")
 }
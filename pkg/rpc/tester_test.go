@@ -0,0 +1,79 @@
+package rpc_test
+
+import (
+	"testing"
+	"time"
+
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/rpctest"
+)
+
+const testChainID = 424242
+
+func TestTesterFaults(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		behavior rpctest.Behavior
+		cause    string
+	}{
+		{name: "healthy", behavior: rpctest.Healthy},
+		{name: "wrong chain id", behavior: rpctest.WrongChainID(1), cause: rpc.FAILURE_CHAIN_ID},
+		{name: "malformed body", behavior: rpctest.Malformed(), cause: rpc.FAILURE_INVALID_RESPONSE},
+		{name: "latency beyond timeout", behavior: rpctest.Delay(3 * timeout), cause: rpc.FAILURE_TIMEOUT},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := rpctest.NewServer(testChainID, test.behavior)
+			defer server.Close()
+
+			tester := rpc.NewTester(timeout)
+			results := tester.CheckRPCs([]string{server.URL}, testChainID)
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			result := results[0]
+			if result.Cause != test.cause {
+				t.Errorf("cause = %q, want %q (error: %s)", result.Cause, test.cause, result.Err)
+			}
+			if test.cause == "" && result.Latency <= 0 {
+				t.Errorf("latency = %s, want a measured one", result.Latency)
+			}
+		})
+	}
+}
+
+func TestTesterRetriesFaults(t *testing.T) {
+	server := rpctest.NewServer(testChainID, rpctest.Malformed(), rpctest.Healthy)
+	defer server.Close()
+
+	tester := rpc.NewTester(200 * time.Millisecond)
+	tester.Retries = 1
+	working, err := tester.FindAllWorkingRPCs([]string{server.URL}, testChainID)
+	if err != nil {
+		t.Fatalf("FindAllWorkingRPCs: %v", err)
+	}
+	if len(working) != 1 || working[0] != server.URL {
+		t.Errorf("working = %v, want %s", working, server.URL)
+	}
+	if requests := server.Requests(); requests != 2 {
+		t.Errorf("server got %d requests, want 2", requests)
+	}
+}
+
+func TestTesterLatencyLeavesOutChecks(t *testing.T) {
+	// The eth_chainId round trip is fast, the web3_clientVersion of the check is not
+	server := rpctest.NewServer(testChainID, rpctest.Healthy, rpctest.Delay(150*time.Millisecond))
+	defer server.Close()
+
+	tester := rpc.NewTester(time.Second)
+	tester.Checks = []rpc.Check{rpc.ClientCheck{}}
+	results := tester.CheckRPCs([]string{server.URL}, testChainID)
+	if results[0].Err != "" {
+		t.Fatalf("endpoint failed: %s", results[0].Err)
+	}
+	if latency := results[0].Latency; latency >= 150*time.Millisecond {
+		t.Errorf("latency = %s, want the eth_chainId round trip only", latency)
+	}
+}
@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+var tlsConfig *tls.Config
+
+// TLSOptions customize the TLS connections to endpoints, e.g. for gateways with a
+// private CA or requiring client certificates (mTLS)
+type TLSOptions struct {
+	// CACert is a PEM bundle of CAs trusted in addition to the system ones
+	CACert string
+	// ClientCert is the PEM client certificate, and ClientKey its private key. The key
+	// may be in the certificate file.
+	ClientCert string
+	ClientKey  string
+	// Insecure skips the verification of the certificates of endpoints
+	Insecure bool
+}
+
+// Config returns the TLS configuration of the options, nil when they are all unset
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if o.CACert == "" && o.ClientCert == "" && o.ClientKey == "" && !o.Insecure {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: o.Insecure}
+	if o.CACert != "" {
+		pem, err := os.ReadFile(o.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", o.CACert)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.ClientCert == "" && o.ClientKey != "" {
+		return nil, fmt.Errorf("client key given without client certificate")
+	}
+	if o.ClientCert != "" {
+		keyFile := o.ClientKey
+		if keyFile == "" {
+			keyFile = o.ClientCert
+		}
+		certificate, err := tls.LoadX509KeyPair(o.ClientCert, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// SetTLSConfig sets the TLS configuration of the HTTP requests and WebSocket
+// connections to endpoints. nil restores the defaults.
+func SetTLSConfig(config *tls.Config) {
+	tlsConfig = config
+	updateTransport()
+}
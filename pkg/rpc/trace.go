@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"context"
+	"net/http/httptrace"
+	"time"
+)
+
+// Probe lifecycle stages reported to Tester.Trace
+const (
+	PROBE_QUEUED    = "queued"
+	PROBE_WARMUP    = "warmup"
+	PROBE_STARTED   = "started"
+	PROBE_CONNECTED = "connected"
+	PROBE_FINISHED  = "finished"
+	PROBE_CANCELLED = "cancelled"
+)
+
+// ProbeEvent is a step in the lifecycle of an endpoint probe
+type ProbeEvent struct {
+	Time  time.Time `json:"time"`
+	URL   string    `json:"url"`
+	Stage string    `json:"stage"`
+	// Attempt is the zero-based attempt number, retries count up from 1
+	Attempt int `json:"attempt"`
+	// OK tells whether a finished attempt verified the endpoint
+	OK bool `json:"ok,omitempty"`
+	// Err is why a finished attempt failed or a probe was cancelled
+	Err string `json:"error,omitempty"`
+}
+
+func (t *Tester) trace(rpcURL, stage string, attempt int, ok bool, err error) {
+	if t.Trace == nil {
+		return
+	}
+
+	event := ProbeEvent{Time: time.Now(), URL: rpcURL, Stage: stage, Attempt: attempt, OK: ok}
+	if err != nil {
+		event.Err = err.Error()
+	}
+	t.Trace(event)
+}
+
+// withConnectTrace reports when the attempt has a connection to the endpoint
+func (t *Tester) withConnectTrace(ctx context.Context, rpcURL string, attempt int) context.Context {
+	if t.Trace == nil {
+		return ctx
+	}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			t.trace(rpcURL, PROBE_CONNECTED, attempt, false, nil)
+		},
+	})
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	DEFAULT_DIAL_TIMEOUT      = 10 * time.Second
+	DEFAULT_KEEP_ALIVE        = 30 * time.Second
+	DEFAULT_IDLE_CONN_TIMEOUT = 90 * time.Second
+	// DEFAULT_MAX_IDLE_CONNS_PER_HOST keeps a connection for every simultaneous probe of
+	// a host and its warm-up, rather than the two of net/http
+	DEFAULT_MAX_IDLE_CONNS_PER_HOST = 2 * DEFAULT_PER_HOST_PROBES
+)
+
+// TransportOptions tune the HTTP connections to endpoints
+type TransportOptions struct {
+	// DialTimeout bounds TCP connection setup and the TLS handshake
+	DialTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes, negative to disable them
+	KeepAlive time.Duration
+	// IdleConnTimeout closes connections left idle for longer
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is how many idle connections are kept per host for reuse
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per host, 0 for no limit
+	MaxConnsPerHost int
+	// DisableKeepAlives opens a new connection for every request
+	DisableKeepAlives bool
+}
+
+// DefaultTransportOptions returns the options of the transport shared by requests
+// to endpoints
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		DialTimeout:         DEFAULT_DIAL_TIMEOUT,
+		KeepAlive:           DEFAULT_KEEP_ALIVE,
+		IdleConnTimeout:     DEFAULT_IDLE_CONN_TIMEOUT,
+		MaxIdleConnsPerHost: DEFAULT_MAX_IDLE_CONNS_PER_HOST,
+	}
+}
+
+// NewTransport returns a transport to endpoints tuned with the options, going through
+// the proxy of SetProxy or of the environment and using the TLS configuration set when
+// it is created. Share it between requests, e.g. with Tester.Transport across rounds
+// of monitoring, so that connections and TLS sessions are reused.
+func NewTransport(options TransportOptions) *http.Transport {
+	dialer := &net.Dialer{Timeout: options.DialTimeout, KeepAlive: options.KeepAlive}
+	return &http.Transport{
+		Proxy:                 Proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   options.DialTimeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     options.DisableKeepAlives,
+	}
+}
+
+// endpointTransport is the transport of endpoint requests without one of their own,
+// shared by all of them so that connections are reused across probes and retries
+var endpointTransport = NewTransport(DefaultTransportOptions())
+
+// updateTransport rebuilds the shared transport once the proxy or TLS configuration changed
+func updateTransport() {
+	endpointTransport.CloseIdleConnections()
+	endpointTransport = NewTransport(DefaultTransportOptions())
+}
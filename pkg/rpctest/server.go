@@ -0,0 +1,161 @@
+// Package rpctest runs fake JSON-RPC endpoints in-process with scripted faults, so
+// that endpoint discovery can be tested deterministically
+package rpctest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"chain-rpc/pkg/mock"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/gorilla/websocket"
+)
+
+// MALFORMED_BODY is the truncated JSON answered by Malformed
+const MALFORMED_BODY = `{"jsonrpc":"2.0","id":1,"result":"0x`
+
+// Behavior is how the server answers a request. The zero Behavior answers like
+// mock.Server does.
+type Behavior struct {
+	// Latency delays the answer
+	Latency time.Duration
+	// ChainID, when set, is served instead of the chain of the server
+	ChainID uint64
+	// Status, when set, fails the request with this HTTP status
+	Status int
+	// Body, when set, is answered verbatim, e.g. malformed JSON
+	Body string
+	// Error, when set, is answered as the JSON-RPC error of the request
+	Error *rpc.RPCError
+}
+
+// Healthy answers normally
+var Healthy = Behavior{}
+
+// Delay answers normally after the latency
+func Delay(latency time.Duration) Behavior {
+	return Behavior{Latency: latency}
+}
+
+// WrongChainID serves another chain
+func WrongChainID(chainID uint64) Behavior {
+	return Behavior{ChainID: chainID}
+}
+
+// Malformed answers truncated JSON
+func Malformed() Behavior {
+	return Behavior{Body: MALFORMED_BODY}
+}
+
+// HTTPError fails the request with the HTTP status, e.g. 429 for rate limiting
+func HTTPError(status int) Behavior {
+	return Behavior{Status: status}
+}
+
+// JSONRPCError answers the JSON-RPC error
+func JSONRPCError(code int, message string) Behavior {
+	return Behavior{Error: &rpc.RPCError{Code: code, Message: message}}
+}
+
+// Server is a fake endpoint following a script: the nth HTTP request, a batch
+// counting as one, is answered with the nth behavior of the script, and the last
+// behavior repeats once the script is over. WebSocket connections are served
+// without faults.
+type Server struct {
+	// URL is the HTTP endpoint of the server
+	URL string
+	// WSURL is the WebSocket endpoint of the server
+	WSURL string
+	// Addr is the address of the listener, to map a fake host name to with rpc.MapHosts
+	Addr string
+
+	mock     *mock.Server
+	server   *httptest.Server
+	mu       sync.Mutex
+	script   []Behavior
+	requests int
+}
+
+// NewServer starts a server of the chain following the script, healthy without one.
+// Close it when done.
+func NewServer(chainID uint64, script ...Behavior) *Server {
+	s := &Server{mock: mock.NewServer(chainID), script: script}
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	s.Addr = s.server.Listener.Addr().String()
+	s.WSURL = "ws" + strings.TrimPrefix(s.server.URL, "http")
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// SetScript replaces the script, starting over with the next request
+func (s *Server) SetScript(script ...Behavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = script
+	s.requests = 0
+}
+
+// Requests returns the number of HTTP requests served since the script was set
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || websocket.IsWebSocketUpgrade(r) {
+		s.mock.ServeHTTP(w, r)
+		return
+	}
+
+	behavior := s.next()
+	if behavior.Latency > 0 {
+		select {
+		case <-time.After(behavior.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case behavior.Status != 0:
+		http.Error(w, http.StatusText(behavior.Status), behavior.Status)
+	case behavior.Body != "":
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, behavior.Body)
+	case behavior.Error != nil:
+		// A batch gets a single error, as nodes answer requests they cannot process
+		var request rpc.RPCRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rpc.RPCResponse{JSONRPC: "2.0", Error: behavior.Error, ID: request.ID})
+	case behavior.ChainID != 0:
+		mock.NewServer(behavior.ChainID).ServeHTTP(w, r)
+	default:
+		s.mock.ServeHTTP(w, r)
+	}
+}
+
+// next returns the behavior of the request being served
+func (s *Server) next() Behavior {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.requests
+	s.requests++
+	if len(s.script) == 0 {
+		return Healthy
+	}
+	return s.script[min(i, len(s.script)-1)]
+}
@@ -0,0 +1,105 @@
+package rpctest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/rpctest"
+)
+
+const testChainID = 424242
+
+const chainIDRequest = `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`
+
+// post sends an eth_chainId request and returns the status and body of the answer
+func post(t *testing.T, server *rpctest.Server) (int, string) {
+	t.Helper()
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(chainIDRequest))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read the answer: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestServerBehaviors(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior rpctest.Behavior
+		status   int
+		result   string
+		code     int
+		body     string
+	}{
+		{name: "healthy", behavior: rpctest.Healthy, status: http.StatusOK, result: "0x67932"},
+		{name: "wrong chain id", behavior: rpctest.WrongChainID(1), status: http.StatusOK, result: "0x1"},
+		{name: "http error", behavior: rpctest.HTTPError(http.StatusTooManyRequests), status: http.StatusTooManyRequests},
+		{name: "json-rpc error", behavior: rpctest.JSONRPCError(-32005, "limit exceeded"), status: http.StatusOK, code: -32005},
+		{name: "malformed body", behavior: rpctest.Malformed(), status: http.StatusOK, body: rpctest.MALFORMED_BODY},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := rpctest.NewServer(testChainID, test.behavior)
+			defer server.Close()
+
+			status, body := post(t, server)
+			if status != test.status {
+				t.Fatalf("status = %d, want %d", status, test.status)
+			}
+			if test.body != "" {
+				if body != test.body {
+					t.Errorf("body = %q, want %q", body, test.body)
+				}
+				return
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			var response rpc.RPCResponse
+			if err := json.Unmarshal([]byte(body), &response); err != nil {
+				t.Fatalf("invalid answer %q: %v", body, err)
+			}
+			if test.code != 0 {
+				if response.Error == nil || response.Error.Code != test.code {
+					t.Errorf("error = %v, want code %d", response.Error, test.code)
+				}
+				return
+			}
+			if response.Result != test.result {
+				t.Errorf("result = %v, want %s", response.Result, test.result)
+			}
+		})
+	}
+}
+
+func TestServerScript(t *testing.T) {
+	server := rpctest.NewServer(testChainID, rpctest.HTTPError(http.StatusBadGateway), rpctest.Healthy)
+	defer server.Close()
+
+	// The last behavior repeats once the script is over
+	for i, want := range []int{http.StatusBadGateway, http.StatusOK, http.StatusOK} {
+		if status, _ := post(t, server); status != want {
+			t.Errorf("request %d: status = %d, want %d", i+1, status, want)
+		}
+	}
+	if requests := server.Requests(); requests != 3 {
+		t.Errorf("server got %d requests, want 3", requests)
+	}
+
+	server.SetScript(rpctest.HTTPError(http.StatusServiceUnavailable))
+	if requests := server.Requests(); requests != 0 {
+		t.Errorf("requests after SetScript = %d, want 0", requests)
+	}
+	if status, _ := post(t, server); status != http.StatusServiceUnavailable {
+		t.Errorf("status after SetScript = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,76 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// GoFile is a Go source file with fallback endpoints, for Go projects to vendor
+type GoFile struct {
+	Package string
+	// Var names the map of endpoints by chain ID, and prefixes the generation time
+	Var         string
+	GeneratedAt time.Time
+	Chains      []GoChain
+}
+
+// GoChain is a chain of a GoFile with its endpoints, in order of preference
+type GoChain struct {
+	ID   uint64
+	Name string
+	URLs []string
+}
+
+var goTemplate = template.Must(template.New("go").Funcs(template.FuncMap{
+	"goquote": strconv.Quote,
+	// comment keeps names on the line of their comment
+	"comment": func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	},
+}).Parse(`// Code generated by chain-rpc export go; DO NOT EDIT.
+
+package {{.Package}}
+
+import "time"
+
+// {{.Var}}GeneratedAt is when the endpoints of {{.Var}} were verified
+var {{.Var}}GeneratedAt = time.Date({{.GeneratedAt.Year}}, time.{{.GeneratedAt.Month}}, {{.GeneratedAt.Day}}, {{.GeneratedAt.Hour}}, {{.GeneratedAt.Minute}}, {{.GeneratedAt.Second}}, 0, time.UTC)
+
+// {{.Var}} are endpoints by chain ID that worked when generated, fastest first
+var {{.Var}} = map[uint64][]string{
+{{- range .Chains}}
+	{{.ID}}: { // {{comment .Name}}
+	{{- range .URLs}}
+		{{goquote .}},
+	{{- end}}
+	},
+{{- end}}
+}
+`))
+
+// RenderGo returns the source of the file, formatted like gofmt does
+func RenderGo(file GoFile) ([]byte, error) {
+	if !token.IsIdentifier(file.Package) {
+		return nil, fmt.Errorf("invalid Go package name %q", file.Package)
+	}
+	if !token.IsIdentifier(file.Var) {
+		return nil, fmt.Errorf("invalid Go variable name %q", file.Var)
+	}
+	file.GeneratedAt = file.GeneratedAt.UTC()
+
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, file); err != nil {
+		return nil, fmt.Errorf("failed to render Go file: %v", err)
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format Go file: %v", err)
+	}
+	return source, nil
+}
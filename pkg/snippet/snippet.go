@@ -0,0 +1,129 @@
+// Package snippet renders chain configurations ready to paste into development tools
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Chain is what snippets are made of
+type Chain struct {
+	ID   uint64
+	Name string
+	// Key names the chain in the snippet, e.g. "sepolia", see Key
+	Key            string
+	RPCURL         string
+	WSURL          string
+	CurrencyName   string
+	CurrencySymbol string
+	Decimals       int
+	ExplorerName   string
+	ExplorerURL    string
+	Testnet        bool
+}
+
+var funcs = template.FuncMap{
+	// quote makes a double-quoted string, valid in TOML, JavaScript and TypeScript
+	"quote": func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data)
+	},
+	"camel": camelCase,
+}
+
+var templates = map[string]*template.Template{
+	"foundry": template.Must(template.New("foundry").Funcs(funcs).Parse(`# {{.Name}} ({{.ID}})
+[rpc_endpoints]
+{{.Key}} = {{quote .RPCURL}}
+`)),
+
+	"hardhat": template.Must(template.New("hardhat").Funcs(funcs).Parse(`// {{.Name}} ({{.ID}}), in the networks of hardhat.config
+{{.Key}}: {
+  url: {{quote .RPCURL}},
+  chainId: {{.ID}},
+},
+`)),
+
+	"viem": template.Must(template.New("viem").Funcs(funcs).Parse(`import { defineChain } from "viem";
+
+export const {{camel .Key}} = defineChain({
+  id: {{.ID}},
+  name: {{quote .Name}},
+  nativeCurrency: { name: {{quote .CurrencyName}}, symbol: {{quote .CurrencySymbol}}, decimals: {{.Decimals}} },
+  rpcUrls: {
+    default: {
+      http: [{{if .RPCURL}}{{quote .RPCURL}}{{end}}],{{if .WSURL}}
+      webSocket: [{{quote .WSURL}}],{{end}}
+    },
+  },{{if .ExplorerURL}}
+  blockExplorers: {
+    default: { name: {{quote .ExplorerName}}, url: {{quote .ExplorerURL}} },
+  },{{end}}{{if .Testnet}}
+  testnet: true,{{end}}
+});
+`)),
+}
+
+// Formats returns the supported snippet formats
+func Formats() []string {
+	formats := make([]string, 0, len(templates))
+	for format := range templates {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Render returns the snippet of the chain in the format
+func Render(format string, chain Chain) (string, error) {
+	tmpl, exists := templates[format]
+	if !exists {
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, chain); err != nil {
+		return "", fmt.Errorf("failed to render %s snippet: %v", format, err)
+	}
+	return buf.String(), nil
+}
+
+// Key turns a chain name or slug into a lowercase identifier with underscores,
+// usable as a TOML key and JavaScript property, e.g. "Arbitrum One" -> "arbitrum_one"
+func Key(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) && r < unicode.MaxASCII || unicode.IsDigit(r) && r < unicode.MaxASCII {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			underscore = false
+		} else {
+			underscore = true
+		}
+	}
+
+	key := b.String()
+	if key == "" || unicode.IsDigit(rune(key[0])) {
+		key = "chain_" + key
+	}
+	return strings.TrimSuffix(key, "_")
+}
+
+// camelCase turns a key into a JavaScript variable name, e.g. "arbitrum_one" -> "arbitrumOne"
+func camelCase(key string) string {
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
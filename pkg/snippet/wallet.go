@@ -0,0 +1,38 @@
+package snippet
+
+import "fmt"
+
+// AddEthereumChainParameter is the parameter of the wallet_addEthereumChain request of
+// EIP-3085, with which dapps ask wallets to add a chain
+type AddEthereumChainParameter struct {
+	// ChainID is hexadecimal, e.g. "0xaa36a7"
+	ChainID        string         `json:"chainId"`
+	ChainName      string         `json:"chainName"`
+	NativeCurrency NativeCurrency `json:"nativeCurrency"`
+	RPCURLs        []string       `json:"rpcUrls"`
+	// BlockExplorerURLs is left out when the chain has no explorer, as wallets reject an empty list
+	BlockExplorerURLs []string `json:"blockExplorerUrls,omitempty"`
+}
+
+// NativeCurrency is the currency wallets show balances and fees in
+type NativeCurrency struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// AddEthereumChain returns the wallet_addEthereumChain parameter of the chain with the
+// endpoints wallets should use, in order of preference
+func AddEthereumChain(chain Chain, rpcURLs, explorerURLs []string) AddEthereumChainParameter {
+	return AddEthereumChainParameter{
+		ChainID:   fmt.Sprintf("0x%x", chain.ID),
+		ChainName: chain.Name,
+		NativeCurrency: NativeCurrency{
+			Name:     chain.CurrencyName,
+			Symbol:   chain.CurrencySymbol,
+			Decimals: chain.Decimals,
+		},
+		RPCURLs:           rpcURLs,
+		BlockExplorerURLs: explorerURLs,
+	}
+}
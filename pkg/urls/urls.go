@@ -0,0 +1,84 @@
+// Package urls normalizes endpoint URLs, so that an endpoint listed several ways is
+// probed and printed once
+package urls
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultPorts are the ports implied by the schemes of endpoints
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443"}
+
+// secureSchemes map plaintext schemes to their encrypted counterparts
+var secureSchemes = map[string]string{"http": "https", "ws": "wss"}
+
+// Normalize lowercases the scheme and host of the URL and drops the default port and a
+// trailing slash, e.g. HTTPS://RPC.Example.org:443/eth/ becomes https://rpc.example.org/eth.
+// The rest is kept verbatim, API key templates included. Malformed URLs are only trimmed.
+func Normalize(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return rawURL
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	authority, rest := rawURL[len(u.Scheme)+len("://"):], ""
+	if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+		authority, rest = authority[:i], authority[i:]
+	}
+	userinfo := ""
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userinfo = authority[:i+1]
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+
+	path, suffix := rest, ""
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		path, suffix = rest[:i], rest[i:]
+	}
+	return scheme + "://" + userinfo + host + strings.TrimRight(path, "/") + suffix
+}
+
+// key identifies the endpoint of a normalized URL regardless of encryption, which is
+// the only difference between http:// and https:// listings of the same endpoint
+func key(normalized string) string {
+	scheme, rest, found := strings.Cut(normalized, "://")
+	if !found {
+		return normalized
+	}
+	if secure, plaintext := secureSchemes[scheme]; plaintext {
+		scheme = secure
+	}
+	return scheme + "://" + rest
+}
+
+// Dedup normalizes the URLs and drops the repeated ones, keeping the order of the first
+// listing. An endpoint listed both in plaintext and encrypted is kept encrypted. HTTP and
+// WebSocket URLs of the same host are different endpoints and are both kept.
+func Dedup(rawURLs []string) []string {
+	deduped := make([]string, 0, len(rawURLs))
+	index := make(map[string]int, len(rawURLs))
+	for _, rawURL := range rawURLs {
+		normalized := Normalize(rawURL)
+		k := key(normalized)
+		i, exists := index[k]
+		if !exists {
+			index[k] = len(deduped)
+			deduped = append(deduped, normalized)
+			continue
+		}
+		if k == normalized {
+			deduped[i] = normalized
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"chain-rpc/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyOut     string
+	policyReplace bool
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Share the endpoint tags and local chains",
+	Long:  "Commands to bundle the personal curation layer, the endpoint tags and the local chain registry, into a single file, and to apply such a file, so that teams can give every developer machine a common endpoint policy",
+}
+
+var policyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the endpoint tags and local chains to a policy file",
+	Long:  "Writes the endpoint tags and the local chain registry to a policy file, or to stdout",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tags, err := config.LoadTags(tagsPath)
+		if err != nil {
+			return err
+		}
+		chains, err := config.LoadRegistryEntries(localRegistryPath(cmd))
+		if err != nil {
+			return err
+		}
+
+		policy := config.Policy{Version: config.POLICY_VERSION, Tags: tags, Chains: chains}
+		if policyOut == "" {
+			return printJSON(policy)
+		}
+
+		data, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize policy: %v", err)
+		}
+		if err := os.WriteFile(policyOut, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write policy: %v", err)
+		}
+		notef("Exported %d tagged endpoints and %d local chains to %s", len(tags), len(chains), policyOut)
+		return nil
+	},
+}
+
+var policyImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Apply a policy file",
+	Long:  "Adds the endpoint tags and local chains of a policy file to the local ones. Tags are added to the existing tags of an endpoint and chains replace the local definition of the same chain. With --replace, the local tags and chains are replaced altogether.",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy, err := config.ReadPolicy(args[0])
+		if err != nil {
+			return err
+		}
+		for url, endpointTags := range policy.Tags {
+			if err := validateTagArgs(cmd, url, endpointTags); err != nil {
+				return err
+			}
+		}
+
+		tags := make(config.Tags)
+		chainsPath := localRegistryPath(cmd)
+		var chains []json.RawMessage
+		if !policyReplace {
+			if tags, err = config.LoadTags(tagsPath); err != nil {
+				return err
+			}
+			if chains, err = config.LoadRegistryEntries(chainsPath); err != nil {
+				return err
+			}
+		}
+
+		for url, endpointTags := range policy.Tags {
+			tags.Add(url, endpointTags...)
+		}
+		if chains, err = config.MergeRegistryEntries(chains, policy.Chains); err != nil {
+			return err
+		}
+
+		if err := tags.Save(tagsPath); err != nil {
+			return err
+		}
+		if err := config.SaveRegistryEntries(chainsPath, chains); err != nil {
+			return err
+		}
+		notef("Imported %d tagged endpoints and %d local chains from %s", len(policy.Tags), len(policy.Chains), args[0])
+		return nil
+	},
+}
+
+func init() {
+	policyExportCmd.Flags().StringVar(&policyOut, "out", "", "policy file to write (default: stdout)")
+	policyImportCmd.Flags().BoolVar(&policyReplace, "replace", false, "replace the local tags and chains rather than adding to them")
+	policyCmd.AddCommand(policyExportCmd)
+	policyCmd.AddCommand(policyImportCmd)
+}
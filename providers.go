@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/urls"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	excludeProviders []string
+	preferProviders  []string
+
+	keyedURLsMux sync.Mutex
+	// keyedURLs maps the normalized endpoint URLs completed with API keys to their
+	// normalized template
+	keyedURLs = make(map[string]string)
+)
+
+// applyProviderConfig falls back to the providers of the config file for the
+// provider flags not given on the command line or in the environment
+func applyProviderConfig(cmd *cobra.Command) {
+	if !cmd.Flags().Changed("exclude-provider") && os.Getenv("CHAIN_RPC_EXCLUDE_PROVIDER") == "" {
+		excludeProviders = cfg.Providers.Exclude
+	}
+	if !cmd.Flags().Changed("prefer-provider") && os.Getenv("CHAIN_RPC_PREFER_PROVIDER") == "" {
+		preferProviders = cfg.Providers.Prefer
+	}
+}
+
+// isExcludedProvider reports whether the endpoint belongs to a provider of --exclude-provider
+func isExcludedProvider(rpcURL string) bool {
+	return matchesProvider(rpcURL, excludeProviders)
+}
+
+// isPreferredProvider reports whether the endpoint belongs to a provider of --prefer-provider
+func isPreferredProvider(rpcURL string) bool {
+	return matchesProvider(rpcURL, preferProviders)
+}
+
+// matchesProvider reports whether the endpoint host is one of the provider domains or
+// a subdomain of one
+func matchesProvider(rpcURL string, providers []string) bool {
+	if len(providers) == 0 {
+		return false
+	}
+	u, err := url.Parse(rpcURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, provider := range providers {
+		provider = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(provider)), ".")
+		if provider != "" && (host == provider || strings.HasSuffix(host, "."+provider)) {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredFirst moves the endpoints of preferred providers to the front, keeping the
+// order of the endpoints otherwise
+func preferredFirst(urls []string) []string {
+	if len(preferProviders) == 0 {
+		return urls
+	}
+	ordered := make([]string, 0, len(urls))
+	var others []string
+	for _, rpcURL := range urls {
+		if isPreferredProvider(rpcURL) {
+			ordered = append(ordered, rpcURL)
+		} else {
+			others = append(others, rpcURL)
+		}
+	}
+	return append(ordered, others...)
+}
+
+// substituteKeys fills the API key placeholders of the endpoint URL from the
+// environment variables they name, then from the provider keys of the config file,
+// and reports whether no placeholder is left. Keys only go to the hosts of their
+// provider, see rpc.SubstituteProviderKeys.
+func substituteKeys(rpcURL string) (string, bool) {
+	var domains map[string][]string
+	if cfg != nil {
+		domains = make(map[string][]string, len(cfg.Providers.Keys))
+		for provider, key := range cfg.Providers.Keys {
+			domains[strings.ToLower(provider)] = key.Domains
+		}
+	}
+	completed, complete := rpc.SubstituteProviderKeys(rpcURL, domains, func(name string) (string, bool) {
+		if value := os.Getenv(name); value != "" {
+			return value, true
+		}
+		if cfg == nil {
+			return "", false
+		}
+		provider, _ := rpc.PlaceholderProvider(name)
+		key := cfg.Providers.Keys[provider].Key
+		return key, key != ""
+	})
+	if completed != rpcURL {
+		keyedURLsMux.Lock()
+		keyedURLs[urls.Normalize(completed)] = urls.Normalize(rpcURL)
+		keyedURLsMux.Unlock()
+	}
+	return completed, complete
+}
+
+// templateURL returns the template an endpoint URL was completed from by substituteKeys,
+// e.g. https://mainnet.infura.io/v3/${INFURA_API_KEY}, and other URLs as they are. URLs
+// are written to the cache and the history and printed to diagnostics in this form, to
+// keep API keys out of them.
+func templateURL(rpcURL string) string {
+	keyedURLsMux.Lock()
+	defer keyedURLsMux.Unlock()
+	if template, ok := keyedURLs[urls.Normalize(rpcURL)]; ok {
+		return template
+	}
+	return rpcURL
+}
+
+// templateURLs is templateURL of every URL
+func templateURLs(rpcURLs []string) []string {
+	templates := make([]string, 0, len(rpcURLs))
+	for _, rpcURL := range rpcURLs {
+		templates = append(templates, templateURL(rpcURL))
+	}
+	return templates
+}
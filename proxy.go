@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/config"
+	"chain-rpc/pkg/proxy"
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/urls"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyListen         string
+	proxyAttempts       int
+	proxyRequestTimeout time.Duration
+	proxyCache          bool
+	proxyNoCoalesce     bool
+	proxyAllowMethods   []string
+	proxyDenyMethods    []string
+	proxyChaos          float64
+	proxyChaosFaults    []string
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy <chainId|chainName>",
+	Short: "Serve a JSON-RPC endpoint forwarding to working endpoints of a chain",
+	Long:  "Serves a JSON-RPC endpoint over HTTP until interrupted, forwarding every request to the working endpoint of the chain picked by --strategy. The endpoints are re-probed in the background every --interval, and a request is sent to another endpoint when one fails.",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The methods of the command line replace the ones of the config file
+		if !cmd.Flags().Changed("allow-method") {
+			proxyAllowMethods = cfg.Proxy.Allow
+		}
+		if !cmd.Flags().Changed("deny-method") {
+			proxyDenyMethods = cfg.Proxy.Deny
+		}
+		for _, patterns := range [][]string{proxyAllowMethods, proxyDenyMethods} {
+			if err := proxy.CheckMethodPatterns(patterns); err != nil {
+				return NewParameterErrorWithCmd(err.Error(), cmd)
+			}
+		}
+		if proxyChaos < 0 || proxyChaos > 1 {
+			return NewParameterErrorWithCmd("chaos must be between 0 and 1", cmd)
+		}
+		if err := proxy.CheckChaosFaults(proxyChaosFaults); err != nil {
+			return NewParameterErrorWithCmd(err.Error(), cmd)
+		}
+		if proxyAttempts <= 0 {
+			return NewParameterErrorWithCmd("attempts must be positive", cmd)
+		}
+		if serveInterval <= 0 {
+			return NewParameterErrorWithCmd("interval must be positive", cmd)
+		}
+		if serveRate <= 0 {
+			return NewParameterErrorWithCmd("rate must be positive", cmd)
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+		strategy, err := selectionStrategy(cmd)
+		if err != nil {
+			return err
+		}
+		if strategy, err = upstreamStrategy(strategy, chainData); err != nil {
+			return err
+		}
+
+		// Requests are forwarded over HTTP
+		var rpcUrls []string
+		for _, rpcURL := range extractRPCUrls(chainData.RPCs, false, httpsOnly) {
+			if !isWebSocketURL(rpcURL) {
+				rpcUrls = append(rpcUrls, rpcURL)
+			}
+		}
+		if len(rpcUrls) == 0 {
+			return fmt.Errorf("no HTTP endpoints found for chain %d", chainData.ChainID)
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		// Background probes would flood the endpoint history
+		tester.OnProbe = nil
+		budgets, err := newBudgetTracker(cmd)
+		if err != nil {
+			return err
+		}
+		if budgets != nil {
+			tester.OnProbe = func(outcome rpc.ProbeOutcome) {
+				budgets.Observe(outcome.URL, outcome.Latency, outcome.OK, outcome.Time)
+			}
+			budgets.OnChange = func(change rpc.BudgetChange) {
+				if change.Demoted {
+					warnf("Demoted %s: %s", templateURL(change.URL), formatBudgetFigures(change))
+				} else {
+					notef("Promoted %s back: %s", templateURL(change.URL), formatBudgetFigures(change))
+				}
+			}
+		}
+		if rpc.UsesScores(strategy) {
+			enableScoring(tester, chainData)
+		}
+
+		pool := rpc.NewPool(tester)
+		pool.Interval = serveInterval
+		pool.Rate = serveRate
+		pool.Add(chainData.ChainID, rpcUrls...)
+
+		server := proxy.New(pool, chainData.ChainID, strategy)
+		server.Attempts = proxyAttempts
+		server.Timeout = proxyRequestTimeout
+		server.Coalesce = !proxyNoCoalesce
+		server.Budgets = budgets
+		server.Allow = proxyAllowMethods
+		server.Deny = proxyDenyMethods
+		if proxyCache {
+			server.Cache = proxy.NewCache(proxy.DefaultCacheTTLs())
+		}
+		server.Chaos = proxyChaos
+		server.ChaosFaults = proxyChaosFaults
+		server.OnChaos = func(fault string, methods []string) {
+			logger.Info("injected fault", "fault", fault, "methods", strings.Join(methods, ","))
+		}
+		server.OnForward = func(rpcURL string, methods []string, latency time.Duration, err error) {
+			if err != nil {
+				logger.Warn("forwarding failed", "url", rpcURL, "methods", strings.Join(methods, ","), "error", err)
+				return
+			}
+			logger.Info("forwarded", "url", rpcURL, "methods", strings.Join(methods, ","), "latency", latency)
+		}
+
+		listener, err := net.Listen("tcp", proxyListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", proxyListen, err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		pool.Start(ctx)
+
+		httpServer := &http.Server{Handler: server}
+		go func() {
+			<-ctx.Done()
+			httpServer.Close()
+		}()
+
+		notef("Proxying chain %d at http://%s, interrupt to stop", chainData.ChainID, listener.Addr())
+		if proxyChaos > 0 {
+			warnf("Failing %g%% of requests on purpose (--chaos)", proxyChaos*100)
+		}
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+// upstreamStrategy makes the strategy pick with the tiers and weights of the proxy
+// section of the config file, if any
+func upstreamStrategy(strategy rpc.Strategy, chainData *chain.ChainData) (rpc.Strategy, error) {
+	prefer, weights := cfg.Proxy.Prefer, cfg.Proxy.Weights
+	if len(prefer) == 0 && len(weights) == 0 {
+		return strategy, nil
+	}
+	names := make([]string, 0, len(weights))
+	for name, weight := range weights {
+		if weight < 0 {
+			return nil, fmt.Errorf("invalid config: proxy weight of %s is negative", name)
+		}
+		names = append(names, name)
+	}
+	// An endpoint matching several names weighs as the first of them
+	sort.Strings(names)
+
+	tags, err := config.LoadTags(tagsPath)
+	if err != nil {
+		return nil, err
+	}
+	// Tags are kept by endpoint URL as listed, without API keys
+	listed := make(map[string]string, len(chainData.RPCs))
+	for _, r := range chainData.RPCs {
+		completed, _ := substituteKeys(r.URL)
+		listed[urls.Normalize(completed)] = r.URL
+	}
+	matches := func(rpcURL, name string) bool {
+		if tags.HasAll(listed[urls.Normalize(rpcURL)], []string{name}) {
+			return true
+		}
+		provider := strings.ToLower(name)
+		domains := cfg.Providers.Keys[provider].Domains
+		if len(domains) == 0 {
+			domains = rpc.PROVIDER_DOMAINS[provider]
+		}
+		if len(domains) == 0 {
+			domains = []string{name}
+		}
+		return matchesProvider(rpcURL, domains)
+	}
+
+	tiered := rpc.TierStrategy{Strategy: strategy, Tier: func(rpcURL string) int {
+		for i, name := range prefer {
+			if matches(rpcURL, name) {
+				return i
+			}
+		}
+		return len(prefer)
+	}}
+	if len(names) > 0 {
+		tiered.Weight = func(rpcURL string) float64 {
+			for _, name := range names {
+				if matches(rpcURL, name) {
+					return weights[name]
+				}
+			}
+			return 1
+		}
+	}
+	return tiered, nil
+}
+
+func init() {
+	proxyCmd.Flags().StringVar(&proxyListen, "listen", "127.0.0.1:8545", "address to serve the endpoint on")
+	proxyCmd.Flags().StringVar(&strategyName, "strategy", "fastest", fmt.Sprintf("how to pick among working endpoints: %s", strings.Join(rpc.StrategyNames(), ", ")))
+	proxyCmd.Flags().IntVar(&proxyAttempts, "attempts", proxy.ATTEMPTS, "number of endpoints a request is tried on before failing")
+	durationVar(proxyCmd.Flags(), &proxyRequestTimeout, "request-timeout", 30*time.Second, "timeout of each forwarded request (0: none)")
+	proxyCmd.Flags().StringSliceVar(&proxyAllowMethods, "allow-method", nil, "only forward these methods, as globs like eth_* (default: all, or allow of the proxy config)")
+	proxyCmd.Flags().StringSliceVar(&proxyDenyMethods, "deny-method", nil, "never forward these methods, as globs like admin_* (default: deny of the proxy config)")
+	proxyCmd.Flags().BoolVar(&proxyNoCoalesce, "no-coalesce", false, "send identical concurrent read calls upstream one by one instead of once")
+	proxyCmd.Flags().BoolVar(&proxyCache, "cache", false, "answer eth_chainId and net_version from the first result, eth_blockNumber and eth_gasPrice from results of the last second")
+	proxyCmd.Flags().Float64Var(&proxyChaos, "chaos", 0, "share of requests, from 0 to 1, answered with a simulated upstream failure instead of being forwarded, to test client retries")
+	proxyCmd.Flags().StringSliceVar(&proxyChaosFaults, "chaos-faults", nil, fmt.Sprintf("simulated failures to pick from: %s (default: all)", strings.Join(proxy.CHAOS_FAULTS, ", ")))
+	durationVar(proxyCmd.Flags(), &serveInterval, "interval", rpc.POOL_INTERVAL, "time between two probes of an endpoint")
+	proxyCmd.Flags().Float64Var(&serveRate, "rate", rpc.POOL_RATE, "maximum probes per second")
+	addBudgetFlags(proxyCmd)
+	proxyCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	durationVarP(proxyCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	proxyCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	proxyCmd.Flags().IntVar(&perHost, "per-host", rpc.DEFAULT_PER_HOST_PROBES, "maximum simultaneous probes to the same host (0: no limit)")
+	proxyCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// endpoints")
+	proxyCmd.Flags().BoolVar(&httpsOnly, "https", false, "keep only HTTPS RPC URLs")
+}
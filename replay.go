@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var recordPath string
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <session.json>",
+	Short: "Re-run the analysis of a recorded test run offline",
+	Long:  "Tests the endpoints of a session recorded with --record against the recorded responses instead of the network, and shows why each endpoint passed or was rejected. Checks and scoring follow the flags given to replay, so they can differ from the recorded run.",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateScoring(cmd, nil); err != nil {
+			return err
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		session, err := rpc.LoadSession(args[0])
+		if err != nil {
+			return err
+		}
+		urls := session.URLs()
+		if len(urls) == 0 {
+			return fmt.Errorf("session %s has no recorded probes", args[0])
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		// Replayed probes are not new observations of the endpoints
+		tester.OnProbe = nil
+		session.RedactURL = templateURL
+		tester.Session = session
+		addCapabilityChecks(tester)
+
+		verdicts := make(map[string]*replayVerdict, len(urls))
+		for _, url := range urls {
+			verdicts[url] = &replayVerdict{URL: url}
+		}
+		tester.Trace = recordVerdicts(tester.Trace, verdicts)
+
+		if minScore > 0 || showScores {
+			// Tracking policies come from the chain data, scores do without them if it is unavailable
+			if chainData, err := getChainData(strconv.FormatUint(session.ChainID, 10)); err == nil {
+				enableScoring(tester, chainData)
+			} else {
+				warnf("scoring without tracking policies: %v", err)
+				enableScoring(tester)
+			}
+		}
+
+		for _, result := range tester.TestRPCs(urls, session.ChainID) {
+			result := result
+			verdicts[result.URL].Result = &result
+		}
+
+		list := make([]replayVerdict, 0, len(urls))
+		for _, url := range urls {
+			verdict := verdicts[url]
+			if verdict.OK && verdict.Result == nil {
+				verdict.OK = false
+				verdict.Reason = fmt.Sprintf("score below --min-score %d", minScore)
+			}
+			list = append(list, *verdict)
+		}
+
+		if asJSON {
+			return printJSON(list)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "URL\tVERDICT\tDETAILS")
+		for _, verdict := range list {
+			if verdict.OK {
+				fmt.Fprintf(w, "%s\tpassed\t%s, %s\n", verdict.URL, formatLatency(verdict.Result.Latency), resultDetails(*verdict.Result))
+			} else {
+				fmt.Fprintf(w, "%s\trejected\t%s\n", verdict.URL, verdict.Reason)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+// replayVerdict is the conclusion of replaying the probe of an endpoint
+type replayVerdict struct {
+	URL string `json:"url"`
+	OK  bool   `json:"ok"`
+	// Reason is why the endpoint was rejected, from its last attempt
+	Reason string         `json:"reason,omitempty"`
+	Result *rpc.RPCResult `json:"result,omitempty"`
+}
+
+// recordVerdicts keeps the outcome of the last attempt of every probe, passing events on to trace
+func recordVerdicts(trace func(rpc.ProbeEvent), verdicts map[string]*replayVerdict) func(rpc.ProbeEvent) {
+	var mu sync.Mutex
+	return func(event rpc.ProbeEvent) {
+		if trace != nil {
+			trace(event)
+		}
+		if event.Stage != rpc.PROBE_FINISHED && event.Stage != rpc.PROBE_CANCELLED {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if verdict, exists := verdicts[event.URL]; exists {
+			verdict.OK = event.OK
+			verdict.Reason = event.Err
+		}
+	}
+}
+
+// validateRecording checks --record, which records the probes of a single test run of a single chain
+func validateRecording(cmd *cobra.Command, identifiers []string) error {
+	if recordPath == "" {
+		return nil
+	}
+	if len(identifiers) > 1 {
+		return NewParameterErrorWithCmd("--record takes a single chain", cmd)
+	}
+	if watchInterval > 0 || useCached || noTest {
+		return NewParameterErrorWithCmd("--record cannot be combined with --watch, --cached or --no-test", cmd)
+	}
+	return nil
+}
+
+// startRecording makes the tester record its probes with --record, including the
+// capabilities and score components that replay analyzes
+func startRecording(tester *rpc.Tester, chainId uint64) {
+	if recordPath == "" {
+		return
+	}
+	tester.Session = rpc.NewSession(chainId)
+	// Session files are meant to be shared, API keys are left out
+	tester.Session.RedactURL = templateURL
+	addCapabilityChecks(tester)
+	if tester.Scorer == nil {
+		tester.Checks = append(tester.Checks, scoringChecks()...)
+	}
+}
+
+// saveRecording writes the probes recorded with --record
+func saveRecording(tester *rpc.Tester) {
+	if tester.Session == nil {
+		return
+	}
+	if err := tester.Session.Save(recordPath); err != nil {
+		warnf("%v", err)
+	}
+}
+
+// addCapabilityChecks probes the node implementation and batch support of endpoints,
+// unless checks probe them already
+func addCapabilityChecks(tester *rpc.Tester) {
+	for _, check := range []rpc.Check{rpc.ClientCheck{}, rpc.BatchCheck{}} {
+		if !hasCheck(tester, check.Name()) {
+			tester.Checks = append(tester.Checks, check)
+		}
+	}
+}
+
+func hasCheck(tester *rpc.Tester, name string) bool {
+	for _, check := range tester.Checks {
+		if check.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	replayCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	durationVarP(replayCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing, recorded responses take as long as they did")
+	replayCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried, with the next recorded responses")
+	replayCmd.Flags().BoolVar(&excludeSyncing, "exclude-syncing", false, "reject endpoints that report through eth_syncing that they are still syncing")
+	replayCmd.Flags().BoolVar(&excludeLimited, "exclude-rate-limited", false, "reject endpoints that throttled the probe, even if a retry passed")
+	replayCmd.Flags().StringSliceVar(&clients, "client", nil, "only pass endpoints running one of these node implementations (e.g. geth,erigon,nethermind,reth)")
+	replayCmd.Flags().StringSliceVar(&requireMethods, "require-methods", nil, "only pass endpoints supporting all of these JSON-RPC methods (e.g. debug_traceTransaction,trace_block)")
+	replayCmd.Flags().BoolVar(&requireBatch, "require-batch", false, "only pass endpoints that answer JSON-RPC batch requests")
+	replayCmd.Flags().BoolVar(&requireSubs, "require-subscriptions", false, "require WebSocket endpoints to have delivered a newHeads notification")
+	replayCmd.Flags().BoolVar(&requireCORS, "cors", false, "only pass endpoints that browser dapps on other origins can call (CORS preflight)")
+	replayCmd.Flags().IntVar(&minScore, "min-score", 0, "reject endpoints scoring lower, from 0 to 100")
+	replayCmd.Flags().BoolVar(&showScores, "scores", false, "score the endpoints that passed")
+	replayCmd.Flags().BoolVar(&traceProbes, "trace-probes", false, "log the lifecycle of every replayed probe to stderr")
+	addOutputFlags(replayCmd)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/config"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	manifestPath string
+	lockOut      string
+	// resolveTimeout is the --timeout of resolve, whose default differs from the root command's
+	resolveTimeout time.Duration
+)
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve --manifest chains.yaml",
+	Short: "Resolve the chains of a project manifest to verified endpoints",
+	Long:  "Reads a project manifest listing the chains a project needs with the constraints of their endpoints (wss, archive, methods, maxLatency, count) and writes a lock file with verified endpoints for each, to commit or feed to deploy tooling",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		manifest, err := config.LoadManifest(manifestPath)
+		if err != nil {
+			return err
+		}
+
+		lock := config.Lock{ResolvedAt: time.Now().UTC().Truncate(time.Second), Chains: make([]config.LockedChain, 0, len(manifest.Chains))}
+		var unresolved []string
+		for _, requirement := range manifest.Chains {
+			locked, err := resolveChain(cmd, requirement)
+			if err != nil {
+				if isParameterError(err) {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "%s: %v\n", requirement.Chain, err)
+				unresolved = append(unresolved, requirement.Chain)
+				continue
+			}
+			lock.Chains = append(lock.Chains, *locked)
+		}
+		// A lock file missing chains would break deployments later rather than now
+		if len(unresolved) > 0 {
+			return fmt.Errorf("could not resolve %s, no lock file written", strings.Join(unresolved, ", "))
+		}
+
+		if lockOut == "" {
+			return printJSON(lock)
+		}
+		data, err := json.MarshalIndent(lock, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize lock file: %v", err)
+		}
+		if err := os.WriteFile(lockOut, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write lock file: %v", err)
+		}
+		notef("Resolved %d chains to %s", len(lock.Chains), lockOut)
+		return nil
+	},
+}
+
+// resolveChain tests the endpoints of the chain against its requirement and keeps
+// the fastest ones meeting it
+func resolveChain(cmd *cobra.Command, requirement config.ChainRequirement) (*config.LockedChain, error) {
+	chainData, err := getChainData(requirement.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	rpcUrls := extractRPCUrls(chainData.RPCs, requirement.WSS, false)
+	if len(rpcUrls) == 0 {
+		return nil, noRPCsError(chainData.RPCs)
+	}
+
+	tester, err := requirementTester(cmd, requirement, resolveTimeout)
+	if err != nil {
+		return nil, err
+	}
+	results := tester.TestRPCs(rpcUrls, chainData.ChainID)
+	reportResults(results...)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Latency < results[j].Latency })
+
+	locked := config.NewLockedChain(requirement, chainData.ChainID, chainData.Name)
+	for _, result := range results {
+		if requirement.MaxLatency > 0 && result.Latency > requirement.MaxLatency {
+			break
+		}
+		if len(locked.Endpoints) == requirement.Count {
+			break
+		}
+		locked.Endpoints = append(locked.Endpoints, config.LockedEndpoint{URL: result.URL, LatencyMs: result.Latency.Milliseconds()})
+	}
+
+	if len(locked.Endpoints) < requirement.Count {
+		return nil, fmt.Errorf("%d of %d required endpoints meet the constraints (%d working)", len(locked.Endpoints), requirement.Count, len(results))
+	}
+	return &locked, nil
+}
+
+// requirementTester returns a tester checking the constraints of the requirement,
+// with warm-up so that latencies compare with its maxLatency
+func requirementTester(cmd *cobra.Command, requirement config.ChainRequirement, timeout time.Duration) (*rpc.Tester, error) {
+	tester, err := newTester(cmd)
+	if err != nil {
+		return nil, err
+	}
+	tester.Timeout = timeout
+	tester.WarmUp = true
+	if requirement.Archive {
+		tester.Checks = append(tester.Checks, rpc.ArchiveCheck{})
+	}
+	if len(requirement.Methods) > 0 {
+		tester.Checks = append(tester.Checks, rpc.MethodsCheck{Methods: requirement.Methods})
+	}
+	return tester, nil
+}
+
+func init() {
+	resolveCmd.Flags().StringVar(&manifestPath, "manifest", "chains.yaml", "project manifest listing the required chains and their constraints")
+	resolveCmd.Flags().StringVar(&lockOut, "out", "", "lock file to write (default: stdout)")
+	resolveCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	resolveCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(resolveCmd.Flags(), &resolveTimeout, "timeout", "t", time.Second, "timeout for RPC testing")
+	resolveCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	resolveCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+}
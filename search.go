@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+var searchLimit int
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Fuzzy search chains by name",
+	Long:  "Ranks chains by similarity of their names, short names and slugs to the query and prints the best candidates with their IDs",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		keep, err := networkFilter(cmd)
+		if err != nil {
+			return err
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		results, err := chain.SearchChainsWhere(args[0], searchLimit, keep)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(struct {
+				Results []chain.SearchResult `json:"results"`
+				Cache   *chain.CacheStatus   `json:"cache,omitempty"`
+			}{results, cacheStatusForOutput()})
+		}
+
+		if len(results) == 0 {
+			return fmt.Errorf("no chains similar to '%s'", args[0])
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tMATCH\tSCORE")
+		for _, result := range results {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%.2f\n", result.ChainID, result.Name, result.Match, result.Score)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 10, "maximum number of results (0 for all)")
+	addNetworkFlags(searchCmd)
+	searchCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	searchCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(searchCmd)
+}
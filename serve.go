@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/urls"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// SERVE_SOCKET_NAME is the unix socket of the serve daemon, in the cache directory
+	SERVE_SOCKET_NAME = "serve.sock"
+	// DAEMON_TIMEOUT bounds asking the serve daemon, invocations probe themselves past it
+	DAEMON_TIMEOUT = time.Second
+)
+
+var (
+	serveSocket   string
+	serveListen   string
+	serveInterval time.Duration
+	serveRate     float64
+	noDaemon      bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [chainId|chainName]...",
+	Short: "Keep endpoints verified in the background for other invocations",
+	Long:  "Runs until interrupted, re-probing the endpoints of chains in the background every --interval. The chains given as arguments are probed from the start, others once they are asked for. While it runs, chain-rpc and chain-rpc all ask it over a unix socket for the endpoints it verified instead of probing them, and local tools can ask it for the best endpoint of a chain",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Nobody is there to answer a prompt
+		noInteractive = true
+		if serveInterval <= 0 {
+			return NewParameterErrorWithCmd("interval must be positive", cmd)
+		}
+		if serveRate <= 0 {
+			return NewParameterErrorWithCmd("rate must be positive", cmd)
+		}
+
+		tester, err := newTester(cmd)
+		if err != nil {
+			return err
+		}
+		// Background probes would flood the endpoint history
+		tester.OnProbe = nil
+
+		pool := rpc.NewPool(tester)
+		pool.Interval = serveInterval
+		pool.Rate = serveRate
+		server := &daemon{pool: pool, chains: make(map[uint64]bool)}
+		for _, identifier := range args {
+			chainData, err := getChainData(identifier)
+			if err != nil {
+				return err
+			}
+			server.add(chainData)
+		}
+
+		var keys []*serveKey
+		if serveListen != "" {
+			if keys, err = loadServeKeys(); err != nil {
+				return err
+			}
+			if len(keys) == 0 {
+				return NewParameterErrorWithCmd("--listen needs API keys, in the serve section of the config file", cmd)
+			}
+		}
+
+		path := serveSocketPath()
+		listener, err := listenSocket(path)
+		if err != nil {
+			return err
+		}
+		servers := []*http.Server{{Handler: server}}
+		listeners := []net.Listener{listener}
+		if serveListen != "" {
+			tcpListener, err := net.Listen("tcp", serveListen)
+			if err != nil {
+				listener.Close()
+				return fmt.Errorf("failed to listen on %s: %v", serveListen, err)
+			}
+			servers = append(servers, &http.Server{Handler: &keyAuth{keys: keys, next: server}})
+			listeners = append(listeners, tcpListener)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		pool.Start(ctx)
+
+		go func() {
+			<-ctx.Done()
+			for _, httpServer := range servers {
+				httpServer.Close()
+			}
+		}()
+
+		if serveListen != "" {
+			notef("Serving verified endpoints at %s, and to API keys at %s, interrupt to stop", path, listeners[1].Addr())
+		} else {
+			notef("Serving verified endpoints at %s, interrupt to stop", path)
+		}
+		errs := make(chan error, len(servers))
+		for i, httpServer := range servers {
+			go func(httpServer *http.Server, listener net.Listener) {
+				errs <- httpServer.Serve(listener)
+			}(httpServer, listeners[i])
+		}
+		for range servers {
+			if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+				stop()
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// daemon answers with the endpoints its pool keeps verified:
+//
+//	GET /v1/chains/<chainId|chainName>/rpcs
+//
+// lists them as rpc.RPCResult objects, and
+//
+//	GET /v1/chains/<chainId|chainName>/best[?strategy=<name>]
+//
+// returns the URL of the one picked by the strategy (fastest by default) as a line of
+// text, for shell prompts and editors. Until every endpoint of a chain was probed once,
+// rpcs answers 503 Service Unavailable and best waits for a working one.
+//
+// Requests authenticated by keyAuth only get the chains of their key, and endpoints
+// as templates, not to hand the API keys of the operator over.
+type daemon struct {
+	pool *rpc.Pool
+
+	mu     sync.Mutex
+	chains map[uint64]bool
+}
+
+// add puts the endpoints of the chain in the pool, once
+func (d *daemon) add(chainData *chain.ChainData) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.chains[chainData.ChainID] {
+		return
+	}
+	d.chains[chainData.ChainID] = true
+	d.pool.Add(chainData.ChainID, extractRPCUrls(chainData.RPCs, wsOnly, httpsOnly)...)
+}
+
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	identifier, found := strings.CutPrefix(r.URL.Path, "/v1/chains/")
+	identifier, resource, _ := strings.Cut(identifier, "/")
+	if !found || identifier == "" || (resource != "rpcs" && resource != "best") {
+		writeDaemonError(w, http.StatusNotFound, fmt.Errorf("not found, expected /v1/chains/<chainId|chainName>/rpcs or /best"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeDaemonError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	chainData, err := getChainData(identifier)
+	if errors.Is(err, chain.ErrChainNotFound) || errors.Is(err, chain.ErrAmbiguousName) {
+		writeDaemonError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeDaemonError(w, http.StatusInternalServerError, err)
+		return
+	}
+	key := requestKey(r)
+	if key != nil && !key.allows(chainData.ChainID) {
+		writeDaemonError(w, http.StatusForbidden, fmt.Errorf("API key %s may not ask for chain %d", key.name, chainData.ChainID))
+		return
+	}
+	d.add(chainData)
+
+	if resource == "best" {
+		d.serveBest(w, r, chainData.ChainID)
+		return
+	}
+	if !d.pool.Probed(chainData.ChainID) {
+		writeDaemonError(w, http.StatusServiceUnavailable, fmt.Errorf("the endpoints of chain %d are still being probed", chainData.ChainID))
+		return
+	}
+	results := d.pool.Results(chainData.ChainID)
+	if results == nil {
+		results = []rpc.RPCResult{}
+	}
+	if key != nil {
+		for i := range results {
+			results[i].URL = templateURL(results[i].URL)
+		}
+	}
+	writeDaemonJSON(w, http.StatusOK, results)
+}
+
+// serveBest writes the endpoint of the chain picked by the strategy of the request
+func (d *daemon) serveBest(w http.ResponseWriter, r *http.Request, chainId uint64) {
+	name := r.URL.Query().Get("strategy")
+	if name == "" {
+		name = "fastest"
+	}
+	strategy, err := rpc.StrategyByName(name)
+	if err != nil {
+		writeDaemonError(w, http.StatusBadRequest, fmt.Errorf("%v, expected one of: %s", err, strings.Join(rpc.StrategyNames(), ", ")))
+		return
+	}
+	if rpc.UsesScores(strategy) {
+		writeDaemonError(w, http.StatusBadRequest, fmt.Errorf("strategy '%s' needs scores, which the daemon does not compute", name))
+		return
+	}
+	if len(preferProviders) > 0 {
+		strategy = rpc.PreferStrategy{Strategy: strategy, Preferred: isPreferredProvider}
+	}
+
+	result, err := d.pool.Pick(r.Context(), chainId, strategy)
+	if errors.Is(err, rpc.ErrNoRPCsFound) {
+		writeDaemonError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		// The client went away
+		return
+	}
+	if requestKey(r) != nil {
+		result.URL = templateURL(result.URL)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, result.URL)
+}
+
+// serveKey is an API key of the serve daemon with the requests it has left
+type serveKey struct {
+	name   string
+	key    string
+	chains map[uint64]bool
+	rate   float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allows reports whether the key may ask for the chain
+func (k *serveKey) allows(chainId uint64) bool {
+	return len(k.chains) == 0 || k.chains[chainId]
+}
+
+// take uses up a request of the key, unless it is over its rate. Bursts of up to a
+// second of requests are let through.
+func (k *serveKey) take(now time.Time) bool {
+	if k.rate <= 0 {
+		return true
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	burst := math.Max(k.rate, 1)
+	if k.last.IsZero() {
+		k.tokens = burst
+	} else {
+		k.tokens = math.Min(burst, k.tokens+now.Sub(k.last).Seconds()*k.rate)
+	}
+	k.last = now
+	if k.tokens < 1 {
+		return false
+	}
+	k.tokens--
+	return true
+}
+
+// loadServeKeys reads the API keys of the config file, completing them from the
+// environment variables they reference
+func loadServeKeys() ([]*serveKey, error) {
+	keys := make([]*serveKey, 0, len(cfg.Serve.Keys))
+	for i, configKey := range cfg.Serve.Keys {
+		name := configKey.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		value, complete := rpc.SubstitutePlaceholders(configKey.Key, os.LookupEnv)
+		if !complete {
+			return nil, fmt.Errorf("API key %s references an unset environment variable", name)
+		}
+		if strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid config: API key %s is empty", name)
+		}
+		key := &serveKey{name: name, key: value, rate: configKey.Rate}
+		if len(configKey.Chains) > 0 {
+			key.chains = make(map[uint64]bool, len(configKey.Chains))
+			for _, chainId := range configKey.Chains {
+				key.chains[chainId] = true
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+type serveKeyContext struct{}
+
+// requestKey returns the API key the request was authenticated with, nil for requests
+// on the unix socket
+func requestKey(r *http.Request) *serveKey {
+	key, _ := r.Context().Value(serveKeyContext{}).(*serveKey)
+	return key
+}
+
+// keyAuth lets the requests carrying one of its keys, in an "Authorization: Bearer"
+// or an X-API-Key header, through to next at the rate of the key
+type keyAuth struct {
+	keys []*serveKey
+	next http.Handler
+}
+
+func (a *keyAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	value := r.Header.Get("X-API-Key")
+	if bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		value = bearer
+	}
+
+	var key *serveKey
+	for _, candidate := range a.keys {
+		if value != "" && subtle.ConstantTimeCompare([]byte(value), []byte(candidate.key)) == 1 {
+			key = candidate
+			break
+		}
+	}
+	if key == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeDaemonError(w, http.StatusUnauthorized, fmt.Errorf("missing or unknown API key"))
+		return
+	}
+	if !key.take(time.Now()) {
+		w.Header().Set("Retry-After", "1")
+		writeDaemonError(w, http.StatusTooManyRequests, fmt.Errorf("API key %s is over its rate of %g requests per second", key.name, key.rate))
+		return
+	}
+	a.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), serveKeyContext{}, key)))
+}
+
+func writeDaemonJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeDaemonError(w http.ResponseWriter, status int, err error) {
+	writeDaemonJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// serveSocketPath returns the unix socket of the serve daemon: --socket, or
+// CHAIN_RPC_SOCKET, or serve.sock in the cache directory
+func serveSocketPath() string {
+	if serveSocket != "" {
+		return serveSocket
+	}
+	return filepath.Join(chain.CacheDir(), SERVE_SOCKET_NAME)
+}
+
+// listenSocket listens on the unix socket at path, replacing the one a daemon that is
+// gone left behind. Only the current user may connect.
+func listenSocket(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, DAEMON_TIMEOUT); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a serve daemon is already listening on %s", path)
+	}
+	os.Remove(path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %v", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict access to %s: %v", path, err)
+	}
+	return listener, nil
+}
+
+// canAskDaemon reports whether the verdicts of a serve daemon answer this invocation.
+// The daemon verifies chain IDs only, so runs with checks, scores or a recording probe
+// the endpoints themselves.
+func canAskDaemon(strategy rpc.Strategy) bool {
+	if noDaemon || recordPath != "" || minScore > 0 || showScores {
+		return false
+	}
+	if strategy != nil && rpc.UsesScores(strategy) {
+		return false
+	}
+	return !excludeSyncing && !excludeLimited && len(clients) == 0 && len(requireMethods) == 0 && !requireBatch && !requireSubs && !requireCORS
+}
+
+// daemonRPCs asks a running serve daemon for the endpoints of the chain it verified,
+// among rpcUrls. Without a daemon, or when it cannot answer yet, there are none and
+// the caller probes the endpoints itself.
+func daemonRPCs(chainId uint64, rpcUrls []string) []rpc.RPCResult {
+	path := serveSocketPath()
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	client := &http.Client{
+		Timeout: DAEMON_TIMEOUT,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	resp, err := client.Get(fmt.Sprintf("http://chain-rpc/v1/chains/%d/rpcs", chainId))
+	if err != nil {
+		logger.Info("serve daemon unavailable", "socket", path, "error", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var answer struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&answer)
+		logger.Info("serve daemon cannot answer", "socket", path, "status", resp.StatusCode, "error", answer.Error)
+		return nil
+	}
+	var results []rpc.RPCResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		logger.Info("invalid answer of the serve daemon", "socket", path, "error", err)
+		return nil
+	}
+
+	// The filters of this invocation apply
+	wanted := make(map[string]bool, len(rpcUrls))
+	for _, rpcURL := range rpcUrls {
+		wanted[urls.Normalize(rpcURL)] = true
+	}
+	verified := make([]rpc.RPCResult, 0, len(results))
+	for _, result := range results {
+		if wanted[urls.Normalize(result.URL)] {
+			verified = append(verified, result)
+		}
+	}
+	logger.Info("using endpoints verified by the serve daemon", "socket", path, "count", len(verified))
+	return verified
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "", "also answer on this TCP address, e.g. :8080, to the API keys of the serve section of the config file")
+	serveCmd.Flags().StringVar(&serveSocket, "socket", os.Getenv("CHAIN_RPC_SOCKET"), "unix socket to answer on (default: serve.sock in the cache directory; env CHAIN_RPC_SOCKET)")
+	durationVar(serveCmd.Flags(), &serveInterval, "interval", rpc.POOL_INTERVAL, "time between two probes of an endpoint")
+	serveCmd.Flags().Float64Var(&serveRate, "rate", rpc.POOL_RATE, "maximum probes per second over all chains")
+	serveCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	durationVarP(serveCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	serveCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	serveCmd.Flags().IntVar(&perHost, "per-host", rpc.DEFAULT_PER_HOST_PROBES, "maximum simultaneous probes to the same host (0: no limit)")
+	serveCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+	serveCmd.Flags().BoolVar(&wsOnly, "wss", false, "keep only WebSocket RPC URLs")
+	serveCmd.Flags().BoolVar(&httpsOnly, "https", false, "keep only HTTPS RPC URLs")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	sloTarget string
+	sloWindow time.Duration
+	sloPeriod time.Duration
+)
+
+var sloCmd = &cobra.Command{
+	Use:   "slo <chainId|chainName> --target 99.5%",
+	Short: "Check endpoint availability against a target",
+	Long:  "Reports, from the probes of past runs, whether every endpoint of the chain met the availability target over the window, and the periods of the window in which it did not",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		target, err := parsePercent(sloTarget)
+		if err != nil {
+			return NewParameterErrorWithCmd(fmt.Sprintf("invalid --target: %v", err), cmd)
+		}
+		if sloWindow <= 0 || sloWindow > chain.HISTORY_TTL {
+			return NewParameterErrorWithCmd(fmt.Sprintf("--window must be positive and at most %s, as long as the history is kept", chain.HISTORY_TTL), cmd)
+		}
+		if sloPeriod <= 0 || sloPeriod > sloWindow {
+			return NewParameterErrorWithCmd("--period must be positive and at most --window", cmd)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		records, err := chain.LoadProbeHistory(chainData.ChainID)
+		if err != nil {
+			return err
+		}
+		slos := chain.EvaluateSLO(records, target, sloWindow, sloPeriod, time.Now())
+
+		if asJSON {
+			return printJSON(slos)
+		}
+		if len(slos) == 0 {
+			return fmt.Errorf("no probes of %s recorded in the last %s", chainData.Name, formatDuration(sloWindow))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "URL\tAVAILABILITY\tPROBES\tSLO\tVIOLATIONS")
+		for _, slo := range slos {
+			verdict := "met"
+			if !slo.Met {
+				verdict = "missed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", slo.URL, formatUptime(slo.Availability), formatCount(slo.Probes), verdict, formatViolations(slo.Violations))
+		}
+		return w.Flush()
+	},
+}
+
+// parsePercent parses a percentage such as "99.5%" or "99.5" into a share from 0 to 1
+func parsePercent(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a percentage", value)
+	}
+	if percent <= 0 || percent > 100 {
+		return 0, fmt.Errorf("%q is not between 0%% and 100%%", value)
+	}
+	return percent / 100, nil
+}
+
+func formatViolations(violations []chain.SLOViolation) string {
+	if len(violations) == 0 {
+		return "-"
+	}
+	periods := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		start, end := violation.Start.Local(), violation.End.Local()
+		endLayout := "15:04"
+		if end.YearDay() != start.YearDay() {
+			endLayout = "Jan 2 15:04"
+		}
+		periods = append(periods, fmt.Sprintf("%s-%s (%s)", start.Format("Jan 2 15:04"), end.Format(endLayout), formatUptime(violation.Availability)))
+	}
+	return strings.Join(periods, ", ")
+}
+
+func init() {
+	sloCmd.Flags().StringVar(&sloTarget, "target", "99.5%", "availability target, the share of probes that must pass")
+	durationVar(sloCmd.Flags(), &sloWindow, "window", chain.HISTORY_TTL, "period over which the target must be met, up to the week the history is kept")
+	durationVar(sloCmd.Flags(), &sloPeriod, "period", 24*time.Hour, "length of the periods checked for violations within the window")
+	sloCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	sloCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(sloCmd)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+	"chain-rpc/pkg/snippet"
+
+	"github.com/spf13/cobra"
+)
+
+var snippetFormat string
+
+var configSnippetCmd = &cobra.Command{
+	Use:   "config <chainId|chainName>",
+	Short: "Print a chain configuration for Foundry, Hardhat or viem",
+	Long:  fmt.Sprintf("Prints a ready-to-paste configuration of the chain with a tested endpoint and the chain's metadata: an [rpc_endpoints] block for foundry.toml, a networks entry for hardhat.config, or a viem defineChain object. Formats: %s", strings.Join(snippet.Formats(), ", ")),
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if _, err := snippet.Render(snippetFormat, snippet.Chain{}); err != nil {
+			return NewParameterErrorWithCmd(fmt.Sprintf("%v, expected one of: %s", err, strings.Join(snippet.Formats(), ", ")), cmd)
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+
+		rpcUrls := extractRPCUrls(chainData.RPCs, false, false)
+		if len(rpcUrls) == 0 {
+			return noRPCsError(chainData.RPCs)
+		}
+
+		var httpResult, wsResult rpc.RPCResult
+		if noTest {
+			httpResult, wsResult = rpc.PickPair(rpc.FirstStrategy{}, urlResults(rpcUrls))
+		} else {
+			strategy, err := selectionStrategy(cmd)
+			if err != nil {
+				return err
+			}
+			tester, err := newTester(cmd)
+			if err != nil {
+				return err
+			}
+			if _, ok := rpc.UnwrapStrategy(strategy).(rpc.FastestStrategy); ok {
+				tester.WarmUp = true
+			}
+			if rpc.UsesScores(strategy) {
+				enableScoring(tester, chainData)
+			}
+
+			if httpResult, wsResult, err = rpc.NewSelector(tester, strategy).SelectPair(rpcUrls, chainData.ChainID); err != nil {
+				return err
+			}
+			for _, result := range []rpc.RPCResult{httpResult, wsResult} {
+				if result.URL != "" {
+					reportResults(result)
+				}
+			}
+		}
+
+		// Tools connect over WebSocket too, when that is all that works
+		rpcURL := httpResult.URL
+		if rpcURL == "" {
+			rpcURL = wsResult.URL
+		}
+		warnInsecure(rpcURL)
+
+		output, err := snippet.Render(snippetFormat, snippetChain(chainData, rpcURL, wsResult.URL))
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+// snippetChain collects what configuration snippets need to know about the chain
+func snippetChain(chainData *chain.ChainData, rpcURL, wsURL string) snippet.Chain {
+	key := chainData.ChainSlug
+	if key == "" {
+		key = chainData.ShortName
+	}
+	if key == "" {
+		key = chainData.Name
+	}
+
+	snippetChain := snippet.Chain{
+		ID:             chainData.ChainID,
+		Name:           chainData.Name,
+		Key:            snippet.Key(key),
+		RPCURL:         rpcURL,
+		WSURL:          wsURL,
+		CurrencyName:   chainData.NativeCurrency.Name,
+		CurrencySymbol: chainData.NativeCurrency.Symbol,
+		Decimals:       chainData.NativeCurrency.Decimals,
+		Testnet:        chain.IsTestnet(chainData),
+	}
+	if len(chainData.Explorers) > 0 {
+		snippetChain.ExplorerName = chainData.Explorers[0].Name
+		snippetChain.ExplorerURL = chainData.Explorers[0].URL
+	}
+	return snippetChain
+}
+
+func init() {
+	configSnippetCmd.Flags().StringVar(&snippetFormat, "format", "foundry", fmt.Sprintf("configuration format: %s", strings.Join(snippet.Formats(), ", ")))
+	configSnippetCmd.Flags().BoolVar(&noTest, "no-test", false, "use the first listed endpoint without testing it")
+	configSnippetCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	configSnippetCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(configSnippetCmd.Flags(), &timeout, "timeout", "t", 200*time.Millisecond, "timeout for RPC testing")
+	configSnippetCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	configSnippetCmd.Flags().StringVar(&strategyName, "strategy", "random", fmt.Sprintf("how to pick among working endpoints: %s", strings.Join(rpc.StrategyNames(), ", ")))
+	configSnippetCmd.Flags().BoolVar(&fastest, "fastest", false, "shorthand for --strategy fastest")
+	configSnippetCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// endpoints")
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+const DEFAULT_TOP_PROVIDERS = 10
+
+var topProviders int
+
+// datasetStats summarizes the cached chain dataset
+type datasetStats struct {
+	Chains          int             `json:"chains"`
+	ChainsWithHTTPS int             `json:"chainsWithHttps"`
+	ChainsWithWSS   int             `json:"chainsWithWss"`
+	Endpoints       int             `json:"endpoints"`
+	MedianEndpoints float64         `json:"medianEndpoints"`
+	TopProviders    []providerCount `json:"topProviders"`
+	// LintIssues counts the endpoint URLs flagged with each issue
+	LintIssues     map[rpc.LintIssue]int `json:"lintIssues"`
+	Cache          *chain.CacheStatus    `json:"cache,omitempty"`
+	endpointCounts []int
+	providers      map[string]int
+}
+
+// providerCount is the number of endpoint URLs served from a provider domain
+type providerCount struct {
+	Provider string `json:"provider"`
+	URLs     int    `json:"urls"`
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the chain dataset",
+	Long:  "Prints statistics of the cached chain dataset: total chains, chains with at least one HTTPS or WSS endpoint, the median number of endpoints per chain, endpoint URLs flagged by linting and the providers serving the most endpoint URLs",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		if topProviders < 0 {
+			return NewParameterErrorWithCmd("--top must not be negative", cmd)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		stats := &datasetStats{LintIssues: make(map[rpc.LintIssue]int), providers: make(map[string]int)}
+		if err := chain.ForEachChain(func(chainData *chain.ChainData) error {
+			stats.add(chainData)
+			return nil
+		}); err != nil {
+			return err
+		}
+		stats.finish(topProviders)
+
+		if asJSON {
+			stats.Cache = cacheStatusForOutput()
+			return printJSON(stats)
+		}
+
+		printStats(stats)
+		return nil
+	},
+}
+
+func (s *datasetStats) add(chainData *chain.ChainData) {
+	s.Chains++
+	s.Endpoints += len(chainData.RPCs)
+	s.endpointCounts = append(s.endpointCounts, len(chainData.RPCs))
+
+	hasHTTPS, hasWSS := false, false
+	for _, endpoint := range chainData.RPCs {
+		for _, issue := range rpc.LintURL(endpoint.URL) {
+			s.LintIssues[issue]++
+		}
+
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			hasHTTPS = true
+		case "wss":
+			hasWSS = true
+		}
+
+		if provider := providerDomain(u.Hostname()); provider != "" {
+			s.providers[provider]++
+		}
+	}
+
+	if hasHTTPS {
+		s.ChainsWithHTTPS++
+	}
+	if hasWSS {
+		s.ChainsWithWSS++
+	}
+}
+
+// finish computes the median and the top providers once all chains are added
+func (s *datasetStats) finish(top int) {
+	s.MedianEndpoints = median(s.endpointCounts)
+
+	s.TopProviders = make([]providerCount, 0, len(s.providers))
+	for provider, count := range s.providers {
+		s.TopProviders = append(s.TopProviders, providerCount{Provider: provider, URLs: count})
+	}
+	sort.Slice(s.TopProviders, func(i, j int) bool {
+		if s.TopProviders[i].URLs != s.TopProviders[j].URLs {
+			return s.TopProviders[i].URLs > s.TopProviders[j].URLs
+		}
+		return s.TopProviders[i].Provider < s.TopProviders[j].Provider
+	})
+	if len(s.TopProviders) > top {
+		s.TopProviders = s.TopProviders[:top]
+	}
+}
+
+func median[T int | int64 | time.Duration](values []T) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]T(nil), values...)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return float64(sorted[mid])
+	}
+	return float64(sorted[mid-1]+sorted[mid]) / 2
+}
+
+// providerDomain reduces a host name to the provider domain, e.g.
+// eth-mainnet.g.alchemy.com -> alchemy.com. IP addresses are kept as is.
+func providerDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || strings.Contains(host, ":") || strings.Trim(host, "0123456789.") == "" {
+		return host
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+func printStats(stats *datasetStats) {
+	percent := func(n int) float64 {
+		if stats.Chains == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(stats.Chains)
+	}
+
+	// The median of an even number of chains can fall between two counts
+	medianDecimals := 0
+	if stats.MedianEndpoints != math.Trunc(stats.MedianEndpoints) {
+		medianDecimals = 1
+	}
+
+	fmt.Printf("Chains:              %s\n", formatCount(stats.Chains))
+	fmt.Printf("With HTTPS endpoint: %s (%s%%)\n", formatCount(stats.ChainsWithHTTPS), formatDecimal(percent(stats.ChainsWithHTTPS), 1))
+	fmt.Printf("With WSS endpoint:   %s (%s%%)\n", formatCount(stats.ChainsWithWSS), formatDecimal(percent(stats.ChainsWithWSS), 1))
+	fmt.Printf("Endpoints:           %s\n", formatCount(stats.Endpoints))
+	fmt.Printf("Median per chain:    %s\n", formatDecimal(stats.MedianEndpoints, medianDecimals))
+	fmt.Println("Flagged endpoints:")
+	for _, issue := range rpc.LintIssues {
+		fmt.Printf("  %-12s %s\n", issue, formatCount(stats.LintIssues[issue]))
+	}
+
+	if len(stats.TopProviders) == 0 {
+		return
+	}
+	fmt.Println("Top providers:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, provider := range stats.TopProviders {
+		fmt.Fprintf(w, "  %s\t%s\n", provider.Provider, formatCount(provider.URLs))
+	}
+	w.Flush()
+}
+
+func init() {
+	statsCmd.Flags().IntVarP(&topProviders, "top", "n", DEFAULT_TOP_PROVIDERS, "number of top providers to show")
+	statsCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	statsCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(statsCmd)
+}
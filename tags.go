@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"chain-rpc/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tagsPath     string
+	filterTags   []string
+	endpointTags config.Tags
+)
+
+// tagPattern keeps tags usable in the comma-separated --tag list
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]*$`)
+
+// taggedEndpoint is an endpoint with its user tags
+type taggedEndpoint struct {
+	URL  string   `json:"url"`
+	Tags []string `json:"tags"`
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Manage endpoint tags",
+	Long:  "Commands to tag endpoints, e.g. trusted, archive or eu, for filtering any command with --tag. Tags are stored locally, next to the config file.",
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <url> <tag>...",
+	Short: "Tag an endpoint",
+	Long:  "Adds tags to an endpoint",
+	Args:  minimumArgsWithParameterError(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateTagArgs(cmd, args[0], args[1:]); err != nil {
+			return err
+		}
+
+		tags, err := config.LoadTags(tagsPath)
+		if err != nil {
+			return err
+		}
+		tags.Add(args[0], args[1:]...)
+		if err := tags.Save(tagsPath); err != nil {
+			return err
+		}
+
+		notef("Tagged %s: %s", args[0], strings.Join(tags[args[0]], ", "))
+		return nil
+	},
+}
+
+var tagRemoveCmd = &cobra.Command{
+	Use:   "remove <url> [tag...]",
+	Short: "Remove tags of an endpoint",
+	Long:  "Removes the given tags of an endpoint, or all of its tags when none are given",
+	Args:  minimumArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tags, err := config.LoadTags(tagsPath)
+		if err != nil {
+			return err
+		}
+		if _, exists := tags[args[0]]; !exists {
+			return fmt.Errorf("%s has no tags", args[0])
+		}
+
+		tags.Remove(args[0], args[1:]...)
+		if err := tags.Save(tagsPath); err != nil {
+			return err
+		}
+
+		if remaining := tags[args[0]]; len(remaining) > 0 {
+			notef("Tagged %s: %s", args[0], strings.Join(remaining, ", "))
+		} else {
+			notef("Removed the tags of %s", args[0])
+		}
+		return nil
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list [tag...]",
+	Short: "List tagged endpoints",
+	Long:  "Lists the tagged endpoints with their tags, only those with all of the given tags if any",
+	Args:  cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		tags, err := config.LoadTags(tagsPath)
+		if err != nil {
+			return err
+		}
+
+		endpoints := make([]taggedEndpoint, 0, len(tags))
+		for url, endpointTags := range tags {
+			if tags.HasAll(url, args) {
+				endpoints = append(endpoints, taggedEndpoint{URL: url, Tags: endpointTags})
+			}
+		}
+		sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].URL < endpoints[j].URL })
+
+		if asJSON {
+			return printJSON(endpoints)
+		}
+		if len(endpoints) == 0 {
+			return fmt.Errorf("no tagged endpoints")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "URL\tTAGS")
+		for _, endpoint := range endpoints {
+			fmt.Fprintf(w, "%s\t%s\n", endpoint.URL, strings.Join(endpoint.Tags, ", "))
+		}
+		return w.Flush()
+	},
+}
+
+func validateTagArgs(cmd *cobra.Command, rpcURL string, tags []string) error {
+	if u, err := url.Parse(rpcURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return NewParameterErrorWithCmd(fmt.Sprintf("invalid endpoint URL %q", rpcURL), cmd)
+	}
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return NewParameterErrorWithCmd(fmt.Sprintf("invalid tag %q: letters, digits, '_', '.', ':' and '-' only, starting with a letter or digit", tag), cmd)
+		}
+	}
+	return nil
+}
+
+// loadFilterTags reads the endpoint tags when endpoints are filtered by tag
+func loadFilterTags() error {
+	if len(filterTags) == 0 {
+		return nil
+	}
+	var err error
+	endpointTags, err = config.LoadTags(tagsPath)
+	return err
+}
+
+// hasFilterTags reports whether the endpoint has all the tags given with --tag
+func hasFilterTags(rpcURL string) bool {
+	return len(filterTags) == 0 || endpointTags.HasAll(rpcURL, filterTags)
+}
+
+func init() {
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRemoveCmd)
+	tagCmd.AddCommand(tagListCmd)
+	addOutputFlags(tagListCmd)
+}
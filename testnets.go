@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+var testnetsCmd = &cobra.Command{
+	Use:   "testnets <chainId|chainName>",
+	Short: "List the test networks of a main network",
+	Long:  "Lists the test networks related to a main network, guessed from their names, e.g. Sepolia and Holesky for Ethereum, or Base Sepolia for Base",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(args[0])
+		if err != nil {
+			return err
+		}
+		if chain.IsTestnet(chainData) {
+			return NewParameterErrorWithCmd(fmt.Sprintf("%s (%d) is a test network, expected a main network", chainData.Name, chainData.ChainID), cmd)
+		}
+
+		testnets, err := chain.RelatedTestnets(chainData)
+		if err != nil {
+			return err
+		}
+
+		chains := make([]chainSummary, 0, len(testnets))
+		for _, testnet := range testnets {
+			chains = append(chains, chainSummary{
+				ChainID: testnet.ChainID,
+				Name:    testnet.Name,
+				Symbol:  testnet.NativeCurrency.Symbol,
+				Testnet: true,
+			})
+		}
+
+		if asJSON {
+			return printJSON(struct {
+				Mainnet chainSummary       `json:"mainnet"`
+				Chains  []chainSummary     `json:"chains"`
+				Cache   *chain.CacheStatus `json:"cache,omitempty"`
+			}{chainSummary{ChainID: chainData.ChainID, Name: chainData.Name, Symbol: chainData.NativeCurrency.Symbol}, chains, cacheStatusForOutput()})
+		}
+		if len(chains) == 0 {
+			return fmt.Errorf("no known test networks of %s", chainData.Name)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSYMBOL")
+		for _, c := range chains {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", c.ChainID, c.Name, c.Symbol)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	testnetsCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	testnetsCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	addOutputFlags(testnetsCmd)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"chain-rpc/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	lockPath string
+	// verifyTimeout is the --timeout of verify, whose default differs from the root command's
+	verifyTimeout time.Duration
+)
+
+// verification is the outcome of re-testing a locked endpoint
+type verification struct {
+	Chain     string `json:"chain"`
+	URL       string `json:"url"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latencyMs"`
+	// Problem is why the endpoint no longer meets the constraints of its chain
+	Problem string `json:"problem,omitempty"`
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify --lock chains.lock.json",
+	Short: "Re-test the endpoints of a lock file",
+	Long:  "Re-tests every endpoint recorded in a lock file written by resolve against the constraints of its chain, and fails if any of them no longer meets them, to catch endpoint rot in CI before deployments",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+		lock, err := config.ReadLock(lockPath)
+		if err != nil {
+			return err
+		}
+
+		var verifications []verification
+		for _, locked := range lock.Chains {
+			chainVerifications, err := verifyChain(cmd, locked)
+			if err != nil {
+				return err
+			}
+			verifications = append(verifications, chainVerifications...)
+		}
+
+		failed := 0
+		for _, v := range verifications {
+			if !v.OK {
+				failed++
+			}
+		}
+
+		if asJSON {
+			if err := printJSON(verifications); err != nil {
+				return err
+			}
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CHAIN\tURL\tSTATUS")
+			for _, v := range verifications {
+				status := fmt.Sprintf("ok (%s)", formatLatency(msDuration(v.LatencyMs)))
+				if !v.OK {
+					status = v.Problem
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", v.Chain, v.URL, status)
+			}
+			w.Flush()
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d locked endpoints no longer meet their constraints", failed, len(verifications))
+		}
+		return nil
+	},
+}
+
+// verifyChain re-tests the endpoints of a locked chain against its constraints
+func verifyChain(cmd *cobra.Command, locked config.LockedChain) ([]verification, error) {
+	requirement := locked.Requirement()
+	tester, err := requirementTester(cmd, requirement, verifyTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	rpcUrls := make([]string, 0, len(locked.Endpoints))
+	for _, endpoint := range locked.Endpoints {
+		rpcUrls = append(rpcUrls, endpoint.URL)
+	}
+	results := tester.CheckRPCs(rpcUrls, locked.ChainID)
+	reportResults(results...)
+
+	verifications := make([]verification, 0, len(results))
+	for _, result := range results {
+		v := verification{Chain: locked.Chain, URL: result.URL}
+		latency := result.Latency
+		switch {
+		case result.Err != "":
+			v.Problem = "failed: " + result.Err
+		case requirement.MaxLatency > 0 && latency > requirement.MaxLatency:
+			v.LatencyMs = latency.Milliseconds()
+			v.Problem = fmt.Sprintf("slow: %dms > %dms", v.LatencyMs, requirement.MaxLatency.Milliseconds())
+		default:
+			v.OK = true
+			v.LatencyMs = latency.Milliseconds()
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, nil
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&lockPath, "lock", "chains.lock.json", "lock file written by resolve")
+	verifyCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	durationVarP(verifyCmd.Flags(), &verifyTimeout, "timeout", "t", time.Second, "timeout for RPC testing")
+	verifyCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing endpoint is retried with exponential backoff")
+	addOutputFlags(verifyCmd)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"chain-rpc/pkg/chain"
+
+	"github.com/spf13/cobra"
+)
+
+// Set at build time for builds outside of a git checkout, e.g.
+// -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	commit    string
+	buildDate string
+)
+
+// buildInfo is what is deployed: the build, the embedded dataset and the cache in use
+type buildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	// Modified tells the build had uncommitted changes
+	Modified bool `json:"modified,omitempty"`
+	// CommitTime is when the commit was made, BuildDate when the binary was built if set
+	CommitTime         string        `json:"commitTime,omitempty"`
+	BuildDate          string        `json:"buildDate,omitempty"`
+	GoVersion          string        `json:"goVersion"`
+	Platform           string        `json:"platform"`
+	CacheSchemaVersion int           `json:"cacheSchemaVersion"`
+	SnapshotTime       *time.Time    `json:"snapshotTime,omitempty"`
+	Cache              *cacheDataset `json:"cache,omitempty"`
+}
+
+// cacheDataset identifies the dataset of the cache
+type cacheDataset struct {
+	Path          string    `json:"path"`
+	Sources       []string  `json:"sources,omitempty"`
+	SourceTime    time.Time `json:"sourceTime"`
+	FetchedAt     time.Time `json:"fetchedAt"`
+	SchemaVersion int       `json:"schemaVersion"`
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version number",
+	Long:  "Print the version number of chain-rpc. As JSON, it also includes the git commit, build date, Go version, the cache schema version and the dataset of the embedded snapshot and the cache, for bug reports and inventories",
+	Args:  exactArgsWithParameterError(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+		if !asJSON {
+			fmt.Println(version)
+			return nil
+		}
+		return printJSON(currentBuildInfo())
+	},
+}
+
+// currentBuildInfo reads the build settings Go embeds in the binary, unless set at
+// build time, and the dataset in use. Nothing is downloaded.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:            version,
+		Commit:             commit,
+		BuildDate:          buildDate,
+		GoVersion:          runtime.Version(),
+		Platform:           runtime.GOOS + "/" + runtime.GOARCH,
+		CacheSchemaVersion: chain.CACHE_SCHEMA_VERSION,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				info.CommitTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if snapshotTime, err := chain.SnapshotTime(); err == nil {
+		info.SnapshotTime = &snapshotTime
+	}
+	if status, err := chain.GetCacheStatus(); err == nil && status.Meta != nil {
+		info.Cache = &cacheDataset{
+			Path:          status.Path,
+			Sources:       status.Meta.Sources,
+			SourceTime:    status.Meta.SourceTime,
+			FetchedAt:     status.Meta.FetchedAt,
+			SchemaVersion: status.Meta.SchemaVersion,
+		}
+	}
+	return info
+}
+
+func init() {
+	addOutputFlags(versionCmd)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"chain-rpc/pkg/rpc"
+)
+
+const (
+	// Latency changes are reported when the latency at least doubles or halves...
+	LATENCY_CHANGE_RATIO = 2.0
+	// ...and moves by at least this much
+	LATENCY_CHANGE_MIN = 50 * time.Millisecond
+)
+
+var watchInterval time.Duration
+
+// watchEvent is a change of an endpoint state between two test rounds
+type watchEvent struct {
+	Time          time.Time `json:"time"`
+	Event         string    `json:"event"`
+	URL           string    `json:"url"`
+	LatencyMs     int64     `json:"latencyMs,omitempty"`
+	PrevLatencyMs int64     `json:"previousLatencyMs,omitempty"`
+	// P95Ms and ErrorRate are the figures an endpoint was demoted or promoted on
+	P95Ms     int64    `json:"p95Ms,omitempty"`
+	ErrorRate *float64 `json:"errorRate,omitempty"`
+	// Insecure labels plaintext endpoints, included with --allow-insecure
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// watchRPCs re-tests the endpoints every interval and prints what changed
+// since the previous round until interrupted. With budgets, endpoints exceeding
+// them are `demoted`, and `promoted` once they recovered.
+func watchRPCs(tester *rpc.Tester, chainId uint64, rpcUrls []string, interval time.Duration, budgets *rpc.BudgetTracker, asJSON bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var budgetEvents []watchEvent
+	if budgets != nil {
+		budgets.OnChange = func(change rpc.BudgetChange) {
+			event := watchEvent{Time: change.Time, Event: "promoted", URL: change.URL, P95Ms: change.P95.Milliseconds(), ErrorRate: &change.ErrorRate, Insecure: isInsecureURL(change.URL)}
+			if change.Demoted {
+				event.Event = "demoted"
+			}
+			budgetEvents = append(budgetEvents, event)
+		}
+	}
+
+	previous := make(map[string]time.Duration)
+	for {
+		current := make(map[string]time.Duration)
+		for _, result := range tester.TestRPCs(rpcUrls, chainId) {
+			current[result.URL] = result.Latency
+		}
+		// Watching ends with an interrupt, keep each round
+		saveProbeHistory()
+
+		now := time.Now()
+		events := diffRounds(previous, current, now)
+		if budgets != nil {
+			budgetEvents = nil
+			for _, url := range rpcUrls {
+				latency, ok := current[url]
+				budgets.Observe(url, latency, ok, now)
+			}
+			events = append(events, budgetEvents...)
+		}
+		for _, event := range events {
+			if err := printWatchEvent(event, asJSON); err != nil {
+				return err
+			}
+		}
+		previous = current
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// diffRounds compares two test rounds: endpoints that stopped working are `down`,
+// ones that started working are `up`, and large latency swings are `latency`
+func diffRounds(previous, current map[string]time.Duration, now time.Time) []watchEvent {
+	events := make([]watchEvent, 0)
+
+	for url, latency := range current {
+		prevLatency, wasUp := previous[url]
+		switch {
+		case !wasUp:
+			events = append(events, watchEvent{Time: now, Event: "up", URL: url, LatencyMs: latency.Milliseconds(), Insecure: isInsecureURL(url)})
+		case isSignificantLatencyChange(prevLatency, latency):
+			events = append(events, watchEvent{Time: now, Event: "latency", URL: url, LatencyMs: latency.Milliseconds(), PrevLatencyMs: prevLatency.Milliseconds(), Insecure: isInsecureURL(url)})
+		}
+	}
+
+	for url := range previous {
+		if _, isUp := current[url]; !isUp {
+			events = append(events, watchEvent{Time: now, Event: "down", URL: url, Insecure: isInsecureURL(url)})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Event != events[j].Event {
+			return events[i].Event < events[j].Event
+		}
+		return events[i].URL < events[j].URL
+	})
+	return events
+}
+
+func isSignificantLatencyChange(previous, current time.Duration) bool {
+	diff := current - previous
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff < LATENCY_CHANGE_MIN {
+		return false
+	}
+
+	return float64(current) >= float64(previous)*LATENCY_CHANGE_RATIO ||
+		float64(current)*LATENCY_CHANGE_RATIO <= float64(previous)
+}
+
+func printWatchEvent(event watchEvent, asJSON bool) error {
+	if asJSON {
+		// JSON lines: one compact object per event
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	timestamp := event.Time.Format(time.RFC3339)
+	url := event.URL
+	if event.Insecure {
+		url += " [insecure]"
+	}
+	switch event.Event {
+	case "up":
+		fmt.Printf("%s up      %s (%s)\n", timestamp, url, formatLatency(msDuration(event.LatencyMs)))
+	case "down":
+		fmt.Printf("%s down    %s\n", timestamp, url)
+	case "latency":
+		fmt.Printf("%s latency %s %s -> %s\n", timestamp, url, formatLatency(msDuration(event.PrevLatencyMs)), formatLatency(msDuration(event.LatencyMs)))
+	case "demoted", "promoted":
+		fmt.Printf("%s %-8s %s (p95 %s, %d%% errors)\n", timestamp, event.Event, url, formatLatency(msDuration(event.P95Ms)), int(math.Round(*event.ErrorRate*100)))
+	}
+	return nil
+}
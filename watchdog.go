@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"chain-rpc/pkg/chain"
+	"chain-rpc/pkg/rpc"
+
+	"github.com/spf13/cobra"
+)
+
+const DEFAULT_WATCHDOG_INTERVAL = 15 * time.Second
+
+var (
+	watchdogChain    string
+	watchdogInterval time.Duration
+	alertLatency     time.Duration
+	alertLag         int64
+	onAlert          string
+	onRecover        string
+	// watchdogTimeout is the --timeout of watch, whose default differs from the root command's
+	watchdogTimeout time.Duration
+)
+
+// Watchdog states, by priority when several thresholds are crossed at once
+const (
+	WATCHDOG_OK      = "ok"
+	WATCHDOG_DOWN    = "down"
+	WATCHDOG_LAGGING = "lagging"
+	WATCHDOG_SLOW    = "slow"
+)
+
+// watchdogEvent is a change of state of the watched endpoint
+type watchdogEvent struct {
+	Time        time.Time `json:"time"`
+	State       string    `json:"state"`
+	URL         string    `json:"url"`
+	LatencyMs   int64     `json:"latencyMs,omitempty"`
+	BlockNumber uint64    `json:"blockNumber,omitempty"`
+	// BlockLag is how many blocks the endpoint is behind the highest public endpoint,
+	// unknown without --alert-lag
+	BlockLag *int64 `json:"blockLag,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <url> --chain <chainId|chainName>",
+	Short: "Watch an endpoint and alert on failures, latency or block lag",
+	Long:  "Checks an endpoint, typically one's own node, every --interval until interrupted: that it serves the chain and is not syncing, how fast it answers and, with --alert-lag, how many blocks it is behind the public endpoints of the chain. State changes are printed. Once a threshold is crossed, chain-rpc exits non-zero, unless --on-alert is set: the hook is then run and watching goes on",
+	Args:  exactArgsWithParameterError(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chain.SetForceRebuild(force)
+		if watchdogChain == "" {
+			return NewParameterErrorWithCmd("requires --chain", cmd)
+		}
+		if watchdogInterval <= 0 {
+			return NewParameterErrorWithCmd("interval must be positive", cmd)
+		}
+		if alertLag < 0 {
+			return NewParameterErrorWithCmd("alert-lag must not be negative", cmd)
+		}
+		if retries < 0 {
+			return NewParameterErrorWithCmd("retries must not be negative", cmd)
+		}
+
+		asJSON, err := isJSONOutput(cmd)
+		if err != nil {
+			return err
+		}
+
+		chainData, err := getChainData(watchdogChain)
+		if err != nil {
+			return err
+		}
+
+		// The block lag is measured against the public endpoints of the chain
+		var referenceURLs []string
+		if alertLag > 0 {
+			for _, rpcURL := range extractRPCUrls(chainData.RPCs, false, false) {
+				if rpcURL != args[0] {
+					referenceURLs = append(referenceURLs, rpcURL)
+				}
+			}
+			if len(referenceURLs) == 0 {
+				return fmt.Errorf("no public endpoints of %s to measure the block lag against", chainData.Name)
+			}
+		}
+
+		return watchEndpoint(chainData, args[0], referenceURLs, asJSON)
+	},
+}
+
+// watchEndpoint checks the endpoint every interval until interrupted, and alerts when
+// its state changes from ok
+func watchEndpoint(chainData *chain.ChainData, rpcURL string, referenceURLs []string, asJSON bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	state := ""
+	for {
+		event := checkEndpoint(chainData.ChainID, rpcURL, referenceURLs)
+		// Watching ends with an interrupt, keep each round
+		saveProbeHistory()
+
+		if event.State != state {
+			if err := printWatchdogEvent(event, asJSON); err != nil {
+				return err
+			}
+			switch {
+			case event.State != WATCHDOG_OK && onAlert == "":
+				return fmt.Errorf("%s is %s: %s", rpcURL, event.State, event.Reason)
+			case event.State != WATCHDOG_OK:
+				runWatchdogHook(onAlert, chainData, event)
+			case state != "" && onRecover != "":
+				runWatchdogHook(onRecover, chainData, event)
+			}
+			state = event.State
+		} else if verbose {
+			printWatchdogEvent(event, asJSON)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// checkEndpoint tests the endpoint, together with the reference endpoints so that
+// block numbers are taken at the same time, and tells its state
+func checkEndpoint(chainID uint64, rpcURL string, referenceURLs []string) watchdogEvent {
+	tester := rpc.NewTester(watchdogTimeout)
+	tester.Retries = retries
+	// Latencies are only comparable once connections are set up
+	tester.WarmUp = true
+	tester.Checks = append(tester.Checks, rpc.SyncingCheck{}, rpc.BlockCheck{})
+	if traceProbes {
+		tester.Trace = newProbeTracer()
+	}
+
+	results := tester.CheckRPCs(append([]string{rpcURL}, referenceURLs...), chainID)
+	event := watchdogEvent{Time: time.Now(), State: WATCHDOG_OK, URL: rpcURL}
+
+	watched := results[0]
+	var head uint64
+	for _, result := range results[1:] {
+		head = max(head, result.BlockNumber)
+	}
+	if watched.Err != "" {
+		event.State = WATCHDOG_DOWN
+		event.Reason = "failed: " + watched.Err
+		return event
+	}
+
+	event.LatencyMs = watched.Latency.Milliseconds()
+	event.BlockNumber = watched.BlockNumber
+	if head > 0 {
+		lag := int64(head) - int64(watched.BlockNumber)
+		event.BlockLag = &lag
+	}
+	switch {
+	case event.BlockLag != nil && *event.BlockLag > alertLag:
+		event.State = WATCHDOG_LAGGING
+		event.Reason = fmt.Sprintf("%s blocks behind > %s", formatCount(*event.BlockLag), formatCount(alertLag))
+	case alertLatency > 0 && watched.Latency > alertLatency:
+		event.State = WATCHDOG_SLOW
+		event.Reason = fmt.Sprintf("%s > %s", formatLatency(watched.Latency), formatLatency(alertLatency))
+	}
+	return event
+}
+
+// runWatchdogHook runs the hook through the shell with the event in its environment.
+// Failing hooks are reported, watching goes on.
+func runWatchdogHook(hook string, chainData *chain.ChainData, event watchdogEvent) {
+	child := exec.Command("sh", "-c", hook)
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.Env = append(os.Environ(),
+		"ETH_RPC_URL="+event.URL,
+		"CHAIN_ID="+strconv.FormatUint(chainData.ChainID, 10),
+		"CHAIN_NAME="+chainData.Name,
+		"WATCH_STATE="+event.State,
+		"WATCH_REASON="+event.Reason,
+		"WATCH_LATENCY_MS="+strconv.FormatInt(event.LatencyMs, 10),
+	)
+	if event.BlockLag != nil {
+		child.Env = append(child.Env, "WATCH_BLOCK_LAG="+strconv.FormatInt(*event.BlockLag, 10))
+	}
+	if err := child.Run(); err != nil {
+		warnf("hook failed: %v", err)
+	}
+}
+
+func printWatchdogEvent(event watchdogEvent, asJSON bool) error {
+	if asJSON {
+		// JSON lines: one compact object per event
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	timestamp := event.Time.Format(time.RFC3339)
+	if event.State == WATCHDOG_DOWN {
+		fmt.Printf("%s %-7s %s: %s\n", timestamp, event.State, event.URL, event.Reason)
+		return nil
+	}
+	details := fmt.Sprintf("%s, block %s", formatLatency(msDuration(event.LatencyMs)), formatCount(event.BlockNumber))
+	if event.BlockLag != nil {
+		details += fmt.Sprintf(", lag %s", formatCount(*event.BlockLag))
+	}
+	if event.Reason != "" {
+		details += "; " + event.Reason
+	}
+	fmt.Printf("%s %-7s %s (%s)\n", timestamp, event.State, event.URL, details)
+	return nil
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&watchdogChain, "chain", "", "chain ID or name the endpoint must serve")
+	durationVar(watchCmd.Flags(), &watchdogInterval, "interval", DEFAULT_WATCHDOG_INTERVAL, "time between checks")
+	durationVar(watchCmd.Flags(), &alertLatency, "alert-latency", 0, "alert when the endpoint answers slower than this (0: never)")
+	watchCmd.Flags().Int64Var(&alertLag, "alert-lag", 0, "alert when the endpoint is more than this many blocks behind the public endpoints of the chain (0: lag not checked)")
+	watchCmd.Flags().StringVar(&onAlert, "on-alert", "", "shell command run when a threshold is crossed, instead of exiting; the event is in WATCH_STATE, WATCH_REASON, WATCH_LATENCY_MS, WATCH_BLOCK_LAG")
+	watchCmd.Flags().StringVar(&onRecover, "on-recover", "", "shell command run when the endpoint is ok again after an alert")
+	watchCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "print every check, not only state changes")
+	watchCmd.Flags().BoolVarP(&force, "force", "f", false, "force rebuild cache")
+	durationVarP(watchCmd.Flags(), &watchdogTimeout, "timeout", "t", 5*time.Second, "timeout of every check")
+	watchCmd.Flags().IntVar(&retries, "retries", 0, "number of times a failing check is retried with exponential backoff")
+	watchCmd.Flags().BoolVar(&traceProbes, "trace-probes", false, "log the lifecycle of every probe to stderr")
+	watchCmd.Flags().BoolVar(&allowInsecure, "allow-insecure", false, "include plaintext http:// and ws:// public endpoints when measuring the block lag")
+	addOutputFlags(watchCmd)
+}